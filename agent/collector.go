@@ -17,14 +17,15 @@ import (
 )
 
 type SystemMetrics struct {
-	Timestamp time.Time      `json:"timestamp"`
-	Hostname  string         `json:"hostname"`
-	OS        OSInfo         `json:"os"`
-	CPU       CPUMetrics     `json:"cpu"`
-	Memory    MemoryMetrics  `json:"memory"`
-	Disk      []DiskMetrics  `json:"disk"`
-	Network   NetworkMetrics `json:"network"`
-	Runtime   RuntimeMetrics `json:"runtime"`
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname"`
+	OS        OSInfo    `json:"os"`
+	// Metric groups are nil when their collector is disabled
+	CPU     *CPUMetrics     `json:"cpu,omitempty"`
+	Memory  *MemoryMetrics  `json:"memory,omitempty"`
+	Disk    []DiskMetrics   `json:"disk,omitempty"`
+	Network *NetworkMetrics `json:"network,omitempty"`
+	Runtime *RuntimeMetrics `json:"runtime,omitempty"`
 }
 
 type OSInfo struct {
@@ -102,7 +103,9 @@ type RuntimeMetrics struct {
 	NumCPU       int    `json:"num_cpu"`
 }
 
-func CollectSystemMetrics() (*SystemMetrics, error) {
+// CollectSystemMetrics collects host information and the metric groups
+// enabled in the configuration
+func CollectSystemMetrics(cfg *AgentConfig) (*SystemMetrics, error) {
 	metrics := &SystemMetrics{
 		Timestamp: time.Now().UTC(),
 	}
@@ -129,159 +132,169 @@ func CollectSystemMetrics() (*SystemMetrics, error) {
 	}
 
 	// CPU metrics
-	cpuPercent, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get CPU percent: %w", err)
-	}
+	if cfg.enabled("cpu") {
+		cpuPercent, err := cpu.Percent(time.Second, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CPU percent: %w", err)
+		}
 
-	cpuInfo, err := cpu.Info()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get CPU info: %w", err)
-	}
+		cpuInfo, err := cpu.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CPU info: %w", err)
+		}
 
-	var modelName string
-	if len(cpuInfo) > 0 {
-		modelName = cpuInfo[0].ModelName
-	}
+		var modelName string
+		if len(cpuInfo) > 0 {
+			modelName = cpuInfo[0].ModelName
+		}
 
-	metrics.CPU = CPUMetrics{
-		ModelName:    modelName,
-		Cores:        int32(runtime.NumCPU()),
-		LogicalCores: int32(runtime.NumCPU()),
-		UsagePercent: cpuPercent[0],
-		LoadAverage:  []float64{0.1, 0.2, 0.3}, // Simplified
-		FrequencyMHz: float64(cpuInfo[0].Mhz) / 1000.0,
+		metrics.CPU = &CPUMetrics{
+			ModelName:    modelName,
+			Cores:        int32(runtime.NumCPU()),
+			LogicalCores: int32(runtime.NumCPU()),
+			UsagePercent: cpuPercent[0],
+			LoadAverage:  []float64{0.1, 0.2, 0.3}, // Simplified
+			FrequencyMHz: float64(cpuInfo[0].Mhz) / 1000.0,
+		}
 	}
 
 	// Memory metrics
-	memInfo, err := mem.VirtualMemory()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get memory info: %w", err)
-	}
+	if cfg.enabled("memory") {
+		memInfo, err := mem.VirtualMemory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get memory info: %w", err)
+		}
 
-	swapInfo, err := mem.SwapMemory()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get swap info: %w", err)
-	}
+		swapInfo, err := mem.SwapMemory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get swap info: %w", err)
+		}
 
-	metrics.Memory = MemoryMetrics{
-		Total:       memInfo.Total,
-		Available:   memInfo.Available,
-		Used:        memInfo.Used,
-		UsedPercent: memInfo.UsedPercent,
-		SwapTotal:   swapInfo.Total,
-		SwapUsed:    swapInfo.Used,
-		SwapPercent: swapInfo.UsedPercent,
+		metrics.Memory = &MemoryMetrics{
+			Total:       memInfo.Total,
+			Available:   memInfo.Available,
+			Used:        memInfo.Used,
+			UsedPercent: memInfo.UsedPercent,
+			SwapTotal:   swapInfo.Total,
+			SwapUsed:    swapInfo.Used,
+			SwapPercent: swapInfo.UsedPercent,
+		}
 	}
 
 	// Disk metrics
-	partitions, err := disk.Partitions(false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get disk partitions: %w", err)
-	}
-
-	for _, partition := range partitions {
-		usage, err := disk.Usage(partition.Mountpoint)
+	if cfg.enabled("disk") {
+		partitions, err := disk.Partitions(false)
 		if err != nil {
-			continue // Skip if we can't get usage
+			return nil, fmt.Errorf("failed to get disk partitions: %w", err)
 		}
 
-		metrics.Disk = append(metrics.Disk, DiskMetrics{
-			Device:      partition.Device,
-			Mountpoint:  partition.Mountpoint,
-			Fstype:      partition.Fstype,
-			Total:       usage.Total,
-			Free:        usage.Free,
-			Used:        usage.Used,
-			UsedPercent: usage.UsedPercent,
-		})
-	}
+		for _, partition := range partitions {
+			usage, err := disk.Usage(partition.Mountpoint)
+			if err != nil {
+				continue // Skip if we can't get usage
+			}
 
-	// Network metrics
-	netInterfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+			metrics.Disk = append(metrics.Disk, DiskMetrics{
+				Device:      partition.Device,
+				Mountpoint:  partition.Mountpoint,
+				Fstype:      partition.Fstype,
+				Total:       usage.Total,
+				Free:        usage.Free,
+				Used:        usage.Used,
+				UsedPercent: usage.UsedPercent,
+			})
+		}
 	}
 
-	for _, iface := range netInterfaces {
-		// Skip loopback interfaces
-		hasLoopback := false
-		for _, flag := range iface.Flags {
-			if flag == "flagLoopback" || flag == "loopback" {
-				hasLoopback = true
-				break
-			}
-		}
-		if hasLoopback {
-			continue
+	// Network metrics
+	if cfg.enabled("network") {
+		netInterfaces, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get network interfaces: %w", err)
 		}
 
-		var addressStrings []string
-		for _, addr := range iface.Addrs {
-			addrStr := addr.String()
-			// Parse the JSON format to extract the actual address
-			if strings.HasPrefix(addrStr, "{\"addr\":\"") {
-				// Extract address from JSON format: {"addr":"..."}
-				start := strings.Index(addrStr, "{\"addr\":\"") + 9
-				end := strings.Index(addrStr[start:], "\"")
-				if end != -1 {
-					addressStrings = append(addressStrings, addrStr[start:start+end])
-					continue
+		for _, iface := range netInterfaces {
+			// Skip loopback interfaces
+			hasLoopback := false
+			for _, flag := range iface.Flags {
+				if flag == "flagLoopback" || flag == "loopback" {
+					hasLoopback = true
+					break
 				}
 			}
-			// Fallback to original string if not JSON format
-			addressStrings = append(addressStrings, addrStr)
-		}
+			if hasLoopback {
+				continue
+			}
 
-		metrics.Network.Interfaces = append(metrics.Network.Interfaces, NetworkInterface{
-			Name:         iface.Name,
-			HardwareAddr: iface.HardwareAddr,
-			MTU:          iface.MTU,
-			Flags:        iface.Flags,
-			Addresses:    addressStrings,
-			BytesSent:    0, // These counters aren't available in basic interface info
-			BytesRecv:    0,
-			PacketsSent:  0,
-			PacketsRecv:  0,
-			Errin:        0,
-			Errout:       0,
-			Dropin:       0,
-			Dropout:      0,
-		})
-	}
+			var addressStrings []string
+			for _, addr := range iface.Addrs {
+				addrStr := addr.String()
+				// Parse the JSON format to extract the actual address
+				if strings.HasPrefix(addrStr, "{\"addr\":\"") {
+					// Extract address from JSON format: {"addr":"..."}
+					start := strings.Index(addrStr, "{\"addr\":\"") + 9
+					end := strings.Index(addrStr[start:], "\"")
+					if end != -1 {
+						addressStrings = append(addressStrings, addrStr[start:start+end])
+						continue
+					}
+				}
+				// Fallback to original string if not JSON format
+				addressStrings = append(addressStrings, addrStr)
+			}
 
-	// Network connections (limited to first 20)
-	connections, err := net.Connections("all")
-	if err == nil {
-		limit := 20
-		if len(connections) < limit {
-			limit = len(connections)
-		}
-		for _, conn := range connections[:limit] {
-			metrics.Network.Connections = append(metrics.Network.Connections, ConnectionInfo{
-				LocalAddr:  fmt.Sprintf("%s:%d", conn.Laddr.IP, conn.Laddr.Port),
-				RemoteAddr: fmt.Sprintf("%s:%d", conn.Raddr.IP, conn.Raddr.Port),
-				State:      conn.Status,
-				PID:        conn.Pid,
-				Process:    fmt.Sprintf("process_%d", conn.Pid),
+			metrics.Network.Interfaces = append(metrics.Network.Interfaces, NetworkInterface{
+				Name:         iface.Name,
+				HardwareAddr: iface.HardwareAddr,
+				MTU:          iface.MTU,
+				Flags:        iface.Flags,
+				Addresses:    addressStrings,
+				BytesSent:    0, // These counters aren't available in basic interface info
+				BytesRecv:    0,
+				PacketsSent:  0,
+				PacketsRecv:  0,
+				Errin:        0,
+				Errout:       0,
+				Dropin:       0,
+				Dropout:      0,
 			})
 		}
+
+		// Network connections (limited to first 20)
+		connections, err := net.Connections("all")
+		if err == nil {
+			limit := 20
+			if len(connections) < limit {
+				limit = len(connections)
+			}
+			for _, conn := range connections[:limit] {
+				metrics.Network.Connections = append(metrics.Network.Connections, ConnectionInfo{
+					LocalAddr:  fmt.Sprintf("%s:%d", conn.Laddr.IP, conn.Laddr.Port),
+					RemoteAddr: fmt.Sprintf("%s:%d", conn.Raddr.IP, conn.Raddr.Port),
+					State:      conn.Status,
+					PID:        conn.Pid,
+					Process:    fmt.Sprintf("process_%d", conn.Pid),
+				})
+			}
+		}
 	}
 
 	// Runtime metrics
-	metrics.Runtime = RuntimeMetrics{
-		GoVersion:    runtime.Version(),
-		GoOS:         runtime.GOOS,
-		GoArch:       runtime.GOARCH,
-		NumGoroutine: runtime.NumGoroutine(),
-		NumCPU:       runtime.NumCPU(),
+	if cfg.enabled("runtime") {
+		metrics.Runtime = &RuntimeMetrics{
+			GoVersion:    runtime.Version(),
+			GoOS:         runtime.GOOS,
+			GoArch:       runtime.GOARCH,
+			NumGoroutine: runtime.NumGoroutine(),
+			NumCPU:       runtime.NumCPU(),
+		}
 	}
 
 	return metrics, nil
 }
 
-func PrintMetricsJSON() {
-	metrics, err := CollectSystemMetrics()
+func PrintMetricsJSON(cfg *AgentConfig) {
+	metrics, err := CollectSystemMetrics(cfg)
 	if err != nil {
 		log.Fatalf("Failed to collect metrics: %v", err)
 	}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnv names the environment variable that points at the config file
+const configEnv = "LITEMIDGO_AGENT_CONFIG"
+
+// allCollectors are the metric groups the agent can collect; host and OS
+// information is always sent
+var allCollectors = []string{"cpu", "memory", "disk", "network", "runtime"}
+
+// AgentConfig is the agent configuration. It is read from a YAML or JSON file;
+// environment variables override the file and flags override both.
+type AgentConfig struct {
+	// ServerURLs are tried in order until one accepts the metrics;
+	// ServerURL is a shorthand for a single server
+	ServerURL  string   `yaml:"server_url" json:"server_url,omitempty"`
+	ServerURLs []string `yaml:"server_urls" json:"server_urls"`
+	// Username and Password are sent with basic authentication, Token as
+	// a bearer token and APIKey in the APIKeyHeader header. They can be
+	// kept out of the config in CredentialsFile.
+	Username        string            `yaml:"username" json:"username,omitempty"`
+	Password        string            `yaml:"password" json:"password,omitempty"`
+	Token           string            `yaml:"token" json:"token,omitempty"`
+	APIKey          string            `yaml:"api_key" json:"api_key,omitempty"`
+	APIKeyHeader    string            `yaml:"api_key_header" json:"api_key_header"`
+	CredentialsFile string            `yaml:"credentials_file" json:"credentials_file,omitempty"`
+	Interval        int               `yaml:"interval" json:"interval"`
+	AgentName       string            `yaml:"agent_name" json:"agent_name"`
+	Topic           string            `yaml:"topic" json:"topic"`
+	Tags            map[string]string `yaml:"tags" json:"tags,omitempty"`
+	Collectors      []string          `yaml:"collectors" json:"collectors"`
+	TLS             TLSConfig         `yaml:"tls" json:"tls"`
+	Debug           bool              `yaml:"debug" json:"debug"`
+}
+
+// TLSConfig controls HTTPS connections to the server. CAFile is added to the
+// system roots; CertFile and KeyFile are a client certificate.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file" json:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file" json:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file" json:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name" json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+}
+
+func defaultConfig() *AgentConfig {
+	return &AgentConfig{
+		ServerURLs:   []string{"http://localhost:8080"},
+		APIKeyHeader: "X-API-Key",
+		Interval:     60,
+		AgentName:    "litemidgo-agent",
+		Topic:        "endpointData",
+		Collectors:   allCollectors,
+	}
+}
+
+// Credentials is the content of a credentials file, YAML or JSON like the
+// config file
+type Credentials struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Token    string `yaml:"token" json:"token"`
+	APIKey   string `yaml:"api_key" json:"api_key"`
+}
+
+// configPaths are searched in order when neither --config nor
+// LITEMIDGO_AGENT_CONFIG is set
+func configPaths() []string {
+	var dirs []string
+	dirs = append(dirs, ".", "config")
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".litemidgo"))
+	}
+	dirs = append(dirs, "/etc/litemidgo")
+
+	var paths []string
+	for _, dir := range dirs {
+		for _, ext := range []string{".yaml", ".yml", ".json"} {
+			paths = append(paths, filepath.Join(dir, "agent"+ext))
+		}
+	}
+	return paths
+}
+
+// loadConfig builds the configuration from the defaults, the config file, the
+// environment and the flags that were set, and returns the file used
+func loadConfig(cmd *cobra.Command) (*AgentConfig, string, error) {
+	cfg := defaultConfig()
+
+	path := configFile
+	if path == "" {
+		path = os.Getenv(configEnv)
+	}
+	if path == "" {
+		for _, candidate := range configPaths() {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// The credentials file sits between the config file and the
+	// environment, so its path is resolved first
+	if v := os.Getenv("LITEMIDGO_AGENT_CREDENTIALS_FILE"); v != "" {
+		cfg.CredentialsFile = v
+	}
+	if cmd.Flags().Changed("credentials-file") {
+		cfg.CredentialsFile = credentialsFile
+	}
+	if cfg.CredentialsFile != "" {
+		if err := cfg.readCredentials(cfg.CredentialsFile); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, "", err
+	}
+	if err := cfg.applyFlags(cmd); err != nil {
+		return nil, "", err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, "", fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, path, nil
+}
+
+// readFile merges a YAML or JSON file into the configuration
+func (c *AgentConfig) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file AgentConfig
+	if err := decodeFile(path, data, &file); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	if file.ServerURL != "" {
+		c.ServerURLs = []string{file.ServerURL}
+	}
+	if len(file.ServerURLs) > 0 {
+		c.ServerURLs = file.ServerURLs
+	}
+	setString(&c.Username, file.Username)
+	setString(&c.Password, file.Password)
+	setString(&c.Token, file.Token)
+	setString(&c.APIKey, file.APIKey)
+	setString(&c.APIKeyHeader, file.APIKeyHeader)
+	setString(&c.CredentialsFile, file.CredentialsFile)
+	if file.Interval != 0 {
+		c.Interval = file.Interval
+	}
+	setString(&c.AgentName, file.AgentName)
+	setString(&c.Topic, file.Topic)
+	if file.Tags != nil {
+		c.Tags = file.Tags
+	}
+	if file.Collectors != nil {
+		c.Collectors = file.Collectors
+	}
+	c.TLS = file.TLS
+	c.Debug = c.Debug || file.Debug
+	return nil
+}
+
+// readCredentials merges a credentials file into the configuration
+func (c *AgentConfig) readCredentials(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 && runtime.GOOS != "windows" {
+		log.Printf("⚠️  Credentials file %s is accessible by other users (mode %04o); use chmod 600", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var creds Credentials
+	if err := decodeFile(path, data, &creds); err != nil {
+		return fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+
+	setString(&c.Username, creds.Username)
+	setString(&c.Password, creds.Password)
+	setString(&c.Token, creds.Token)
+	setString(&c.APIKey, creds.APIKey)
+	return nil
+}
+
+// decodeFile decodes JSON files by extension and everything else as YAML,
+// refusing unknown keys
+func decodeFile(path string, data []byte, v interface{}) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); !errors.Is(err, io.EOF) {
+		return err
+	}
+	// An empty file keeps the defaults
+	return nil
+}
+
+// applyEnv applies the LITEMIDGO_* environment variables
+func (c *AgentConfig) applyEnv() error {
+	if v := os.Getenv("LITEMIDGO_SERVER_URL"); v != "" {
+		c.ServerURLs = splitList(v)
+	}
+	setString(&c.Username, os.Getenv("LITEMIDGO_AGENT_USERNAME"))
+	setString(&c.Password, os.Getenv("LITEMIDGO_AGENT_PASSWORD"))
+	setString(&c.Token, os.Getenv("LITEMIDGO_AGENT_TOKEN"))
+	setString(&c.APIKey, os.Getenv("LITEMIDGO_AGENT_API_KEY"))
+	setString(&c.APIKeyHeader, os.Getenv("LITEMIDGO_AGENT_API_KEY_HEADER"))
+	if v := os.Getenv("LITEMIDGO_INTERVAL"); v != "" {
+		interval, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("LITEMIDGO_INTERVAL: %q is not a number of seconds", v)
+		}
+		c.Interval = interval
+	}
+	setString(&c.AgentName, os.Getenv("LITEMIDGO_AGENT_NAME"))
+	setString(&c.Topic, os.Getenv("LITEMIDGO_AGENT_TOPIC"))
+	if v := os.Getenv("LITEMIDGO_AGENT_TAGS"); v != "" {
+		tags, err := parseTags(splitList(v))
+		if err != nil {
+			return fmt.Errorf("LITEMIDGO_AGENT_TAGS: %w", err)
+		}
+		c.Tags = tags
+	}
+	if v := os.Getenv("LITEMIDGO_AGENT_COLLECTORS"); v != "" {
+		c.Collectors = splitList(v)
+	}
+	setString(&c.TLS.CAFile, os.Getenv("LITEMIDGO_AGENT_CA_FILE"))
+	setString(&c.TLS.CertFile, os.Getenv("LITEMIDGO_AGENT_CERT_FILE"))
+	setString(&c.TLS.KeyFile, os.Getenv("LITEMIDGO_AGENT_KEY_FILE"))
+	setString(&c.TLS.ServerName, os.Getenv("LITEMIDGO_AGENT_SERVER_NAME"))
+	if v := os.Getenv("LITEMIDGO_AGENT_INSECURE_SKIP_VERIFY"); v != "" {
+		c.TLS.InsecureSkipVerify, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("LITEMIDGO_DEBUG"); v != "" {
+		c.Debug, _ = strconv.ParseBool(v)
+	}
+	return nil
+}
+
+// applyFlags applies the flags given on the command line
+func (c *AgentConfig) applyFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Changed("server") {
+		c.ServerURLs = serverURLs
+	}
+	if flags.Changed("interval") {
+		c.Interval = interval
+	}
+	if flags.Changed("agent-name") {
+		c.AgentName = agentName
+	}
+	if flags.Changed("topic") {
+		c.Topic = topic
+	}
+	if flags.Changed("tag") {
+		tags, err := parseTags(tagFlags)
+		if err != nil {
+			return fmt.Errorf("--tag: %w", err)
+		}
+		c.Tags = tags
+	}
+	if flags.Changed("collectors") {
+		c.Collectors = collectors
+	}
+	if flags.Changed("username") {
+		c.Username = username
+	}
+	if flags.Changed("cert") {
+		c.TLS.CertFile = certFile
+	}
+	if flags.Changed("key") {
+		c.TLS.KeyFile = keyFile
+	}
+	if flags.Changed("ca-file") {
+		c.TLS.CAFile = caFile
+	}
+	if flags.Changed("debug") {
+		c.Debug = debug
+	}
+	return nil
+}
+
+func (c *AgentConfig) validate() error {
+	if len(c.ServerURLs) == 0 {
+		return fmt.Errorf("no server URL configured")
+	}
+	for _, raw := range c.ServerURLs {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid server URL %q (use http://host:port or https://host:port)", raw)
+		}
+	}
+	if c.Interval < 1 {
+		return fmt.Errorf("interval must be at least 1 second, got %d", c.Interval)
+	}
+	if c.AgentName == "" || c.Topic == "" {
+		return fmt.Errorf("agent_name and topic must not be empty")
+	}
+	for _, name := range c.Collectors {
+		if !contains(allCollectors, name) {
+			return fmt.Errorf("unknown collector %q (use %s)", name, strings.Join(allCollectors, ", "))
+		}
+	}
+	if c.Password != "" && c.Username == "" {
+		return fmt.Errorf("password is set without a username")
+	}
+	if c.Username != "" && c.Token != "" {
+		return fmt.Errorf("set either username/password or token, not both (both use the Authorization header)")
+	}
+	if c.APIKey != "" && c.APIKeyHeader == "" {
+		return fmt.Errorf("api_key_header must not be empty when api_key is set")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// enabled reports whether a collector is enabled
+func (c *AgentConfig) enabled(collector string) bool {
+	return contains(c.Collectors, collector)
+}
+
+// httpClient builds the client used to reach the server
+func (c *AgentConfig) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+	if c.TLS.CAFile != "" {
+		pem, err := os.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", c.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// authMethods describes the credentials sent to the server, for messages
+func (c *AgentConfig) authMethods() string {
+	var methods []string
+	if c.Username != "" {
+		methods = append(methods, fmt.Sprintf("basic auth as %q", c.Username))
+	}
+	if c.Token != "" {
+		methods = append(methods, "bearer token")
+	}
+	if c.APIKey != "" {
+		methods = append(methods, "API key in "+c.APIKeyHeader)
+	}
+	if c.TLS.CertFile != "" {
+		methods = append(methods, "client certificate "+c.TLS.CertFile)
+	}
+	if len(methods) == 0 {
+		return "no credentials"
+	}
+	return strings.Join(methods, ", ")
+}
+
+// redacted is the configuration with the secrets hidden, for printing
+func (c AgentConfig) redacted() AgentConfig {
+	for _, secret := range []*string{&c.Password, &c.Token, &c.APIKey} {
+		if *secret != "" {
+			*secret = "********"
+		}
+	}
+	return c
+}
+
+func setString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTags reads key=value pairs
+func parseTags(pairs []string) (map[string]string, error) {
+	tags := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q (use key=value)", pair)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
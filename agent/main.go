@@ -4,26 +4,35 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	serverURL string
-	interval  int
-	once      bool
-	debug     bool
-)
+	configFile string
+	serverURLs []string
+	interval   int
+	agentName  string
+	topic      string
+	tagFlags   []string
+	collectors []string
+	once       bool
+	debug      bool
 
-type AgentConfig struct {
-	ServerURL string `json:"server_url"`
-	Interval  int    `json:"interval"`
-	AgentName string `json:"agent_name"`
-}
+	username        string
+	credentialsFile string
+	certFile        string
+	keyFile         string
+	caFile          string
+)
 
 type Payload struct {
 	Agent   string      `json:"agent"`
@@ -34,139 +43,324 @@ type Payload struct {
 }
 
 func main() {
-	// Set default server URL from environment variable
-	defaultServerURL := os.Getenv("LITEMIDGO_SERVER_URL")
-	if defaultServerURL == "" {
-		defaultServerURL = "http://localhost:8080"
-	}
-
 	var rootCmd = &cobra.Command{
 		Use:   "litemidgo-agent",
 		Short: "LiteMIDgo SensuGo-compatible agent",
 		Long: `A lightweight monitoring agent that collects system metrics
-and sends them to LiteMIDgo server for ServiceNow integration.`,
+and sends them to LiteMIDgo server for ServiceNow integration.
+
+Settings are read from --config, $LITEMIDGO_AGENT_CONFIG or the first agent.yaml,
+agent.yml or agent.json found in ., ./config, $HOME/.litemidgo and
+/etc/litemidgo. Environment variables override the file; flags override both.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
 	}
 
 	var collectCmd = &cobra.Command{
 		Use:   "collect",
 		Short: "Collect and display system metrics",
-		Run: func(cmd *cobra.Command, args []string) {
-			PrintMetricsJSON()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			PrintMetricsJSON(cfg)
+			return nil
 		},
 	}
 
 	var sendCmd = &cobra.Command{
 		Use:   "send",
 		Short: "Collect and send metrics to LiteMIDgo server",
-		Run: func(cmd *cobra.Command, args []string) {
-			sendMetrics()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := newAgent(cmd)
+			if err != nil {
+				return err
+			}
+			return agent.sendMetrics()
 		},
 	}
 
 	var daemonCmd = &cobra.Command{
 		Use:   "daemon",
 		Short: "Run agent as daemon",
-		Run: func(cmd *cobra.Command, args []string) {
-			runDaemon()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := newAgent(cmd)
+			if err != nil {
+				return err
+			}
+			if once {
+				return agent.sendMetrics()
+			}
+			agent.runDaemon()
+			return nil
+		},
+	}
+
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Show the effective configuration and where it was read from",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, path, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				path = "none (defaults, environment and flags only)"
+			}
+			fmt.Printf("📄 Config file: %s\n", path)
+			data, _ := json.MarshalIndent(cfg.redacted(), "", "  ")
+			fmt.Println(string(data))
+			return nil
 		},
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVarP(&serverURL, "server", "s", defaultServerURL, "LiteMIDgo server URL")
-	rootCmd.PersistentFlags().IntVarP(&interval, "interval", "i", 60, "Collection interval in seconds")
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Show JSON payload being sent")
+	flags := rootCmd.PersistentFlags()
+	flags.StringVarP(&configFile, "config", "c", "", "config file (YAML or JSON)")
+	flags.StringSliceVarP(&serverURLs, "server", "s", nil, "LiteMIDgo server URL; repeat for failover servers (default http://localhost:8080)")
+	flags.IntVarP(&interval, "interval", "i", 60, "Collection interval in seconds")
+	flags.StringVar(&agentName, "agent-name", "litemidgo-agent", "ECC agent name")
+	flags.StringVar(&topic, "topic", "endpointData", "ECC topic")
+	flags.StringSliceVar(&tagFlags, "tag", nil, "tag sent with the metrics as key=value (repeatable)")
+	flags.StringSliceVar(&collectors, "collectors", allCollectors, "metric groups to collect")
+	flags.StringVar(&username, "username", "", "basic auth username; the password comes from the config, $LITEMIDGO_AGENT_PASSWORD or the credentials file")
+	flags.StringVar(&credentialsFile, "credentials-file", "", "YAML or JSON file with username, password, token or api_key")
+	flags.StringVar(&certFile, "cert", "", "client certificate file (PEM)")
+	flags.StringVar(&keyFile, "key", "", "client certificate key file (PEM)")
+	flags.StringVar(&caFile, "ca-file", "", "CA certificate file (PEM) for verifying the server")
+	flags.BoolVar(&debug, "debug", false, "Show JSON payload being sent")
 	daemonCmd.Flags().BoolVar(&once, "once", false, "Send metrics once and exit")
 
 	rootCmd.AddCommand(collectCmd)
 	rootCmd.AddCommand(sendCmd)
 	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(configCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
 	}
 }
 
-func sendMetrics() {
-	metrics, err := CollectSystemMetrics()
+// Agent sends metrics to the configured servers
+type Agent struct {
+	config   *AgentConfig
+	client   *http.Client
+	hostname string
+}
+
+func newAgent(cmd *cobra.Command) (*Agent, error) {
+	cfg, path, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Debug && path != "" {
+		fmt.Printf("📄 Using config file: %s\n", path)
+	}
+	client, err := cfg.httpClient()
 	if err != nil {
-		log.Fatalf("Failed to collect metrics: %v", err)
+		return nil, err
+	}
+	if cfg.Username != "" || cfg.Token != "" || cfg.APIKey != "" {
+		for _, serverURL := range cfg.ServerURLs {
+			if strings.HasPrefix(serverURL, "http://") && !isLoopback(serverURL) {
+				log.Printf("⚠️  Credentials are sent to %s over plain HTTP; use https://", serverURL)
+			}
+		}
 	}
 
 	hostname, _ := os.Hostname()
 	if hostname == "" {
 		hostname = "unknown"
 	}
+	return &Agent{config: cfg, client: client, hostname: hostname}, nil
+}
+
+// sendMetrics collects metrics and posts them to the first server that
+// accepts them
+func (a *Agent) sendMetrics() error {
+	metrics, err := CollectSystemMetrics(a.config)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	endpointMetrics := map[string]interface{}{
+		"hostname":         metrics.Hostname,
+		"collection_time":  time.Now().UTC().Format(time.RFC3339),
+		"agent_version":    "1.0.0",
+		"interval":         a.config.Interval,
+		"operating_system": metrics.OS,
+		"raw_timestamp":    metrics.Timestamp,
+	}
+	if metrics.CPU != nil {
+		endpointMetrics["cpu_metrics"] = metrics.CPU
+	}
+	if metrics.Memory != nil {
+		endpointMetrics["memory_metrics"] = metrics.Memory
+	}
+	if a.config.enabled("disk") {
+		endpointMetrics["disk_metrics"] = metrics.Disk
+	}
+	if metrics.Network != nil {
+		endpointMetrics["network_metrics"] = metrics.Network
+	}
+	if metrics.Runtime != nil {
+		endpointMetrics["runtime_metrics"] = metrics.Runtime
+	}
+	if len(a.config.Tags) > 0 {
+		endpointMetrics["tags"] = a.config.Tags
+	}
 
 	payload := Payload{
-		Agent:  "litemidgo-agent",
-		Topic:  "endpointData",
-		Name:   hostname,
-		Source: hostname,
-		Payload: map[string]interface{}{
-			"endpoint_metrics": map[string]interface{}{
-				"hostname":         metrics.Hostname,
-				"collection_time":  time.Now().UTC().Format(time.RFC3339),
-				"agent_version":    "1.0.0",
-				"operating_system": metrics.OS,
-				"cpu_metrics":      metrics.CPU,
-				"memory_metrics":   metrics.Memory,
-				"disk_metrics":     metrics.Disk,
-				"network_metrics":  metrics.Network,
-				"runtime_metrics":  metrics.Runtime,
-				"raw_timestamp":    metrics.Timestamp,
-			},
-		},
+		Agent:   a.config.AgentName,
+		Topic:   a.config.Topic,
+		Name:    a.hostname,
+		Source:  a.hostname,
+		Payload: map[string]interface{}{"endpoint_metrics": endpointMetrics},
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		log.Fatalf("Failed to marshal payload: %v", err)
+		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	apiURL := fmt.Sprintf("%s/proxy/ecc_queue", serverURL)
-
 	// Debug output - show formatted JSON
-	if debug {
+	if a.config.Debug {
 		prettyJSON, err := json.MarshalIndent(payload, "", "  ")
 		if err != nil {
 			fmt.Printf("Error formatting JSON: %v\n", err)
 		} else {
 			fmt.Printf("🔍 Debug - JSON payload being sent:\n")
 			fmt.Printf("%s\n", string(prettyJSON))
-			fmt.Printf("📡 Sending to: %s\n\n", apiURL)
+			fmt.Printf("📡 Sending to: %s\n\n", strings.Join(a.config.ServerURLs, ", "))
 		}
 	}
 
-	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(jsonData))
+	if len(a.config.ServerURLs) == 1 {
+		serverURL := a.config.ServerURLs[0]
+		if err := a.post(serverURL, jsonData); err != nil {
+			return fmt.Errorf("failed to send metrics to %s: %w", serverURL, err)
+		}
+		fmt.Printf("✅ Metrics sent successfully to %s\n", serverURL)
+		return nil
+	}
+
+	for _, serverURL := range a.config.ServerURLs {
+		if err := a.post(serverURL, jsonData); err != nil {
+			log.Printf("⚠️  %s: %v", serverURL, err)
+			continue
+		}
+		fmt.Printf("✅ Metrics sent successfully to %s\n", serverURL)
+		return nil
+	}
+	return fmt.Errorf("failed to send metrics to any of %d servers", len(a.config.ServerURLs))
+}
+
+func (a *Agent) post(serverURL string, body []byte) error {
+	apiURL := strings.TrimSuffix(serverURL, "/") + "/proxy/ecc_queue"
+	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	a.authenticate(req)
+
+	resp, err := a.client.Do(req)
 	if err != nil {
-		log.Fatalf("Failed to send metrics: %v", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Server returned status: %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusOK {
+		return nil
 	}
+	message := responseMessage(resp)
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		hint := "check the credentials"
+		if a.config.authMethods() == "no credentials" {
+			hint = "set username/password, token or api_key in the config, environment or credentials file"
+		}
+		if scheme := resp.Header.Get("WWW-Authenticate"); scheme != "" {
+			hint += "; server asks for " + scheme
+		}
+		return fmt.Errorf("authentication failed (401 %s) using %s: %s", message, a.config.authMethods(), hint)
+	case http.StatusForbidden:
+		return fmt.Errorf("access denied (403) using %s: %s", a.config.authMethods(), message)
+	default:
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, message)
+	}
+}
 
-	fmt.Printf("✅ Metrics sent successfully to %s\n", serverURL)
+// authenticate adds the configured credentials to a request
+func (a *Agent) authenticate(req *http.Request) {
+	if a.config.Username != "" {
+		req.SetBasicAuth(a.config.Username, a.config.Password)
+	}
+	if a.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.config.Token)
+	}
+	if a.config.APIKey != "" {
+		req.Header.Set(a.config.APIKeyHeader, a.config.APIKey)
+	}
 }
 
-func runDaemon() {
-	hostname, _ := os.Hostname()
-	if hostname == "" {
-		hostname = "unknown"
+// responseMessage is the message of an error response: the "message" field of
+// a LiteMIDgo JSON response, or the start of any other body
+func responseMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var result struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(body, &result) == nil {
+		if result.Message != "" {
+			return result.Message
+		}
+		if result.Error != "" {
+			return result.Error
+		}
+	}
+	text := strings.TrimSpace(string(body))
+	if len(text) > 200 {
+		text = text[:200] + "..."
 	}
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	return text
+}
 
-	fmt.Printf("🚀 Starting LiteMIDgo agent for %s\n", hostname)
-	fmt.Printf("📡 Sending metrics to %s every %d seconds\n", serverURL, interval)
+// isLoopback reports whether a server URL points at this host
+func isLoopback(serverURL string) bool {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+func (a *Agent) runDaemon() {
+	fmt.Printf("🚀 Starting LiteMIDgo agent for %s\n", a.hostname)
+	fmt.Printf("📡 Sending metrics to %s every %d seconds\n", strings.Join(a.config.ServerURLs, ", "), a.config.Interval)
 	fmt.Printf("🔄 Press Ctrl+C to stop\n\n")
 
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	ticker := time.NewTicker(time.Duration(a.config.Interval) * time.Second)
 	defer ticker.Stop()
 
 	// Send initial metrics
-	sendMetrics()
+	if err := a.sendMetrics(); err != nil {
+		log.Printf("❌ %v", err)
+	}
 
 	for range ticker.C {
-		sendMetrics()
+		if err := a.sendMetrics(); err != nil {
+			log.Printf("❌ %v", err)
+		}
 	}
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentsServerURL string
+	agentsStale     bool
+	agentsJSON      bool
+)
+
+var agentsCmd = &cobra.Command{
+	Use:   "agents",
+	Short: "List the agents known to the server",
+	Long: `List the agents that have posted records to the server, with their address,
+version, status, last record and reporting interval.
+
+The agent registry file (agents.registry_file) is read directly, so it also
+works while the server is stopped; statuses are then as of the last save.
+--server asks a running server instead.
+
+Examples:
+  litemidgo agents
+  litemidgo agents --stale
+  litemidgo agents --server http://localhost:8080 --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			fmt.Printf("❌ Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		var agents []server.AgentInfo
+		if agentsServerURL != "" {
+			agents, err = fetchAgents(cfg)
+		} else {
+			agents, err = server.ReadAgents(cfg.Agents.RegistryPath())
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to read agent registry: %v\n", err)
+			os.Exit(1)
+		}
+
+		if agentsStale {
+			var stale []server.AgentInfo
+			for _, agent := range agents {
+				if agent.Status == "stale" {
+					stale = append(stale, agent)
+				}
+			}
+			agents = stale
+		}
+
+		if agentsJSON {
+			if agents == nil {
+				agents = []server.AgentInfo{}
+			}
+			printJSON(agents)
+			return
+		}
+		if len(agents) == 0 {
+			fmt.Println("No agents")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "AGENT\tSOURCE\tADDRESS\tVERSION\tSTATUS\tLAST SEEN\tINTERVAL\tRECORDS\tFAILED\tLAST TOPIC")
+		for _, a := range agents {
+			status := "✅ " + a.Status
+			if a.Status == "stale" {
+				status = "⚠️  " + a.Status
+			}
+			interval := "-"
+			if a.Interval > 0 {
+				interval = fmt.Sprintf("%ds", a.Interval)
+			}
+			version := a.Version
+			if version == "" {
+				version = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s ago\t%s\t%d\t%d\t%s\n", a.Agent, a.Source, a.Address, version, status,
+				time.Since(a.LastSeen).Round(time.Second), interval, a.Records, a.Failed, a.LastTopic)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentsCmd)
+
+	flags := agentsCmd.Flags()
+	flags.StringVar(&agentsServerURL, "server", "", "ask a running LiteMIDgo server, e.g. http://localhost:8080")
+	flags.BoolVar(&agentsStale, "stale", false, "only agents that stopped reporting")
+	flags.BoolVar(&agentsJSON, "json", false, "print agents as JSON")
+}
+
+// fetchAgents gets the registry from GET /agents of a running server, using
+// the server auth credentials from the configuration when enabled
+func fetchAgents(cfg *config.Config) ([]server.AgentInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(agentsServerURL, "/")+"/agents", nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if cfg.Server.Auth.Enabled {
+		req.SetBasicAuth(cfg.Server.Auth.Username, cfg.Server.Auth.Password)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		Agents []server.AgentInfo `json:"agents"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return result.Agents, nil
+}
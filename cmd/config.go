@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"litemidgo/config"
@@ -10,6 +13,8 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 var configCmd = &cobra.Command{
@@ -23,9 +28,20 @@ Note: You can also set credentials using environment variables:
 - SERVICENOW_USERNAME: Your ServiceNow username  
 - SERVICENOW_PASSWORD: Your ServiceNow password
 
-Environment variables take precedence over config file settings.`,
+Environment variables take precedence over config file settings.
+
+The password may be a secret reference (file:, env:, exec: or vault:). When
+LITEMIDGO_MASTER_KEY is set, a plain password is stored in the encrypted vault
+instead of config.yaml (see 'litemidgo secret').
+
+With --profile (or LITEMIDGO_PROFILE) the settings are written to that profile,
+which is created if it does not exist yet. Other settings in the file are kept.
+
+For scripted setup, pass settings as flags or as JSON on stdin instead:
+  litemidgo config --instance acme.service-now.com --username svc --password-stdin < pw.txt
+  echo '{"servicenow":{"instance":"acme.service-now.com"},"server":{"port":9090}}' | litemidgo config --json`,
 	Run: func(cmd *cobra.Command, args []string) {
-		runConfigSetup()
+		runConfigSetup(cmd)
 	},
 }
 
@@ -39,14 +55,115 @@ that the credentials and network connectivity are working correctly.`,
 	},
 }
 
+// Non-interactive setup flags
+var (
+	setupInstance      string
+	setupUsername      string
+	setupPassword      string
+	setupPasswordStdin bool
+	setupHost          string
+	setupPort          int
+	setupUseHTTPS      bool
+	setupTimeout       int
+	setupJSON          bool
+)
+
+// setupFlagKeys maps setup flags to the settings they set
+var setupFlagKeys = map[string]string{
+	"instance":  "servicenow.instance",
+	"username":  "servicenow.username",
+	"password":  "servicenow.password",
+	"host":      "server.host",
+	"port":      "server.port",
+	"use-https": "servicenow.use_https",
+	"timeout":   "servicenow.timeout",
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configTestCmd)
+
+	flags := configCmd.Flags()
+	flags.StringVar(&setupInstance, "instance", "", "ServiceNow instance (skips the interactive setup)")
+	flags.StringVar(&setupUsername, "username", "", "ServiceNow username")
+	flags.StringVar(&setupPassword, "password", "", "ServiceNow password or secret reference")
+	flags.BoolVar(&setupPasswordStdin, "password-stdin", false, "read the ServiceNow password from stdin")
+	flags.StringVar(&setupHost, "host", "", "server listen host")
+	flags.IntVar(&setupPort, "port", 0, "server listen port")
+	flags.BoolVar(&setupUseHTTPS, "use-https", true, "connect to the instance over HTTPS")
+	flags.IntVar(&setupTimeout, "timeout", 0, "ServiceNow request timeout in seconds")
+	flags.BoolVar(&setupJSON, "json", false, `read settings as JSON from stdin, e.g. {"servicenow":{"instance":"..."}}`)
+}
+
+func runConfigSetup(cmd *cobra.Command) {
+	// Read the current settings (without resolving secrets). A profile that
+	// does not exist yet starts from the base settings.
+	profile := viper.GetString("profile")
+	if profile != "" && viper.Get("profiles."+profile) == nil {
+		viper.Set("profile", "")
+	}
+	current, err := config.ReadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	viper.Set("profile", profile)
+
+	// Any setup flag switches to non-interactive mode
+	nonInteractive := false
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if _, ok := setupFlagKeys[f.Name]; ok || f.Name == "json" || f.Name == "password-stdin" {
+			nonInteractive = true
+		}
+	})
+
+	var values map[string]interface{}
+	if nonInteractive {
+		values, err = setupValuesFromFlags(cmd)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		values = setupValuesFromWizard(profile, current)
+		if values == nil {
+			fmt.Println("Configuration cancelled.")
+			return
+		}
+	}
+
+	if err := saveSettings(profile, current, values); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println("You can now start the server with:")
+	fmt.Println("  litemidgo server")
+	fmt.Println()
+	fmt.Println("Or test the connection with:")
+	fmt.Println("  litemidgo config test")
 }
 
-func runConfigSetup() {
+// setupValuesFromWizard runs the interactive setup, prefilled with the
+// current settings. It returns nil when the user cancels.
+func setupValuesFromWizard(profile string, current *config.Config) map[string]interface{} {
+	useHTTPSAnswer := "n"
+	if current.ServiceNow.UseHTTPS {
+		useHTTPSAnswer = "y"
+	}
+	defaults := map[string]string{
+		"instance":  current.ServiceNow.Instance,
+		"username":  current.ServiceNow.Username,
+		"password":  current.ServiceNow.Password,
+		"host":      current.Server.Host,
+		"port":      strconv.Itoa(current.Server.Port),
+		"use_https": useHTTPSAnswer,
+		"timeout":   strconv.Itoa(current.ServiceNow.Timeout),
+	}
+
 	// Create and run the Bubble Tea configuration UI
-	model := ui.NewConfigModel()
+	model := ui.NewConfigModel(profile, defaults)
 	program := tea.NewProgram(model, tea.WithAltScreen())
 
 	finalModel, err := program.Run()
@@ -60,52 +177,107 @@ func runConfigSetup() {
 	answers := configModel.Answers
 
 	if len(answers) == 0 {
-		fmt.Println("Configuration cancelled.")
-		return
+		return nil
 	}
 
-	// Create config directory if it doesn't exist
-	configDir := "./config"
-	if _, err := os.Stat(configDir); os.IsNotExist(err) {
-		os.Mkdir(configDir, 0755)
+	return map[string]interface{}{
+		"server.host":          answers["host"],
+		"server.port":          answers["port"],
+		"servicenow.instance":  answers["instance"],
+		"servicenow.username":  answers["username"],
+		"servicenow.password":  answers["password"],
+		"servicenow.use_https": strings.ToLower(answers["use_https"]) == "y" || strings.ToLower(answers["use_https"]) == "yes",
+		"servicenow.timeout":   answers["timeout"],
 	}
+}
 
-	// Write configuration to file
-	configFile := configDir + "/config.yaml"
-	file, err := os.Create(configFile)
-	if err != nil {
-		fmt.Printf("❌ Failed to create config file: %v\n", err)
-		return
+// setupValuesFromFlags collects the settings given as flags and, with
+// --json, as a JSON object on stdin
+func setupValuesFromFlags(cmd *cobra.Command) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	if setupJSON {
+		if setupPasswordStdin {
+			return nil, fmt.Errorf("--json and --password-stdin both read stdin")
+		}
+		var input map[string]interface{}
+		if err := json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON settings: %w", err)
+		}
+		values = config.FlattenKeys(input)
 	}
-	defer file.Close()
 
-	// Parse boolean values
-	useHTTPS := strings.ToLower(answers["use_https"]) == "y" || strings.ToLower(answers["use_https"]) == "yes"
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if key, ok := setupFlagKeys[f.Name]; ok {
+			values[key] = f.Value.String()
+		}
+	})
 
-	configContent := fmt.Sprintf(`server:
-  host: "%s"
-  port: %s
+	if setupPasswordStdin {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		values["servicenow.password"] = strings.TrimRight(string(data), "\r\n")
+	}
 
-servicenow:
-  instance: "%s"
-  username: "%s"
-  password: "%s"
-  use_https: %t
-  timeout: %s
-`, answers["host"], answers["port"], answers["instance"], answers["username"], answers["password"], useHTTPS, answers["timeout"])
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no settings given")
+	}
+	return values, nil
+}
 
-	if _, err := file.WriteString(configContent); err != nil {
-		fmt.Printf("❌ Failed to write config file: %v\n", err)
-		return
+// saveSettings validates dotted-key settings against the config schema and
+// writes them to the config file (under profiles.<name> when editing a
+// profile), keeping everything else in the file. A plain-text password is
+// moved to the vault when a master key is set.
+func saveSettings(profile string, current *config.Config, values map[string]interface{}) error {
+	prefix := ""
+	if profile != "" {
+		prefix = "profiles." + profile + "."
 	}
 
-	fmt.Printf("✅ Configuration saved to %s\n", configFile)
-	fmt.Println()
-	fmt.Println("You can now start the server with:")
-	fmt.Println("  litemidgo server")
-	fmt.Println()
-	fmt.Println("Or test the connection with:")
-	fmt.Println("  litemidgo config test")
+	settings := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		coerced, err := config.CoerceValue(prefix+key, value)
+		if err != nil {
+			return err
+		}
+		settings[prefix+key] = coerced
+	}
+
+	// Keep the password out of the config file when a vault master key is set
+	passwordKey := prefix + "servicenow.password"
+	password, hasPassword := settings[passwordKey].(string)
+	if hasPassword && password != "" && !config.IsSecretRef(password) && os.Getenv("LITEMIDGO_MASTER_KEY") != "" {
+		secretName := strings.TrimPrefix(passwordKey, "profiles.")
+		vault, err := current.Secrets.OpenVault()
+		if err == nil {
+			err = vault.Set(secretName, password)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store password in vault: %w", err)
+		}
+		fmt.Printf("🔐 Password stored in %s\n", vault.Path())
+		settings[passwordKey] = "vault:" + secretName
+	}
+
+	// Update the config file in place, keeping other settings and profiles
+	configFile := config.FileUsed()
+	if err := config.SetFileValues(configFile, settings); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if profile != "" {
+		fmt.Printf("✅ Profile %s saved to %s\n", profile, configFile)
+	} else {
+		fmt.Printf("✅ Configuration saved to %s\n", configFile)
+	}
+	if hasPassword && password != "" && !config.IsSecretRef(settings[passwordKey].(string)) {
+		fmt.Println("⚠️  The password is stored in plain text. Set LITEMIDGO_MASTER_KEY to keep it in")
+		fmt.Println("   the encrypted vault, or use a file:, env: or exec: reference instead.")
+	}
+	return nil
 }
 
 func testConnection() {
@@ -117,7 +289,7 @@ func testConnection() {
 	}
 
 	// Create and run the Bubble Tea connection test UI
-	model := ui.NewConnectionTestModel(cfg.ServiceNow.Instance)
+	model := ui.NewConnectionTestModel(&cfg.ServiceNow)
 	program := tea.NewProgram(model, tea.WithAltScreen())
 
 	_, err = program.Run()
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"litemidgo/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var setValueStdin bool
+var getReveal bool
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Set a single setting in the config file",
+	Long: `Set a setting by its dotted key, e.g.
+
+  litemidgo config set server.port 9090
+  litemidgo config set snmp.communities public,private
+  litemidgo --profile prod config set servicenow.timeout 60
+  litemidgo config set servicenow.password --stdin < password.txt
+
+The value is checked against the setting's type. Other settings and comments in
+the file are kept. With --profile the setting is written to that profile.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var value string
+		switch {
+		case setValueStdin && len(args) == 1:
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Printf("❌ Failed to read value: %v\n", err)
+				os.Exit(1)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		case !setValueStdin && len(args) == 2:
+			value = args[1]
+		default:
+			fmt.Println("❌ Give either a value or --stdin")
+			os.Exit(1)
+		}
+
+		profile := ""
+		if cmd.Flags().Changed("profile") {
+			profile = viper.GetString("profile")
+		}
+		viper.Set("profile", "")
+		current, err := config.ReadConfig(cfgFile)
+		if err != nil {
+			fmt.Printf("❌ Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := saveSettings(profile, current, map[string]interface{}{args[0]: value}); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Long: `Print the effective value of a setting by its dotted key, after applying the
+active profile, environment variables and defaults. Secret references are shown
+as configured; plain-text secrets are redacted unless --reveal is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.CheckKey(args[0]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := config.ReadConfig(cfgFile); err != nil {
+			fmt.Printf("❌ Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		value := viper.Get(args[0])
+		if !getReveal {
+			value = config.Redact(args[0], value)
+		}
+
+		switch value := value.(type) {
+		case nil:
+		case map[string]interface{}, []interface{}:
+			data, err := yaml.Marshal(value)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(string(data))
+		default:
+			fmt.Println(value)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSetCmd, configGetCmd)
+	configSetCmd.Flags().BoolVar(&setValueStdin, "stdin", false, "read the value from stdin")
+	configGetCmd.Flags().BoolVar(&getReveal, "reveal", false, "print plain-text secrets")
+}
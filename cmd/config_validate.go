@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"litemidgo/config"
+
+	"github.com/spf13/cobra"
+)
+
+var validateStrict bool
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for errors and risky settings",
+	Long: `Check the effective configuration (config file, profile, .env and environment)
+without contacting ServiceNow: required settings, instance host name format,
+port ranges, timeout bounds, listener addresses, output targets, TLS files and
+whether secret references resolve. Warnings flag risky settings such as auth
+enabled with the default change-me password.
+
+Exits with status 1 when errors are found, or with --strict on warnings too.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfig(cfgFile)
+		if err != nil {
+			fmt.Printf("❌ Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		source := config.FileUsed()
+		if cfg.Profile != "" {
+			source += " (profile " + cfg.Profile + ")"
+		}
+		fmt.Printf("🔍 Validating %s\n\n", source)
+
+		problems := cfg.Check()
+		if err := cfg.ResolveSecrets(); err != nil {
+			problems = append([]config.Problem{{Key: "secrets", Message: err.Error()}}, problems...)
+		}
+
+		errors, warnings := 0, 0
+		for _, problem := range problems {
+			if problem.Warning {
+				warnings++
+				fmt.Printf("⚠️  %s\n", problem)
+			} else {
+				errors++
+				fmt.Printf("❌ %s\n", problem)
+			}
+		}
+		if len(problems) > 0 {
+			fmt.Println()
+		}
+
+		switch {
+		case errors > 0:
+			fmt.Printf("❌ Configuration has %d error(s) and %d warning(s)\n", errors, warnings)
+			os.Exit(1)
+		case warnings > 0 && validateStrict:
+			fmt.Printf("❌ Configuration has %d warning(s) (--strict)\n", warnings)
+			os.Exit(1)
+		case warnings > 0:
+			fmt.Printf("✅ Configuration is valid with %d warning(s)\n", warnings)
+		default:
+			fmt.Println("✅ Configuration is valid")
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each setting comes from",
+	Long: `Print every effective setting after merging defaults, the config file, the
+active profile, .env and environment variables, with the source of each value.
+Plain-text secrets are redacted; secret references are shown as configured.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfig(cfgFile)
+		if err != nil {
+			fmt.Printf("❌ Failed to load configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Config file: %s\n", config.FileUsed())
+		if cfg.Profile != "" {
+			fmt.Printf("Profile:     %s\n", cfg.Profile)
+		}
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, setting := range config.EffectiveSettings() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, formatSetting(config.Redact(setting.Key, setting.Value)), setting.Source)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd, configShowCmd)
+	configValidateCmd.Flags().BoolVar(&validateStrict, "strict", false, "treat warnings as errors")
+}
+
+// formatSetting prints scalars as is and lists or maps as compact JSON
+func formatSetting(value interface{}) string {
+	switch value.(type) {
+	case []interface{}, []string, map[string]interface{}:
+		data, err := json.Marshal(value)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
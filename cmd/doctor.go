@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorJSON bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose connectivity and permissions for the ServiceNow instance",
+	Long: `Step through everything LiteMIDgo needs from the ServiceNow instance and report
+what works and what to fix:
+
+  DNS resolution, TCP connect, TLS handshake and certificate expiry,
+  authentication, read access to sys_user, write access to ecc_queue
+  (a probe record is inserted and deleted) and clock skew.
+
+When a proxy is configured, DNS and TCP are checked against the proxy.
+Exits with status 1 if any check fails. Use --json for machine-readable output.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDoctor()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "print the report as JSON")
+}
+
+type doctorReport struct {
+	Instance       string                       `json:"instance"`
+	ConnectionPath string                       `json:"connection_path,omitempty"`
+	OK             bool                         `json:"ok"`
+	Error          string                       `json:"error,omitempty"`
+	Checks         []servicenow.DiagnosticCheck `json:"checks"`
+}
+
+func runDoctor() {
+	report := doctorReport{}
+
+	cfg, err := config.LoadConfig(cfgFile)
+	if err == nil {
+		report.Instance = cfg.ServiceNow.Instance
+		err = cfg.Validate()
+	}
+	var client *servicenow.Client
+	if err == nil {
+		client, err = servicenow.NewClient(&cfg.ServiceNow)
+	}
+	if err != nil {
+		report.Error = err.Error()
+		printDoctorReport(report)
+		os.Exit(1)
+	}
+
+	report.Instance = client.GetInstanceURL()
+	report.ConnectionPath = client.ConnectionPath().String()
+	if !doctorJSON {
+		fmt.Printf("🩺 Checking %s\n", report.Instance)
+		fmt.Printf("   Connection path: %s\n\n", report.ConnectionPath)
+	}
+
+	report.Checks = client.Diagnose(context.Background())
+	report.OK = true
+	for _, check := range report.Checks {
+		if check.Status == servicenow.CheckFail {
+			report.OK = false
+		}
+	}
+
+	printDoctorReport(report)
+	if !report.OK {
+		os.Exit(1)
+	}
+}
+
+func printDoctorReport(report doctorReport) {
+	if doctorJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if report.Error != "" {
+		fmt.Printf("❌ Configuration: %s\n", report.Error)
+		fmt.Println("   💡 Run 'litemidgo config validate' for details")
+		return
+	}
+
+	icons := map[string]string{
+		servicenow.CheckPass: "✅",
+		servicenow.CheckWarn: "⚠️ ",
+		servicenow.CheckFail: "❌",
+		servicenow.CheckSkip: "⏭️ ",
+	}
+	counts := make(map[string]int)
+	for _, check := range report.Checks {
+		counts[check.Status]++
+		fmt.Printf("%s %-26s %s", icons[check.Status], check.Name, check.Detail)
+		if check.Status != servicenow.CheckSkip {
+			fmt.Printf(" (%dms)", check.DurationMS)
+		}
+		fmt.Println()
+		if check.Hint != "" && check.Status != servicenow.CheckPass {
+			fmt.Printf("   💡 %s\n", check.Hint)
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d warning(s), %d failed, %d skipped\n",
+		counts[servicenow.CheckPass], counts[servicenow.CheckWarn], counts[servicenow.CheckFail], counts[servicenow.CheckSkip])
+}
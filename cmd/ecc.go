@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var eccCmd = &cobra.Command{
+	Use:   "ecc",
+	Short: "Send and inspect ECC queue records",
+}
+
+var (
+	eccAgent     string
+	eccTopic     string
+	eccName      string
+	eccSource    string
+	eccFile      string
+	eccServerURL string
+	eccJSON      bool
+)
+
+var eccSendCmd = &cobra.Command{
+	Use:   "send [key=value ...]",
+	Short: "Send an ad-hoc record to the ECC queue",
+	Long: `Build an ECC queue record from flags and a payload, and send it directly to
+ServiceNow or through a running LiteMIDgo server (--server).
+
+The payload comes from --file (JSON is sent as an object, anything else such as
+XML as a string; use - for stdin), from stdin when it is piped, or from
+key=value arguments. key=value pairs are added to a JSON object payload.
+
+Examples:
+  litemidgo ecc send --topic MIDServer --name test message="Hello" host=web01
+  litemidgo ecc send --topic SensorTest --file payload.json
+  cat probe.xml | litemidgo ecc send --topic Probe --name discovery
+  litemidgo ecc send --server http://localhost:8080 status=ok
+
+Direct sends print the created record including sys_id and state; through a
+server only the sys_id is returned.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runECCSend(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eccCmd)
+	eccCmd.AddCommand(eccSendCmd)
+
+	flags := eccSendCmd.Flags()
+	flags.StringVar(&eccAgent, "agent", "litemidgo", "ECC agent")
+	flags.StringVar(&eccTopic, "topic", "endpointData", "ECC topic")
+	flags.StringVar(&eccName, "name", "default", "ECC record name")
+	flags.StringVar(&eccSource, "source", "", "ECC source (default: this host name)")
+	flags.StringVarP(&eccFile, "file", "f", "", "read the payload from a file (- for stdin)")
+	flags.StringVar(&eccServerURL, "server", "", "send through a running LiteMIDgo server, e.g. http://localhost:8080")
+	flags.BoolVar(&eccJSON, "json", false, "print the response as JSON")
+}
+
+func runECCSend(args []string) {
+	payload, err := buildECCPayload(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	source := eccSource
+	if source == "" {
+		if source, err = os.Hostname(); err != nil {
+			source = "litemidgo-cli"
+		}
+	}
+	record := &servicenow.ECCQueuePayload{
+		Agent:   eccAgent,
+		Topic:   eccTopic,
+		Name:    eccName,
+		Source:  source,
+		Payload: payload,
+	}
+
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if eccServerURL != "" {
+		sendECCViaServer(cfg, record)
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("❌ Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	client, err := servicenow.NewClient(&cfg.ServiceNow)
+	if err != nil {
+		fmt.Printf("❌ Failed to create ServiceNow client: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := client.SendToECCQueue(record)
+	if err != nil {
+		fmt.Printf("❌ Failed to send record: %v\n", err)
+		os.Exit(1)
+	}
+
+	if eccJSON {
+		printJSON(resp.Result)
+		return
+	}
+	result := resp.Result
+	fmt.Printf("✅ Record created in %s ecc_queue\n", client.GetInstanceURL())
+	fmt.Printf("   sys_id:  %s\n", result.SysID)
+	fmt.Printf("   state:   %s\n", result.State)
+	fmt.Printf("   queue:   %s\n", result.Queue)
+	fmt.Printf("   agent:   %s\n", result.Agent)
+	fmt.Printf("   topic:   %s\n", result.Topic)
+	fmt.Printf("   name:    %s\n", result.Name)
+	fmt.Printf("   source:  %s\n", result.Source)
+	fmt.Printf("   created: %s\n", result.SysCreatedOn)
+}
+
+// buildECCPayload reads the payload from --file, piped stdin or key=value
+// arguments. Pairs are merged into a JSON object payload.
+func buildECCPayload(args []string) (interface{}, error) {
+	var payload interface{}
+
+	var data []byte
+	var err error
+	switch {
+	case eccFile == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case eccFile != "":
+		data, err = os.ReadFile(eccFile)
+	case len(args) == 0 && !term.IsTerminal(os.Stdin.Fd()):
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		if err := json.Unmarshal([]byte(text), &payload); err != nil {
+			if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+				return nil, fmt.Errorf("payload is not valid JSON: %w", err)
+			}
+			payload = text
+		}
+	}
+
+	if len(args) > 0 {
+		fields, ok := payload.(map[string]interface{})
+		if payload != nil && !ok {
+			return nil, fmt.Errorf("key=value pairs need a JSON object payload")
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		for _, arg := range args {
+			key, value, found := strings.Cut(arg, "=")
+			if !found || key == "" {
+				return nil, fmt.Errorf("invalid argument %q, expected key=value", arg)
+			}
+			fields[key] = value
+		}
+		payload = fields
+	}
+
+	if payload == nil {
+		return nil, fmt.Errorf("no payload given (use --file, stdin or key=value pairs)")
+	}
+	return payload, nil
+}
+
+// sendECCViaServer posts the record to /proxy/ecc_queue of a running server,
+// using the server auth credentials from the configuration when enabled
+func sendECCViaServer(cfg *config.Config, record *servicenow.ECCQueuePayload) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("❌ Failed to encode record: %v\n", err)
+		os.Exit(1)
+	}
+
+	endpoint := strings.TrimSuffix(eccServerURL, "/") + "/proxy/ecc_queue"
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		fmt.Printf("❌ Invalid server URL: %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Server.Auth.Enabled {
+		req.SetBasicAuth(cfg.Server.Auth.Username, cfg.Server.Auth.Password)
+	}
+
+	client := &http.Client{Timeout: time.Duration(cfg.ServiceNow.Timeout+5) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Printf("❌ Failed to reach server: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var result struct {
+		Success   bool   `json:"success"`
+		Message   string `json:"message"`
+		SysID     string `json:"sys_id"`
+		Timestamp string `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		fmt.Printf("❌ Server returned HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(data)))
+		os.Exit(1)
+	}
+
+	if eccJSON {
+		printJSON(result)
+	} else if result.Success {
+		fmt.Printf("✅ %s (via %s)\n", result.Message, eccServerURL)
+		if result.SysID != "" {
+			fmt.Printf("   sys_id:  %s\n", result.SysID)
+		}
+	} else {
+		fmt.Printf("❌ Server returned HTTP %d: %s\n", resp.StatusCode, result.Message)
+	}
+	if !result.Success {
+		os.Exit(1)
+	}
+}
+
+func printJSON(value interface{}) {
+	data, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Println(string(data))
+}
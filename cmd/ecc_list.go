@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+
+	"github.com/spf13/cobra"
+)
+
+const eccPageSize = 100
+
+var (
+	eccFilter   servicenow.ECCFilter
+	eccSince    string
+	eccUntil    string
+	eccLimit    int
+	eccOffset   int
+	eccInterval time.Duration
+)
+
+var eccListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List ecc_queue records, newest first",
+	Long: `List ecc_queue records through the Table API, newest first. Filters are
+combined into an encoded query; --query adds raw encoded query conditions.
+
+--since and --until take a duration (15m, 2h) or a UTC time (2006-01-02,
+"2006-01-02 15:04:05" or RFC 3339) and bound sys_created_on.
+
+Examples:
+  litemidgo ecc list --topic MIDServer --since 1h
+  litemidgo ecc list --agent litemidgo --state error --limit 100 --json
+  litemidgo ecc list --query "nameLIKEdisk"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := eccClient()
+		query := eccQuery(false, "ORDERBYDESCsys_created_on")
+
+		var records []servicenow.ECCRecord
+		offset := eccOffset
+		for eccLimit <= 0 || len(records) < eccLimit {
+			size := eccPageSize
+			if eccLimit > 0 && eccLimit-len(records) < size {
+				size = eccLimit - len(records)
+			}
+			page, err := client.ListECCRecords(context.Background(), query, offset, size)
+			if err != nil {
+				fmt.Printf("❌ Failed to list records: %v\n", err)
+				os.Exit(1)
+			}
+			records = append(records, page...)
+			offset += len(page)
+			if len(page) < size {
+				break
+			}
+		}
+
+		if eccJSON {
+			if records == nil {
+				records = []servicenow.ECCRecord{}
+			}
+			printJSON(records)
+			return
+		}
+		if len(records) == 0 {
+			fmt.Println("No matching records")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SYS_ID\tCREATED\tQUEUE\tSTATE\tAGENT\tTOPIC\tNAME\tSOURCE")
+		for _, r := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.SysID, r.SysCreatedOn, r.Queue, r.State, r.Agent, r.Topic, r.Name, r.Source)
+		}
+		w.Flush()
+	},
+}
+
+var eccGetCmd = &cobra.Command{
+	Use:   "get <sys_id>",
+	Short: "Show one ecc_queue record including its payload",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		record, err := eccClient().GetECCRecord(context.Background(), args[0])
+		if err != nil {
+			fmt.Printf("❌ Failed to get record %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		if eccJSON {
+			printJSON(record)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, field := range [][2]string{
+			{"sys_id", record.SysID},
+			{"queue", record.Queue},
+			{"state", record.State},
+			{"agent", record.Agent},
+			{"topic", record.Topic},
+			{"name", record.Name},
+			{"source", record.Source},
+			{"response_to", record.ResponseTo},
+			{"error_string", record.ErrorString},
+			{"processed", record.Processed},
+			{"created", record.SysCreatedOn + " by " + record.SysCreatedBy},
+			{"updated", record.SysUpdatedOn + " by " + record.SysUpdatedBy},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(w, "%s:\t%s\n", field[0], field[1])
+			}
+		}
+		w.Flush()
+
+		fmt.Println("\npayload:")
+		fmt.Println(formatECCPayload(record.Payload))
+	},
+}
+
+var eccTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Follow new ecc_queue records and state changes",
+	Long: `Poll ecc_queue and print records as they are created and when their state
+changes (e.g. ready → processing → processed), until interrupted. The list
+filters apply; --since also prints recent records first.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		runECCTail(ctx, eccClient())
+	},
+}
+
+func init() {
+	eccCmd.AddCommand(eccListCmd, eccGetCmd, eccTailCmd)
+
+	for _, c := range []*cobra.Command{eccListCmd, eccTailCmd} {
+		flags := c.Flags()
+		flags.StringVar(&eccFilter.Agent, "agent", "", "only records from this agent")
+		flags.StringVar(&eccFilter.Topic, "topic", "", "only records with this topic")
+		flags.StringVar(&eccFilter.Name, "name", "", "only records with this name")
+		flags.StringVar(&eccFilter.Source, "source", "", "only records from this source")
+		flags.StringVar(&eccFilter.Queue, "queue", "", "only the input or output queue")
+		flags.StringVar(&eccFilter.State, "state", "", "only records in this state (ready, processing, processed, error)")
+		flags.StringVar(&eccFilter.Query, "query", "", "extra encoded query conditions")
+		flags.StringVar(&eccSince, "since", "", "only records since a duration ago or a UTC time")
+		flags.BoolVar(&eccJSON, "json", false, "print records as JSON")
+	}
+	eccListCmd.Flags().StringVar(&eccUntil, "until", "", "only records before a duration ago or a UTC time")
+	eccListCmd.Flags().IntVar(&eccLimit, "limit", 20, "maximum number of records (0 for all)")
+	eccListCmd.Flags().IntVar(&eccOffset, "offset", 0, "skip this many records")
+	eccTailCmd.Flags().DurationVar(&eccInterval, "interval", 2*time.Second, "poll interval")
+	eccGetCmd.Flags().BoolVar(&eccJSON, "json", false, "print the record as JSON")
+}
+
+// eccClient loads the configuration and creates a ServiceNow client, exiting
+// on errors
+func eccClient() *servicenow.Client {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("❌ Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	client, err := servicenow.NewClient(&cfg.ServiceNow)
+	if err != nil {
+		fmt.Printf("❌ Failed to create ServiceNow client: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// eccQuery applies --since and --until to the filter flags and encodes them
+func eccQuery(updated bool, order string) string {
+	var err error
+	if eccFilter.Since, err = parseECCTime(eccSince); err != nil {
+		fmt.Printf("❌ Invalid --since: %v\n", err)
+		os.Exit(1)
+	}
+	if eccFilter.Until, err = parseECCTime(eccUntil); err != nil {
+		fmt.Printf("❌ Invalid --until: %v\n", err)
+		os.Exit(1)
+	}
+	eccFilter.Updated = updated
+	return eccFilter.Encode(order)
+}
+
+// parseECCTime accepts a duration before now or a UTC time
+func parseECCTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, servicenow.ECCTimeFormat, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration nor a time", value)
+}
+
+// formatECCPayload indents JSON payloads and prints anything else as is
+func formatECCPayload(payload string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(payload), &value); err == nil {
+		if data, err := json.MarshalIndent(value, "", "  "); err == nil {
+			return string(data)
+		}
+	}
+	return payload
+}
+
+// eccTailLookback is how far back the first tail poll looks, so records are
+// not missed when the local clock is ahead of the instance. Records found by
+// that poll are only printed when --since asks for them.
+const eccTailLookback = 5 * time.Minute
+
+func runECCTail(ctx context.Context, client *servicenow.Client) {
+	printRecent := eccSince != ""
+	if !printRecent {
+		eccSince = eccTailLookback.String()
+	}
+	eccQuery(true, "")
+	since := eccFilter.Since
+
+	if !eccJSON {
+		fmt.Printf("👀 Following ecc_queue on %s (Ctrl+C to stop)\n", client.GetInstanceURL())
+	}
+
+	// Last seen state and update count per record, to report changes only
+	type seenRecord struct{ state, modCount string }
+	seen := make(map[string]seenRecord)
+	first := true
+
+	for {
+		eccFilter.Since = since
+		query := eccFilter.Encode("ORDERBYsys_updated_on")
+		for offset := 0; ; offset += eccPageSize {
+			page, err := client.ListECCRecords(ctx, query, offset, eccPageSize)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "⚠️  Poll failed: %v\n", err)
+				break
+			}
+			for _, r := range page {
+				previous, known := seen[r.SysID]
+				seen[r.SysID] = seenRecord{state: r.State, modCount: r.SysModCount}
+				if updated, err := time.Parse(servicenow.ECCTimeFormat, r.SysUpdatedOn); err == nil && updated.After(since) {
+					since = updated
+				}
+
+				event := ""
+				switch {
+				case !known:
+					event = "new"
+				case previous.state != r.State:
+					event = "state"
+				case previous.modCount != r.SysModCount:
+					event = "updated"
+				}
+				if event != "" && (!first || printRecent) {
+					printECCTailEvent(event, previous.state, r)
+				}
+			}
+			if len(page) < eccPageSize {
+				break
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eccInterval):
+		}
+	}
+}
+
+// printECCTailEvent prints a new record, a state change or another update as
+// one line, or as one JSON object per line with --json
+func printECCTailEvent(event, previousState string, r servicenow.ECCRecord) {
+	if eccJSON {
+		data, _ := json.Marshal(struct {
+			Event         string               `json:"event"`
+			PreviousState string               `json:"previous_state,omitempty"`
+			Record        servicenow.ECCRecord `json:"record"`
+		}{event, previousState, r})
+		fmt.Println(string(data))
+		return
+	}
+
+	label := event + " (" + r.State + ")"
+	if event == "state" {
+		label = previousState + " → " + r.State
+	}
+	fmt.Printf("%s  %-24s %-6s %s %s %s %s\n", r.SysUpdatedOn, label, r.Queue, r.Agent, r.Topic, r.Name, r.SysID)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"litemidgo/mockinstance"
+
+	"github.com/spf13/cobra"
+)
+
+var mockOpts mockinstance.Options
+var mockAddr, mockTLSCert, mockTLSKey string
+
+var mockInstanceCmd = &cobra.Command{
+	Use:   "mock-instance",
+	Short: "Run a mock ServiceNow instance for local development",
+	Long: `Run a local stand-in for a ServiceNow instance that serves the APIs LiteMIDgo
+uses (Table API including sys_user and ecc_queue, import sets, Event Management,
+attachments and IRE). Records are kept in memory, or on disk with --data-dir.
+
+Point LiteMIDgo at it with, for example:
+  servicenow.instance: localhost:8081
+  servicenow.use_https: false
+  servicenow.username: admin
+  servicenow.password: admin
+
+Latency, rate limiting (429) and server errors (500/503) can be injected to test
+how clients cope with a slow or failing instance.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runMockInstance()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockInstanceCmd)
+
+	flags := mockInstanceCmd.Flags()
+	flags.StringVar(&mockAddr, "addr", "127.0.0.1:8081", "address to listen on")
+	flags.StringVar(&mockOpts.Username, "username", "admin", "basic auth user name")
+	flags.StringVar(&mockOpts.Password, "password", "admin", "basic auth password")
+	flags.StringVar(&mockOpts.ClientID, "client-id", "", "OAuth client ID (enables /oauth_token.do)")
+	flags.StringVar(&mockOpts.ClientSecret, "client-secret", "", "OAuth client secret")
+	flags.StringVar(&mockOpts.DataDir, "data-dir", "", "persist records to this directory instead of memory")
+	flags.DurationVar(&mockOpts.Latency, "latency", 0, "latency added to every request (e.g. 200ms)")
+	flags.DurationVar(&mockOpts.Jitter, "jitter", 0, "random extra latency up to this duration")
+	flags.Float64Var(&mockOpts.RateLimitRate, "rate-limit-rate", 0, "fraction of requests answered with 429 (0-1)")
+	flags.Float64Var(&mockOpts.ErrorRate, "error-rate", 0, "fraction of requests answered with 500/503 (0-1)")
+	flags.IntVar(&mockOpts.RetryAfter, "retry-after", 1, "Retry-After seconds sent with 429 responses")
+	flags.StringVar(&mockTLSCert, "tls-cert", "", "serve HTTPS with this certificate")
+	flags.StringVar(&mockTLSKey, "tls-key", "", "private key for --tls-cert")
+}
+
+func runMockInstance() {
+	mockOpts.Logger = log.New(os.Stderr, "mock-instance: ", log.LstdFlags)
+
+	instance, err := mockinstance.New(mockOpts)
+	if err != nil {
+		log.Fatalf("Failed to start mock instance: %v", err)
+	}
+
+	srv := &http.Server{
+		Addr:              mockAddr,
+		Handler:           instance,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if mockTLSCert != "" {
+			err = srv.ListenAndServeTLS(mockTLSCert, mockTLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Mock instance failed: %v", err)
+		}
+	}()
+
+	storage := "memory"
+	if mockOpts.DataDir != "" {
+		storage = mockOpts.DataDir
+	}
+	log.Printf("🧪 Mock ServiceNow instance listening on %s (user %s, storage %s)", mockAddr, mockOpts.Username, storage)
+	if mockOpts.Latency > 0 || mockOpts.RateLimitRate > 0 || mockOpts.ErrorRate > 0 {
+		log.Printf("   Injecting latency %s (+%s jitter), %.0f%% 429s, %.0f%% 5xx",
+			mockOpts.Latency, mockOpts.Jitter, mockOpts.RateLimitRate*100, mockOpts.ErrorRate*100)
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down mock instance...")
+	srv.Close()
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"litemidgo/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List, select and inspect configuration profiles",
+	Long: `Profiles are named sets of settings in the config file that are applied on
+top of the base settings, e.g. one per ServiceNow instance:
+
+  profile: dev
+  profiles:
+    dev:
+      servicenow:
+        instance: dev12345.service-now.com
+    prod:
+      servicenow:
+        instance: acme.service-now.com
+        password: vault:prod.servicenow.password
+
+The active profile is chosen by --profile, then LITEMIDGO_PROFILE, then the
+profile key in the file.`,
+}
+
+var configProfilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles and mark the active one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := readProfileConfig("")
+		profiles := config.Profiles()
+		if len(profiles) == 0 {
+			fmt.Printf("No profiles defined in %s\n", config.FileUsed())
+			return
+		}
+		for _, name := range profiles {
+			marker := " "
+			if name == cfg.Profile {
+				marker = "*"
+			}
+			instance := viper.GetString("profiles." + name + ".servicenow.instance")
+			if instance == "" {
+				instance = "(base instance)"
+			}
+			fmt.Printf("%s %-20s %s\n", marker, name, instance)
+		}
+	},
+}
+
+var configProfilesUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Make a profile the default in the config file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ignore the currently selected profile so a broken one can be replaced
+		viper.Set("profile", "")
+		readProfileConfig("")
+
+		if viper.Get("profiles."+args[0]) == nil {
+			fmt.Printf("❌ Profile %q not found in %s\n", args[0], config.FileUsed())
+			os.Exit(1)
+		}
+		if err := config.SetFileValues(config.FileUsed(), map[string]interface{}{"profile": args[0]}); err != nil {
+			fmt.Printf("❌ Failed to update config file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Now using profile %s (%s)\n", args[0], config.FileUsed())
+		if env := os.Getenv("LITEMIDGO_PROFILE"); env != "" && env != args[0] {
+			fmt.Printf("⚠️  LITEMIDGO_PROFILE=%s still takes precedence in this shell\n", env)
+		}
+	},
+}
+
+var configProfilesShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "Show the effective settings of a profile (default: the active one)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		cfg := readProfileConfig(name)
+
+		profile := cfg.Profile
+		if profile == "" {
+			profile = "(none, base settings)"
+		}
+		fmt.Printf("Profile:     %s\n", profile)
+		fmt.Printf("Config file: %s\n", config.FileUsed())
+		fmt.Println()
+		fmt.Printf("servicenow.instance:  %s\n", cfg.ServiceNow.Instance)
+		fmt.Printf("servicenow.username:  %s\n", cfg.ServiceNow.Username)
+		fmt.Printf("servicenow.password:  %s\n", config.Redact("servicenow.password", cfg.ServiceNow.Password))
+		fmt.Printf("servicenow.use_https: %t\n", cfg.ServiceNow.UseHTTPS)
+		fmt.Printf("servicenow.timeout:   %d\n", cfg.ServiceNow.Timeout)
+		if cfg.ServiceNow.Proxy.URL != "" {
+			fmt.Printf("servicenow.proxy.url: %s\n", cfg.ServiceNow.Proxy.URL)
+		}
+		fmt.Printf("server.host:          %s\n", cfg.Server.Host)
+		fmt.Printf("server.port:          %d\n", cfg.Server.Port)
+		fmt.Printf("server.auth.enabled:  %t\n", cfg.Server.Auth.Enabled)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configProfilesCmd)
+	configProfilesCmd.AddCommand(configProfilesListCmd, configProfilesUseCmd, configProfilesShowCmd)
+}
+
+// readProfileConfig reads the configuration without resolving secrets,
+// selecting the named profile when one is given
+func readProfileConfig(profile string) *config.Config {
+	if profile != "" {
+		viper.Set("profile", profile)
+	}
+	cfg, err := config.ReadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"litemidgo/config"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets in the encrypted local vault",
+	Long: `Store credentials in an encrypted local vault so config files can reference
+them instead of holding them in plain text:
+
+  servicenow:
+    password: vault:servicenow.password
+
+The vault (default $HOME/.litemidgo/vault.json, or secrets.vault_file) is
+encrypted with a key derived from the master key in LITEMIDGO_MASTER_KEY or
+secrets.master_key_file. When neither is set you are prompted for it.
+
+Credential settings also accept file:<path>, env:<VAR> and exec:<command>
+references.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret, reading the value from stdin or a prompt",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vault := openVault()
+		value, err := readSecretValue(fmt.Sprintf("Value for %s: ", args[0]))
+		if err != nil {
+			fmt.Printf("❌ Failed to read secret: %v\n", err)
+			os.Exit(1)
+		}
+		if err := vault.Set(args[0], value); err != nil {
+			fmt.Printf("❌ Failed to store secret: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Stored %s in %s\n", args[0], vault.Path())
+		fmt.Printf("   Reference it as: vault:%s\n", args[0])
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of stored secrets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		vault := openVault()
+		for _, name := range vault.Names() {
+			fmt.Println(name)
+		}
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a secret from the vault",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vault := openVault()
+		if err := vault.Delete(args[0]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Deleted %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd, secretListCmd, secretDeleteCmd)
+}
+
+// openVault opens the vault configured for the current config file, asking
+// for the master key on the terminal when it is not configured
+func openVault() *config.Vault {
+	cfg, err := config.ReadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if os.Getenv("LITEMIDGO_MASTER_KEY") == "" && cfg.Secrets.MasterKeyFile == "" && term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Print("Master key: ")
+		key, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		if err != nil {
+			fmt.Printf("❌ Failed to read master key: %v\n", err)
+			os.Exit(1)
+		}
+		os.Setenv("LITEMIDGO_MASTER_KEY", string(key))
+	}
+
+	vault, err := cfg.Secrets.OpenVault()
+	if err != nil {
+		fmt.Printf("❌ Failed to open vault: %v\n", err)
+		os.Exit(1)
+	}
+	return vault
+}
+
+// readSecretValue prompts without echo on a terminal, otherwise it reads
+// stdin up to EOF
+func readSecretValue(prompt string) (string, error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Print(prompt)
+		value, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		return string(value), err
+	}
+	data, err := io.ReadAll(os.Stdin)
+	return strings.TrimRight(string(data), "\r\n"), err
+}
@@ -42,7 +42,10 @@ func startServerSimple() {
 	log.Printf("ServiceNow instance: %s", cfg.ServiceNow.Instance)
 
 	// Create and start server
-	srv := server.NewServer(cfg)
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
 
 	// Setup graceful shutdown
 	go func() {
@@ -1,40 +1,325 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server     ServerConfig     `mapstructure:"server"`
-	ServiceNow ServiceNowConfig `mapstructure:"servicenow"`
+	Profile      string             `mapstructure:"profile"`
+	Server       ServerConfig       `mapstructure:"server"`
+	ServiceNow   ServiceNowConfig   `mapstructure:"servicenow"`
+	Syslog       SyslogConfig       `mapstructure:"syslog"`
+	SNMP         SNMPConfig         `mapstructure:"snmp"`
+	Sensu        SensuConfig        `mapstructure:"sensu"`
+	Alertmanager AlertmanagerConfig `mapstructure:"alertmanager"`
+	Events       EventsConfig       `mapstructure:"events"`
+	CMDB         CMDBConfig         `mapstructure:"cmdb"`
+	Attachments  AttachmentsConfig  `mapstructure:"attachments"`
+	Agents       AgentsConfig       `mapstructure:"agents"`
+	Secrets      SecretsConfig      `mapstructure:"secrets"`
 }
 
 type ServerConfig struct {
-	Host string     `mapstructure:"host"`
-	Port int        `mapstructure:"port"`
-	Auth AuthConfig `mapstructure:"auth"`
+	Host   string       `mapstructure:"host"`
+	Port   int          `mapstructure:"port"`
+	Auth   AuthConfig   `mapstructure:"auth"`
+	Stream StreamConfig `mapstructure:"stream"`
+	Web    WebConfig    `mapstructure:"web"`
 }
 
+// WebConfig controls the browser dashboard served under /ui/.
+type WebConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// StreamConfig controls the NDJSON streaming ingest endpoint.
+type StreamConfig struct {
+	Concurrency  int `mapstructure:"concurrency"`
+	MaxLineBytes int `mapstructure:"max_line_bytes"`
+}
+
+// AuthConfig holds the basic authentication credentials. Username and
+// Password may use every protected endpoint; Clients may only send ECC
+// records, restricted by their policies.
 type AuthConfig struct {
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	Enabled  bool   `mapstructure:"enabled"`
+	Username string         `mapstructure:"username"`
+	Password string         `mapstructure:"password"`
+	Enabled  bool           `mapstructure:"enabled"`
+	Clients  []ClientConfig `mapstructure:"clients"`
+}
+
+// ClientConfig binds a credential to the records it may send. Topics, Agents
+// and Sources are glob patterns (e.g. "web-*"); an empty list allows any
+// value. Identity is the verified source of the client and defaults to
+// Username. SourceMode "enforce" rejects records from any other source and
+// "overwrite" replaces their source with Identity.
+type ClientConfig struct {
+	Username   string   `mapstructure:"username"`
+	Password   string   `mapstructure:"password"`
+	Identity   string   `mapstructure:"identity"`
+	Topics     []string `mapstructure:"topics"`
+	Agents     []string `mapstructure:"agents"`
+	Sources    []string `mapstructure:"sources"`
+	SourceMode string   `mapstructure:"source_mode"`
 }
 
 type ServiceNowConfig struct {
-	Instance string `mapstructure:"instance"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	UseHTTPS bool   `mapstructure:"use_https"`
-	Timeout  int    `mapstructure:"timeout"`
+	Instance string      `mapstructure:"instance"`
+	Username string      `mapstructure:"username"`
+	Password string      `mapstructure:"password"`
+	UseHTTPS bool        `mapstructure:"use_https"`
+	Timeout  int         `mapstructure:"timeout"`
+	Proxy    ProxyConfig `mapstructure:"proxy"`
+	TLS      TLSConfig   `mapstructure:"tls"`
+}
+
+// ProxyConfig routes ServiceNow traffic through an outbound proxy. URL may use
+// the http, https, socks5 or socks5h scheme; when it is empty the standard
+// HTTPS_PROXY/HTTP_PROXY/NO_PROXY environment variables apply. NoProxy entries
+// are host names, domain suffixes (.example.com), IPs, CIDR ranges or "*".
+type ProxyConfig struct {
+	URL      string   `mapstructure:"url"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	NoProxy  []string `mapstructure:"no_proxy"`
+}
+
+// TLSConfig customises TLS to the instance: extra CA bundles (added to the
+// system pool, which is needed behind TLS-inspecting proxies), a client
+// certificate for mutual TLS and the minimum protocol version ("1.2", "1.3").
+type TLSConfig struct {
+	CAFiles    []string `mapstructure:"ca_files"`
+	CertFile   string   `mapstructure:"cert_file"`
+	KeyFile    string   `mapstructure:"key_file"`
+	MinVersion string   `mapstructure:"min_version"`
+}
+
+// SyslogConfig controls the optional syslog receiver. Messages are matched
+// against Rules in order; the first matching rule decides what happens.
+type SyslogConfig struct {
+	Enabled        bool         `mapstructure:"enabled"`
+	UDPAddress     string       `mapstructure:"udp_address"`
+	TCPAddress     string       `mapstructure:"tcp_address"`
+	MaxMessageSize int          `mapstructure:"max_message_size"`
+	DefaultAction  string       `mapstructure:"default_action"`
+	Rules          []SyslogRule `mapstructure:"rules"`
+}
+
+type SyslogRule struct {
+	Name            string       `mapstructure:"name"`
+	Match           SyslogMatch  `mapstructure:"match"`
+	Action          string       `mapstructure:"action"`
+	AggregateWindow int          `mapstructure:"aggregate_window"`
+	Record          RecordConfig `mapstructure:"record"`
+}
+
+// SyslogMatch criteria are combined with AND; empty criteria match everything.
+// Host and Message are regular expressions.
+type SyslogMatch struct {
+	Severities []string `mapstructure:"severities"`
+	Facilities []string `mapstructure:"facilities"`
+	Host       string   `mapstructure:"host"`
+	AppName    string   `mapstructure:"app_name"`
+	Message    string   `mapstructure:"message"`
+}
+
+// RecordConfig maps an ingested message onto ECC queue fields. Values are Go
+// text/template strings evaluated against the source message.
+type RecordConfig struct {
+	Agent   string            `mapstructure:"agent"`
+	Topic   string            `mapstructure:"topic"`
+	Name    string            `mapstructure:"name"`
+	Source  string            `mapstructure:"source"`
+	Payload map[string]string `mapstructure:"payload"`
+}
+
+// EventConfig maps an ingested message onto a ServiceNow Event Management event.
+// Values are Go text/template strings evaluated against the source message;
+// Severity must render to 0 (clear) through 5 (info).
+type EventConfig struct {
+	Source         string            `mapstructure:"source"`
+	Node           string            `mapstructure:"node"`
+	Type           string            `mapstructure:"type"`
+	Resource       string            `mapstructure:"resource"`
+	MetricName     string            `mapstructure:"metric_name"`
+	EventClass     string            `mapstructure:"event_class"`
+	Severity       string            `mapstructure:"severity"`
+	MessageKey     string            `mapstructure:"message_key"`
+	Description    string            `mapstructure:"description"`
+	AdditionalInfo map[string]string `mapstructure:"additional_info"`
+}
+
+// SNMPConfig controls the optional SNMP trap receiver. Target selects whether
+// traps become ECC records ("ecc"), Event Management events ("event") or both.
+type SNMPConfig struct {
+	Enabled        bool         `mapstructure:"enabled"`
+	Address        string       `mapstructure:"address"`
+	Communities    []string     `mapstructure:"communities"`
+	Users          []SNMPUser   `mapstructure:"users"`
+	MIBFiles       []string     `mapstructure:"mib_files"`
+	AllowedSources []string     `mapstructure:"allowed_sources"`
+	Filters        []SNMPFilter `mapstructure:"filters"`
+	Target         string       `mapstructure:"target"`
+	Record         RecordConfig `mapstructure:"record"`
+	Event          EventConfig  `mapstructure:"event"`
+}
+
+// SNMPUser is an SNMPv3 USM credential accepted for incoming traps
+type SNMPUser struct {
+	Username       string `mapstructure:"username"`
+	AuthProtocol   string `mapstructure:"auth_protocol"`
+	AuthPassphrase string `mapstructure:"auth_passphrase"`
+	PrivProtocol   string `mapstructure:"priv_protocol"`
+	PrivPassphrase string `mapstructure:"priv_passphrase"`
+}
+
+// SNMPFilter drops or forwards traps by sender and trap OID. Source is an IP or
+// CIDR and TrapOID an OID prefix or MIB name; empty fields match everything.
+type SNMPFilter struct {
+	Source  string `mapstructure:"source"`
+	TrapOID string `mapstructure:"trap_oid"`
+	Action  string `mapstructure:"action"`
+}
+
+// SensuConfig controls the Sensu Go events API compatibility endpoints and,
+// when AgentAddress is set, the agent websocket listener. The keepalive
+// timeouts apply to keepalives from connected agents and to "keepalive" check
+// events posted to the events API. Timeouts are in seconds; a critical timeout
+// of 0 disables that stage.
+type SensuConfig struct {
+	Enabled                  bool         `mapstructure:"enabled"`
+	AgentAddress             string       `mapstructure:"agent_address"`
+	Target                   string       `mapstructure:"target"`
+	ForwardOK                bool         `mapstructure:"forward_ok"`
+	APIKeys                  []string     `mapstructure:"api_keys"`
+	KeepaliveWarningTimeout  int          `mapstructure:"keepalive_warning_timeout"`
+	KeepaliveCriticalTimeout int          `mapstructure:"keepalive_critical_timeout"`
+	Record                   RecordConfig `mapstructure:"record"`
+	Event                    EventConfig  `mapstructure:"event"`
+}
+
+// AlertmanagerConfig controls the Prometheus Alertmanager webhook receiver
+type AlertmanagerConfig struct {
+	Enabled bool         `mapstructure:"enabled"`
+	Target  string       `mapstructure:"target"`
+	Record  RecordConfig `mapstructure:"record"`
+	Event   EventConfig  `mapstructure:"event"`
+}
+
+// EventsConfig controls Event Management output. Output decides whether records
+// ingested through the ECC endpoints and receivers are sent as ECC records,
+// events (mapped with Event) or both. FlushInterval is in seconds. At most
+// MaxPending events wait to be sent, including batches being retried.
+type EventsConfig struct {
+	Output        string      `mapstructure:"output"`
+	BatchSize     int         `mapstructure:"batch_size"`
+	FlushInterval int         `mapstructure:"flush_interval"`
+	MaxPending    int         `mapstructure:"max_pending"`
+	Event         EventConfig `mapstructure:"event"`
+}
+
+// CMDBConfig controls reconciliation of agent inventory into the CMDB through
+// the Identification and Reconciliation API. When Enabled, every ingested
+// record carrying endpoint_metrics is reconciled; ClassName overrides the
+// host class otherwise derived from the operating system.
+type CMDBConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	DataSource string `mapstructure:"data_source"`
+	DryRun     bool   `mapstructure:"dry_run"`
+	ClassName  string `mapstructure:"class_name"`
+}
+
+// AttachmentsConfig controls the attachment upload endpoint. ECC payloads
+// larger than OffloadThreshold bytes are moved to an attachment on the ECC
+// record; 0 disables offloading.
+type AttachmentsConfig struct {
+	MaxUploadBytes   int64 `mapstructure:"max_upload_bytes"`
+	OffloadThreshold int   `mapstructure:"offload_threshold"`
+}
+
+// AgentsConfig controls the registry of agents posting records. An agent is
+// stale once it has missed MissedIntervals of its reporting interval (declared
+// in its payload, measured, or DefaultInterval seconds); when Alerts is set a
+// record or event is sent through Target then, and again when it recovers.
+// Alerts is off by default since measured intervals are only an estimate.
+type AgentsConfig struct {
+	RegistryFile    string       `mapstructure:"registry_file"`
+	MissedIntervals int          `mapstructure:"missed_intervals"`
+	DefaultInterval int          `mapstructure:"default_interval"`
+	Alerts          bool         `mapstructure:"alerts"`
+	Target          string       `mapstructure:"target"`
+	Record          RecordConfig `mapstructure:"record"`
+	Event           EventConfig  `mapstructure:"event"`
+}
+
+// RegistryPath is where the agent registry is kept, by default
+// $HOME/.litemidgo/agents.json
+func (a AgentsConfig) RegistryPath() string {
+	if a.RegistryFile != "" {
+		return a.RegistryFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "agents.json"
+	}
+	return filepath.Join(home, ".litemidgo", "agents.json")
+}
+
+// envBindings maps settings to the environment variables that override them
+var envBindings = []struct{ key, env string }{
+	{"servicenow.instance", "SERVICENOW_INSTANCE"},
+	{"servicenow.username", "SERVICENOW_USERNAME"},
+	{"servicenow.password", "SERVICENOW_PASSWORD"},
+	{"servicenow.proxy.url", "SERVICENOW_PROXY_URL"},
+	{"servicenow.proxy.username", "SERVICENOW_PROXY_USERNAME"},
+	{"servicenow.proxy.password", "SERVICENOW_PROXY_PASSWORD"},
+	{"server.auth.username", "LITEMIDGO_AUTH_USERNAME"},
+	{"server.auth.password", "LITEMIDGO_AUTH_PASSWORD"},
+	{"server.auth.enabled", "LITEMIDGO_AUTH_ENABLED"},
+	{"secrets.vault_file", "LITEMIDGO_VAULT_FILE"},
+	{"profile", "LITEMIDGO_PROFILE"},
 }
 
+// loaded records where the settings of the last ReadConfig came from
+var loaded struct {
+	dotenv      map[string]bool
+	fileKeys    map[string]bool
+	profileKeys map[string]bool
+	fileProfile string
+}
+
+// LoadConfig reads the configuration and resolves secret references in it
 func LoadConfig(configPath string) (*Config, error) {
-	// Load .env file if it exists
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ResolveSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secret: %w", err)
+	}
+	return config, nil
+}
+
+// ReadConfig reads the configuration without resolving secret references
+func ReadConfig(configPath string) (*Config, error) {
+	// Load .env file if it exists, noting which variables it provides
+	loaded.dotenv = make(map[string]bool)
+	if dotenv, err := godotenv.Read(); err == nil {
+		for name := range dotenv {
+			if _, set := os.LookupEnv(name); !set {
+				loaded.dotenv[name] = true
+			}
+		}
+	}
 	if err := godotenv.Load(); err != nil {
 		log.Printf("No .env file found or error loading .env file: %v", err)
 	}
@@ -56,31 +341,88 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("server.auth.enabled", false)
 	viper.SetDefault("server.auth.username", "admin")
 	viper.SetDefault("server.auth.password", "change-me")
+	viper.SetDefault("server.stream.concurrency", 4)
+	viper.SetDefault("server.stream.max_line_bytes", 1048576)
+	viper.SetDefault("server.web.enabled", false)
+	viper.SetDefault("syslog.enabled", false)
+	viper.SetDefault("syslog.udp_address", ":5514")
+	viper.SetDefault("syslog.tcp_address", ":5514")
+	viper.SetDefault("syslog.max_message_size", 65536)
+	viper.SetDefault("syslog.default_action", "drop")
+	viper.SetDefault("snmp.enabled", false)
+	viper.SetDefault("snmp.address", ":9162")
+	viper.SetDefault("snmp.target", "ecc")
+	viper.SetDefault("sensu.enabled", false)
+	viper.SetDefault("sensu.target", "ecc")
+	viper.SetDefault("sensu.keepalive_warning_timeout", 120)
+	viper.SetDefault("sensu.keepalive_critical_timeout", 180)
+	viper.SetDefault("alertmanager.enabled", false)
+	viper.SetDefault("alertmanager.target", "event")
+	viper.SetDefault("events.output", "ecc")
+	viper.SetDefault("events.batch_size", 100)
+	viper.SetDefault("events.flush_interval", 5)
+	viper.SetDefault("events.max_pending", 10000)
+	viper.SetDefault("cmdb.enabled", false)
+	viper.SetDefault("cmdb.data_source", "LiteMIDgo")
+	viper.SetDefault("cmdb.dry_run", false)
+	viper.SetDefault("attachments.max_upload_bytes", 104857600)
+	viper.SetDefault("attachments.offload_threshold", 0)
+	viper.SetDefault("agents.missed_intervals", 3)
+	viper.SetDefault("agents.default_interval", 0)
+	viper.SetDefault("agents.alerts", false)
+	viper.SetDefault("agents.target", "ecc")
 	viper.SetDefault("servicenow.use_https", true)
 	viper.SetDefault("servicenow.timeout", 30)
+	viper.SetDefault("servicenow.tls.min_version", "1.2")
 
 	// Set environment variable bindings
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("LITEMIDGO")
 
 	// Bind environment variables to config keys
-	viper.BindEnv("servicenow.instance", "SERVICENOW_INSTANCE")
-	viper.BindEnv("servicenow.username", "SERVICENOW_USERNAME")
-	viper.BindEnv("servicenow.password", "SERVICENOW_PASSWORD")
-
-	// Bind server authentication environment variables
-	viper.BindEnv("server.auth.username", "LITEMIDGO_AUTH_USERNAME")
-	viper.BindEnv("server.auth.password", "LITEMIDGO_AUTH_PASSWORD")
-	viper.BindEnv("server.auth.enabled", "LITEMIDGO_AUTH_ENABLED")
+	for _, binding := range envBindings {
+		viper.BindEnv(binding.key, binding.env)
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok || errors.Is(err, os.ErrNotExist) {
 			log.Printf("Config file not found, using defaults")
 		} else {
 			return nil, fmt.Errorf("error reading config file: %w", err)
 		}
 	}
 
+	loaded.fileKeys = make(map[string]bool)
+	for _, key := range viper.AllKeys() {
+		if viper.InConfig(key) {
+			loaded.fileKeys[key] = true
+		}
+	}
+	loaded.fileProfile = ""
+	if loaded.fileKeys["profile"] {
+		file := viper.New()
+		file.SetConfigFile(viper.ConfigFileUsed())
+		if err := file.ReadInConfig(); err == nil {
+			loaded.fileProfile = file.GetString("profile")
+		}
+	}
+	loaded.profileKeys = make(map[string]bool)
+
+	// Overlay the selected profile (--profile, LITEMIDGO_PROFILE or the
+	// profile key in the file) on the base settings
+	if profile := viper.GetString("profile"); profile != "" {
+		settings, ok := viper.Get("profiles." + profile).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(Profiles(), ", "))
+		}
+		if err := viper.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("failed to apply profile %q: %w", profile, err)
+		}
+		for key := range FlattenKeys(settings) {
+			loaded.profileKeys[strings.ToLower(key)] = true
+		}
+	}
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("unable to decode config: %w", err)
@@ -89,15 +431,22 @@ func LoadConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
-func (c *Config) Validate() error {
-	if c.ServiceNow.Instance == "" {
-		return fmt.Errorf("ServiceNow instance is required. Set SERVICENOW_INSTANCE environment variable or configure in config file")
-	}
-	if c.ServiceNow.Username == "" {
-		return fmt.Errorf("ServiceNow username is required. Set SERVICENOW_USERNAME environment variable or configure in config file")
+// Profiles returns the names of the profiles in the config file, sorted
+func Profiles() []string {
+	var names []string
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
 	}
-	if c.ServiceNow.Password == "" {
-		return fmt.Errorf("ServiceNow password is required. Set SERVICENOW_PASSWORD environment variable or configure in config file")
+	sort.Strings(names)
+	return names
+}
+
+// Validate returns the first error found by Check; warnings are not errors
+func (c *Config) Validate() error {
+	for _, problem := range c.Check() {
+		if !problem.Warning {
+			return fmt.Errorf("%s", problem)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is where new configuration is written when no config
+// file exists yet
+const DefaultConfigFile = "config/config.yaml"
+
+// FileUsed returns the config file read by the last LoadConfig or
+// ReadConfig, or DefaultConfigFile when none was found
+func FileUsed() string {
+	if file := viper.ConfigFileUsed(); file != "" {
+		return file
+	}
+	return DefaultConfigFile
+}
+
+// SetFileValues sets dotted keys (e.g. "servicenow.instance") in a YAML
+// config file, leaving other keys and comments untouched. The file is
+// created if needed and always written with 0600 permissions since it may
+// hold credentials.
+func SetFileValues(path string, values map[string]interface{}) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a YAML mapping", path)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		node := doc.Content[0]
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child := mappingValue(node, part)
+			if child == nil || child.Kind != yaml.MappingNode {
+				if child == nil {
+					child = &yaml.Node{}
+					node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, child)
+				}
+				*child = yaml.Node{Kind: yaml.MappingNode}
+			}
+			node = child
+		}
+
+		last := parts[len(parts)-1]
+		value := mappingValue(node, last)
+		if value == nil {
+			value = &yaml.Node{}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: last}, value)
+		}
+		comment := value.LineComment
+		if err := value.Encode(values[key]); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		value.LineComment = comment
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	encoder.Close()
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
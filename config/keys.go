@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// CoerceValue checks that a dotted key (optionally under profiles.<name>.)
+// names a setting of Config and converts value to the setting's type. String
+// values are parsed, so "8080" becomes an int and "a,b" a list. Structured
+// values (e.g. syslog.rules) are validated and returned unchanged so they keep
+// their config file field names.
+func CoerceValue(key string, value interface{}) (interface{}, error) {
+	t, err := keyType(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := value.(string); ok && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String {
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+	}
+
+	target := reflect.New(t)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		Result:           target.Interface(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(value); err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct:
+		return value, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		return value, nil
+	}
+	return target.Elem().Interface(), nil
+}
+
+// CheckKey reports an error when a dotted key is not a known setting
+func CheckKey(key string) error {
+	_, err := keyType(key)
+	return err
+}
+
+// keyType returns the Go type of the setting a dotted key refers to
+func keyType(key string) (reflect.Type, error) {
+	parts := strings.Split(key, ".")
+	if parts[0] == "profiles" {
+		if len(parts) < 2 || parts[1] == "" {
+			return nil, fmt.Errorf("%s: a profile name is required", key)
+		}
+		parts = parts[2:]
+	}
+
+	t := reflect.TypeOf(Config{})
+	for i, part := range parts {
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByTag(t, part)
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %s", strings.Join(parts[:i+1], "."))
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("unknown setting %s", strings.Join(parts[:i+1], "."))
+		}
+	}
+	return t, nil
+}
+
+func fieldByTag(t reflect.Type, tag string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") == tag {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// IsSecretKey reports whether a dotted key holds a credential that should be
+// redacted when displayed
+func IsSecretKey(key string) bool {
+	last := key[strings.LastIndex(key, ".")+1:]
+	switch last {
+	case "password", "api_keys", "auth_passphrase", "priv_passphrase":
+		return true
+	}
+	return false
+}
+
+// FlattenKeys turns nested maps into dotted keys, leaving lists and scalars
+// as values. Keys that already contain dots are kept as they are.
+func FlattenKeys(values map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	var walk func(prefix string, values map[string]interface{})
+	walk = func(prefix string, values map[string]interface{}) {
+		for key, value := range values {
+			if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			flat[prefix+key] = value
+		}
+	}
+	walk("", values)
+	return flat
+}
+
+// Redact hides plain-text secrets in a setting value, looking into lists and
+// maps. Secret references are kept since they only say where a secret lives.
+func Redact(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = Redact(k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = Redact(key, item)
+		}
+		return redacted
+	case []string:
+		redacted := make([]string, len(v))
+		for i, item := range v {
+			redacted[i] = fmt.Sprint(Redact(key, item))
+		}
+		return redacted
+	}
+
+	if !IsSecretKey(key) {
+		return value
+	}
+	if s := fmt.Sprint(value); value == nil || s == "" || IsSecretRef(s) {
+		return value
+	}
+	return "********"
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// execSecretTimeout bounds how long an exec: secret command may run
+const execSecretTimeout = 30 * time.Second
+
+// SecretsConfig locates the encrypted vault used by vault: references
+type SecretsConfig struct {
+	VaultFile     string `mapstructure:"vault_file"`
+	MasterKeyFile string `mapstructure:"master_key_file"`
+}
+
+// IsSecretRef reports whether value is a secret reference
+func IsSecretRef(value string) bool {
+	for _, prefix := range []string{"file:", "env:", "exec:", "vault:", "plain:"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecrets replaces secret references in the credential settings with
+// the secrets they point at:
+//
+//	file:/run/secrets/snow    contents of a file (trailing newline removed)
+//	env:SNOW_PASSWORD         an environment variable
+//	exec:pass show snow/prod  stdout of a shell command
+//	vault:servicenow.password an entry in the encrypted local vault
+//	plain:env:not-a-ref       a literal value that starts with a prefix
+//
+// Any other value is used as is. LoadConfig calls it, so references are
+// resolved again whenever the configuration is loaded.
+func (c *Config) ResolveSecrets() error {
+	fields := []struct {
+		key   string
+		value *string
+	}{
+		{"servicenow.username", &c.ServiceNow.Username},
+		{"servicenow.password", &c.ServiceNow.Password},
+		{"servicenow.proxy.username", &c.ServiceNow.Proxy.Username},
+		{"servicenow.proxy.password", &c.ServiceNow.Proxy.Password},
+		{"server.auth.username", &c.Server.Auth.Username},
+		{"server.auth.password", &c.Server.Auth.Password},
+	}
+	for i := range c.Server.Auth.Clients {
+		fields = append(fields, struct {
+			key   string
+			value *string
+		}{fmt.Sprintf("server.auth.clients[%d].password", i), &c.Server.Auth.Clients[i].Password})
+	}
+	for i := range c.Sensu.APIKeys {
+		fields = append(fields, struct {
+			key   string
+			value *string
+		}{fmt.Sprintf("sensu.api_keys[%d]", i), &c.Sensu.APIKeys[i]})
+	}
+
+	var vault *Vault
+	for _, field := range fields {
+		if !IsSecretRef(*field.value) {
+			continue
+		}
+		if strings.HasPrefix(*field.value, "vault:") && vault == nil {
+			var err error
+			if vault, err = c.Secrets.OpenVault(); err != nil {
+				return fmt.Errorf("%s: %w", field.key, err)
+			}
+		}
+		resolved, err := resolveSecret(*field.value, vault)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.key, err)
+		}
+		*field.value = resolved
+	}
+	return nil
+}
+
+func resolveSecret(ref string, vault *Vault) (string, error) {
+	kind, value, _ := strings.Cut(ref, ":")
+	switch kind {
+	case "plain":
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "env":
+		secret, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", value)
+		}
+		return secret, nil
+	case "exec":
+		ctx, cancel := context.WithTimeout(context.Background(), execSecretTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", value)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret command failed: %w", err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	case "vault":
+		return vault.Get(value)
+	}
+	return ref, nil
+}
+
+// OpenVault opens the configured vault. The master key comes from the
+// LITEMIDGO_MASTER_KEY environment variable or from master_key_file.
+func (s SecretsConfig) OpenVault() (*Vault, error) {
+	masterKey := os.Getenv("LITEMIDGO_MASTER_KEY")
+	if masterKey == "" && s.MasterKeyFile != "" {
+		data, err := os.ReadFile(s.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		masterKey = strings.TrimRight(string(data), "\r\n")
+	}
+	if masterKey == "" {
+		return nil, fmt.Errorf("vault master key not set (LITEMIDGO_MASTER_KEY or secrets.master_key_file)")
+	}
+	return OpenVault(s.vaultPath(), masterKey)
+}
+
+func (s SecretsConfig) vaultPath() string {
+	if s.VaultFile != "" {
+		return s.VaultFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "vault.json"
+	}
+	return filepath.Join(home, ".litemidgo", "vault.json")
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Setting is one effective configuration value and where it came from
+type Setting struct {
+	Key    string
+	Value  interface{}
+	Source string
+}
+
+// EffectiveSettings lists the settings of the last ReadConfig or LoadConfig
+// in key order, after profiles, environment variables and defaults have been
+// applied. Values are as configured; secret references are not resolved.
+// Source is "default", "file <path>", "profile <name>", "env <VAR>",
+// ".env <VAR>" or "--profile".
+func EffectiveSettings() []Setting {
+	var settings []Setting
+	for _, key := range viper.AllKeys() {
+		if key == "profiles" || strings.HasPrefix(key, "profiles.") || key == "debug" {
+			continue
+		}
+		value := viper.Get(key)
+		source := keySource(key)
+		if source == "default" && (value == nil || value == "") {
+			continue
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: source})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+func keySource(key string) string {
+	for _, binding := range envBindings {
+		if binding.key != key {
+			continue
+		}
+		if _, set := os.LookupEnv(binding.env); set {
+			if loaded.dotenv[binding.env] {
+				return ".env " + binding.env
+			}
+			return "env " + binding.env
+		}
+	}
+
+	switch {
+	case loaded.profileKeys[key]:
+		return "profile " + viper.GetString("profile")
+	case loaded.fileKeys[key]:
+		if key == "profile" && viper.GetString("profile") != loaded.fileProfile {
+			return "--profile"
+		}
+		return "file " + viper.ConfigFileUsed()
+	case key == "profile":
+		return "--profile"
+	}
+	return "default"
+}
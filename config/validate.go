@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Problem is a configuration issue found by Check. Errors stop the server
+// from starting; warnings are reported but allowed.
+type Problem struct {
+	Key     string
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Key, p.Message)
+}
+
+const (
+	maxTimeout      = 600
+	defaultPassword = "change-me"
+)
+
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// Check runs every configuration check and returns the problems found, errors
+// first. Secret references are not resolved, so it works on ReadConfig output.
+func (c *Config) Check() []Problem {
+	var errs, warnings []Problem
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(key, format string, args ...interface{}) {
+		warnings = append(warnings, Problem{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	// ServiceNow connection
+	if c.ServiceNow.Instance == "" {
+		fail("servicenow.instance", "required (set SERVICENOW_INSTANCE or configure in config file)")
+	} else if err := checkInstance(c.ServiceNow.Instance); err != nil {
+		fail("servicenow.instance", "%v", err)
+	}
+	if c.ServiceNow.Username == "" {
+		fail("servicenow.username", "required (set SERVICENOW_USERNAME or configure in config file)")
+	}
+	if c.ServiceNow.Password == "" {
+		fail("servicenow.password", "required (set SERVICENOW_PASSWORD or configure in config file)")
+	}
+	if c.ServiceNow.Timeout < 1 || c.ServiceNow.Timeout > maxTimeout {
+		fail("servicenow.timeout", "must be between 1 and %d seconds, got %d", maxTimeout, c.ServiceNow.Timeout)
+	}
+	if !c.ServiceNow.UseHTTPS {
+		warn("servicenow.use_https", "credentials are sent without TLS")
+	}
+	if proxy := c.ServiceNow.Proxy.URL; proxy != "" {
+		if u, err := url.Parse(proxy); err != nil || u.Host == "" {
+			fail("servicenow.proxy.url", "invalid URL %q", proxy)
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			fail("servicenow.proxy.url", "unsupported scheme %q (use http, https or socks5)", u.Scheme)
+		}
+	}
+	if v := c.ServiceNow.TLS.MinVersion; v != "" {
+		switch strings.TrimPrefix(strings.ToLower(v), "tls") {
+		case "1.2", "1.3":
+		case "1.0", "1.1":
+			warn("servicenow.tls.min_version", "TLS %s is deprecated", v)
+		default:
+			fail("servicenow.tls.min_version", "unsupported version %q (use 1.2 or 1.3)", v)
+		}
+	}
+	for _, file := range c.ServiceNow.TLS.CAFiles {
+		if _, err := os.Stat(file); err != nil {
+			fail("servicenow.tls.ca_files", "%v", err)
+		}
+	}
+	if (c.ServiceNow.TLS.CertFile == "") != (c.ServiceNow.TLS.KeyFile == "") {
+		fail("servicenow.tls.cert_file", "cert_file and key_file must be set together")
+	}
+
+	// HTTP server
+	if c.Server.Host != "" && net.ParseIP(c.Server.Host) == nil && checkHostname(c.Server.Host) != nil {
+		fail("server.host", "%q is not an IP address or host name", c.Server.Host)
+	}
+	checkPort(fail, "server.port", c.Server.Port)
+	if c.Server.Auth.Enabled {
+		if c.Server.Auth.Username == "" || c.Server.Auth.Password == "" {
+			fail("server.auth", "username and password are required when auth is enabled")
+		} else if c.Server.Auth.Password == defaultPassword {
+			warn("server.auth.password", "still the default %q; change it before exposing the server", defaultPassword)
+		}
+	} else {
+		warn("server.auth.enabled", "authentication is disabled; protected endpoints are open")
+	}
+	if len(c.Server.Auth.Clients) > 0 {
+		if c.Server.Auth.Enabled {
+			checkClients(fail, c.Server.Auth)
+		} else {
+			warn("server.auth.clients", "ignored because authentication is disabled")
+		}
+	}
+	if c.Server.Web.Enabled && !c.Server.Auth.Enabled {
+		fail("server.web.enabled", "requires server.auth.enabled; the dashboard shows agents and requests")
+	}
+	if c.Server.Stream.Concurrency < 1 {
+		fail("server.stream.concurrency", "must be at least 1")
+	}
+	if c.Server.Stream.MaxLineBytes < 1 {
+		fail("server.stream.max_line_bytes", "must be at least 1")
+	}
+
+	// Receivers
+	if c.Syslog.Enabled {
+		checkAddress(fail, "syslog.udp_address", c.Syslog.UDPAddress)
+		checkAddress(fail, "syslog.tcp_address", c.Syslog.TCPAddress)
+		checkAction(fail, "syslog.default_action", c.Syslog.DefaultAction)
+		for i, rule := range c.Syslog.Rules {
+			checkAction(fail, fmt.Sprintf("syslog.rules[%d].action", i), rule.Action)
+		}
+	}
+	if c.SNMP.Enabled {
+		checkAddress(fail, "snmp.address", c.SNMP.Address)
+		checkTarget(fail, "snmp.target", c.SNMP.Target)
+		if len(c.SNMP.Communities) == 0 {
+			warn("snmp.communities", "no communities configured; v1/v2c traps with any community are accepted")
+		}
+	}
+	if c.Sensu.Enabled {
+		checkTarget(fail, "sensu.target", c.Sensu.Target)
+		if c.Sensu.AgentAddress != "" {
+			checkAddress(fail, "sensu.agent_address", c.Sensu.AgentAddress)
+			if !c.Server.Auth.Enabled {
+				warn("sensu.agent_address", "server auth is disabled; any agent can connect")
+			}
+		}
+		if len(c.Sensu.APIKeys) == 0 {
+			warn("sensu.api_keys", "no API keys configured; the Sensu endpoints use server auth only")
+		}
+		if c.Sensu.KeepaliveCriticalTimeout > 0 && c.Sensu.KeepaliveCriticalTimeout <= c.Sensu.KeepaliveWarningTimeout {
+			fail("sensu.keepalive_critical_timeout", "must be greater than keepalive_warning_timeout")
+		}
+	}
+	if c.Alertmanager.Enabled {
+		checkTarget(fail, "alertmanager.target", c.Alertmanager.Target)
+		switch strings.ToLower(c.Alertmanager.Target) {
+		case "", "ecc":
+			warn("alertmanager.target", "resolved alerts become new ECC records and close nothing; use event or both to clear Event Management alerts")
+		}
+	}
+
+	// Agent registry
+	if c.Agents.MissedIntervals < 1 {
+		fail("agents.missed_intervals", "must be at least 1")
+	}
+	if c.Agents.DefaultInterval < 0 {
+		fail("agents.default_interval", "must not be negative")
+	}
+	if c.Agents.Alerts {
+		checkTarget(fail, "agents.target", c.Agents.Target)
+	}
+
+	// Outputs
+	checkTarget(fail, "events.output", c.Events.Output)
+	if c.Events.BatchSize < 1 {
+		fail("events.batch_size", "must be at least 1")
+	}
+	if c.Events.FlushInterval < 1 {
+		fail("events.flush_interval", "must be at least 1 second")
+	}
+	if c.Events.MaxPending < c.Events.BatchSize {
+		fail("events.max_pending", "must be at least batch_size")
+	}
+	if c.Attachments.MaxUploadBytes < 0 {
+		fail("attachments.max_upload_bytes", "must not be negative")
+	}
+	if c.Attachments.OffloadThreshold < 0 {
+		fail("attachments.offload_threshold", "must not be negative")
+	}
+
+	return append(errs, warnings...)
+}
+
+// checkInstance accepts a host name or IP with an optional port and rejects
+// URLs, which are a common mistake
+func checkInstance(instance string) error {
+	if strings.Contains(instance, "://") {
+		return fmt.Errorf("%q must be a host name without scheme, e.g. acme.service-now.com (use use_https for the scheme)", instance)
+	}
+	if strings.ContainsAny(instance, "/?# ") {
+		return fmt.Errorf("%q must be a host name without path", instance)
+	}
+	host := instance
+	if h, port, err := net.SplitHostPort(instance); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port in %q", instance)
+		}
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	return checkHostname(host)
+}
+
+func checkHostname(host string) error {
+	if len(host) > 253 {
+		return fmt.Errorf("host name %q is too long", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !hostnameLabel.MatchString(label) {
+			return fmt.Errorf("%q is not a valid host name", host)
+		}
+	}
+	return nil
+}
+
+// checkClients validates the restricted client credentials and their policies
+func checkClients(fail func(string, string, ...interface{}), auth AuthConfig) {
+	seen := map[string]bool{auth.Username: true}
+	for i, client := range auth.Clients {
+		key := fmt.Sprintf("server.auth.clients[%d]", i)
+		if client.Username == "" || client.Password == "" {
+			fail(key, "username and password are required")
+		} else if seen[client.Username] {
+			fail(key+".username", "%q is already used by another credential", client.Username)
+		}
+		seen[client.Username] = true
+
+		switch client.SourceMode {
+		case "", "enforce", "overwrite":
+		default:
+			fail(key+".source_mode", "unknown mode %q (use enforce or overwrite)", client.SourceMode)
+		}
+		for _, rule := range []struct {
+			field    string
+			patterns []string
+		}{{"topics", client.Topics}, {"agents", client.Agents}, {"sources", client.Sources}} {
+			for _, pattern := range rule.patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					fail(key+"."+rule.field, "invalid pattern %q", pattern)
+				}
+			}
+		}
+	}
+}
+
+func checkPort(fail func(string, string, ...interface{}), key string, port int) {
+	if port < 1 || port > 65535 {
+		fail(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func checkAddress(fail func(string, string, ...interface{}), key, address string) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		fail(key, "invalid listen address %q: %v", address, err)
+		return
+	}
+	if host != "" && net.ParseIP(host) == nil && checkHostname(host) != nil {
+		fail(key, "invalid host in %q", address)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		fail(key, "invalid port in %q", address)
+		return
+	}
+	checkPort(fail, key, n)
+}
+
+func checkTarget(fail func(string, string, ...interface{}), key, target string) {
+	switch strings.ToLower(target) {
+	case "", "ecc", "event", "both":
+	default:
+		fail(key, "unknown value %q (use ecc, event or both)", target)
+	}
+}
+
+func checkAction(fail func(string, string, ...interface{}), key, action string) {
+	switch strings.ToLower(action) {
+	case "", "forward", "drop":
+	default:
+		fail(key, "unknown action %q (use forward or drop)", action)
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	vaultVersion    = 1
+	vaultIterations = 600000
+	vaultCheck      = "litemidgo-vault"
+)
+
+// Vault is a local file of secrets encrypted with AES-256-GCM under a key
+// derived from a master key with PBKDF2-SHA256. Each entry is sealed with its
+// name as additional data, so entries cannot be swapped between names.
+type Vault struct {
+	path string
+	aead cipher.AEAD
+	file vaultFile
+}
+
+type vaultFile struct {
+	Version    int               `json:"version"`
+	Salt       string            `json:"salt"`
+	Iterations int               `json:"iterations"`
+	Check      string            `json:"check"`
+	Secrets    map[string]string `json:"secrets"`
+}
+
+// OpenVault opens the vault at path, creating an empty one in memory if the
+// file does not exist yet. A wrong master key is reported here rather than on
+// the first Get.
+func OpenVault(path, masterKey string) (*Vault, error) {
+	v := &Vault{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		v.file = vaultFile{
+			Version:    vaultVersion,
+			Salt:       base64.StdEncoding.EncodeToString(salt),
+			Iterations: vaultIterations,
+			Secrets:    make(map[string]string),
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	default:
+		if err := json.Unmarshal(data, &v.file); err != nil {
+			return nil, fmt.Errorf("failed to parse vault %s: %w", path, err)
+		}
+		if v.file.Version != vaultVersion {
+			return nil, fmt.Errorf("unsupported vault version %d", v.file.Version)
+		}
+		if v.file.Secrets == nil {
+			v.file.Secrets = make(map[string]string)
+		}
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(v.file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, masterKey, salt, v.file.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if v.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	if v.file.Check == "" {
+		if v.file.Check, err = v.seal(vaultCheck, vaultCheck); err != nil {
+			return nil, err
+		}
+	} else if check, err := v.open(vaultCheck, v.file.Check); err != nil || check != vaultCheck {
+		return nil, fmt.Errorf("wrong master key for vault %s", path)
+	}
+	return v, nil
+}
+
+// Get decrypts a secret
+func (v *Vault) Get(name string) (string, error) {
+	sealed, ok := v.file.Secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found in vault %s", name, v.path)
+	}
+	secret, err := v.open(name, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %q: %w", name, err)
+	}
+	return secret, nil
+}
+
+// Set encrypts a secret and saves the vault
+func (v *Vault) Set(name, secret string) error {
+	sealed, err := v.seal(name, secret)
+	if err != nil {
+		return err
+	}
+	v.file.Secrets[name] = sealed
+	return v.save()
+}
+
+// Delete removes a secret and saves the vault
+func (v *Vault) Delete(name string) error {
+	if _, ok := v.file.Secrets[name]; !ok {
+		return fmt.Errorf("secret %q not found in vault %s", name, v.path)
+	}
+	delete(v.file.Secrets, name)
+	return v.save()
+}
+
+// Names lists the stored secret names in order
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.file.Secrets))
+	for name := range v.file.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path returns the vault file location
+func (v *Vault) Path() string {
+	return v.path
+}
+
+func (v *Vault) seal(name, plaintext string) (string, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := v.aead.Seal(nonce, nonce, []byte(plaintext), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (v *Vault) open(name, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < v.aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:v.aead.NonceSize()], sealed[v.aead.NonceSize():]
+	plaintext, err := v.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (v *Vault) save() error {
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return os.Rename(tmp, v.path)
+}
@@ -0,0 +1,223 @@
+// Package cmdb converts the inventory reported by litemidgo agents into
+// Identification and Reconciliation (IRE) payloads.
+package cmdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"litemidgo/internal/servicenow"
+)
+
+// Relation types between the host and its dependent CIs
+const (
+	ownsRelation     = "Owns::Owned by"
+	containsRelation = "Contains::Contained by"
+)
+
+// EndpointMetrics is the subset of the agent's endpoint_metrics payload used
+// to build CIs.
+type EndpointMetrics struct {
+	Hostname        string `json:"hostname"`
+	OperatingSystem struct {
+		Platform           string `json:"platform"`
+		PlatformFamily     string `json:"platform_family"`
+		PlatformVersion    string `json:"platform_version"`
+		Architecture       string `json:"architecture"`
+		KernelVersion      string `json:"kernel_version"`
+		VirtualizationRole string `json:"virtualization_role"`
+	} `json:"operating_system"`
+	CPU struct {
+		ModelName    string  `json:"model_name"`
+		Cores        int     `json:"cores"`
+		LogicalCores int     `json:"logical_cores"`
+		FrequencyMHz float64 `json:"frequency_mhz"`
+	} `json:"cpu_metrics"`
+	Memory struct {
+		Total uint64 `json:"total"`
+	} `json:"memory_metrics"`
+	Disks []struct {
+		Device     string `json:"device"`
+		Mountpoint string `json:"mountpoint"`
+		Fstype     string `json:"fstype"`
+		Total      uint64 `json:"total"`
+		Free       uint64 `json:"free"`
+	} `json:"disk_metrics"`
+	Network struct {
+		Interfaces []struct {
+			Name         string   `json:"name"`
+			HardwareAddr string   `json:"hardware_addr"`
+			Flags        []string `json:"flags"`
+			Addresses    []string `json:"addresses"`
+		} `json:"interfaces"`
+	} `json:"network_metrics"`
+}
+
+// FindEndpointMetrics extracts endpoint_metrics from an ECC record payload,
+// returning false when the payload does not carry agent inventory.
+func FindEndpointMetrics(payload interface{}) (*EndpointMetrics, bool, error) {
+	fields, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	raw, ok := fields["endpoint_metrics"]
+	if !ok {
+		return nil, false, nil
+	}
+
+	// Round-trip through JSON to decode the generic map into typed fields
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, err
+	}
+	var metrics EndpointMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, true, fmt.Errorf("invalid endpoint_metrics: %w", err)
+	}
+	if metrics.Hostname == "" {
+		return nil, true, fmt.Errorf("endpoint_metrics has no hostname")
+	}
+	return &metrics, true, nil
+}
+
+// HostClass picks the CMDB class for the host from its operating system
+func HostClass(metrics *EndpointMetrics) string {
+	switch {
+	case strings.Contains(strings.ToLower(metrics.OperatingSystem.Platform), "windows"),
+		strings.EqualFold(metrics.OperatingSystem.PlatformFamily, "windows"):
+		return "cmdb_ci_win_server"
+	case strings.EqualFold(metrics.OperatingSystem.Platform, "darwin"):
+		return "cmdb_ci_computer"
+	}
+	return "cmdb_ci_linux_server"
+}
+
+// ToIRE builds the IRE payload for a host: the server CI (className, or the
+// class derived from the OS when empty) plus its network adapters and file
+// systems, related to the server. Item 0 is always the server.
+func ToIRE(metrics *EndpointMetrics, className string) *servicenow.IREPayload {
+	if className == "" {
+		className = HostClass(metrics)
+	}
+
+	server := map[string]interface{}{
+		"name":             metrics.Hostname,
+		"host_name":        metrics.Hostname,
+		"os":               metrics.OperatingSystem.Platform,
+		"os_version":       metrics.OperatingSystem.PlatformVersion,
+		"os_address_width": addressWidth(metrics.OperatingSystem.Architecture),
+		"kernel_release":   metrics.OperatingSystem.KernelVersion,
+		"cpu_type":         metrics.CPU.ModelName,
+		"cpu_name":         metrics.CPU.ModelName,
+		"cpu_core_count":   metrics.CPU.Cores,
+		"cpu_count":        metrics.CPU.LogicalCores,
+		"cpu_speed":        int(metrics.CPU.FrequencyMHz),
+		"ram":              metrics.Memory.Total / (1024 * 1024),
+		"virtual":          metrics.OperatingSystem.VirtualizationRole == "guest",
+	}
+
+	if metrics.OperatingSystem.Architecture == "" {
+		delete(server, "os_address_width")
+	}
+	pruneEmpty(server)
+
+	payload := &servicenow.IREPayload{
+		Items: []servicenow.IREItem{{ClassName: className, Values: server}},
+	}
+
+	for _, iface := range metrics.Network.Interfaces {
+		if iface.HardwareAddr == "" || slices.Contains(iface.Flags, "loopback") {
+			continue
+		}
+		adapter := map[string]interface{}{
+			"name":        iface.Name,
+			"mac_address": iface.HardwareAddr,
+		}
+		if ip, netmask := firstIPv4(iface.Addresses); ip != "" {
+			adapter["ip_address"] = ip
+			adapter["netmask"] = netmask
+			// The first addressed adapter doubles as the host's primary address
+			if _, ok := server["ip_address"]; !ok {
+				server["ip_address"] = ip
+				server["mac_address"] = iface.HardwareAddr
+			}
+		}
+		payload.Items = append(payload.Items, servicenow.IREItem{
+			ClassName: "cmdb_ci_network_adapter",
+			Values:    adapter,
+		})
+		payload.Relations = append(payload.Relations, servicenow.IRERelation{
+			Parent: 0,
+			Child:  len(payload.Items) - 1,
+			Type:   ownsRelation,
+		})
+	}
+
+	for _, disk := range metrics.Disks {
+		if disk.Mountpoint == "" {
+			continue
+		}
+		payload.Items = append(payload.Items, servicenow.IREItem{
+			ClassName: "cmdb_ci_file_system",
+			Values: map[string]interface{}{
+				"name":             disk.Mountpoint,
+				"mount_point":      disk.Mountpoint,
+				"file_system":      disk.Fstype,
+				"media_type":       "fixed",
+				"label":            disk.Device,
+				"size_bytes":       disk.Total,
+				"free_space_bytes": disk.Free,
+			},
+		})
+		payload.Relations = append(payload.Relations, servicenow.IRERelation{
+			Parent: 0,
+			Child:  len(payload.Items) - 1,
+			Type:   containsRelation,
+		})
+	}
+
+	return payload
+}
+
+// pruneEmpty drops values the agent did not report, so reconciliation does not
+// blank out attributes populated by other data sources
+func pruneEmpty(values map[string]interface{}) {
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				delete(values, key)
+			}
+		case int:
+			if v == 0 {
+				delete(values, key)
+			}
+		case uint64:
+			if v == 0 {
+				delete(values, key)
+			}
+		}
+	}
+}
+
+func addressWidth(arch string) int {
+	if strings.Contains(arch, "64") {
+		return 64
+	}
+	return 32
+}
+
+// firstIPv4 returns the first IPv4 address and its netmask from CIDR strings
+func firstIPv4(addresses []string) (string, string) {
+	for _, addr := range addresses {
+		ip, network, err := net.ParseCIDR(addr)
+		if err != nil || ip.To4() == nil {
+			continue
+		}
+		return ip.String(), net.IP(network.Mask).String()
+	}
+	return "", ""
+}
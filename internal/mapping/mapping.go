@@ -0,0 +1,256 @@
+// Package mapping turns ingested messages (syslog, SNMP traps, webhooks) into
+// ECC queue records and Event Management events using configurable
+// text/template strings.
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+)
+
+var funcs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// default returns fallback when value is empty: {{ .Labels.team | default "ops" }}
+	"default": func(fallback string, value interface{}) string {
+		if value == nil {
+			return fallback
+		}
+		if s := fmt.Sprint(value); s != "" {
+			return s
+		}
+		return fallback
+	},
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"severity": SeverityCode,
+}
+
+// severityNames are the Event Management severities and common aliases used by
+// monitoring tools
+var severityNames = map[string]string{
+	"clear": "0", "ok": "0", "resolved": "0",
+	"critical": "1", "fatal": "1", "emergency": "1", "alert": "1", "page": "1",
+	"major": "2", "error": "2", "high": "2",
+	"minor": "3", "medium": "3",
+	"warning": "4", "warn": "4", "low": "4",
+	"info": "5", "informational": "5", "notice": "5", "none": "5",
+}
+
+// SeverityCode converts a severity name or number to the Event Management
+// code ("0" clear through "5" info). Unknown values map to "5".
+func SeverityCode(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if code, ok := severityNames[value]; ok {
+		return code
+	}
+	if len(value) == 1 && value[0] >= '0' && value[0] <= '5' {
+		return value
+	}
+	return "5"
+}
+
+func compile(name, value, fallback string) (*template.Template, error) {
+	if value == "" {
+		value = fallback
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func compileMap(prefix string, values map[string]string) (map[string]*template.Template, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	compiled := make(map[string]*template.Template, len(values))
+	for key, value := range values {
+		tmpl, err := compile(prefix+"."+key, value, "")
+		if err != nil {
+			return nil, err
+		}
+		compiled[key] = tmpl
+	}
+	return compiled, nil
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	// missingkey=zero renders absent map entries of interface type this way
+	return strings.ReplaceAll(b.String(), "<no value>", ""), nil
+}
+
+func executeMap(templates map[string]*template.Template, data interface{}) (map[string]interface{}, error) {
+	if templates == nil {
+		return nil, nil
+	}
+	values := make(map[string]interface{}, len(templates))
+	for key, tmpl := range templates {
+		value, err := execute(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Record renders ECC queue records
+type Record struct {
+	agent   *template.Template
+	topic   *template.Template
+	name    *template.Template
+	source  *template.Template
+	payload map[string]*template.Template
+}
+
+// NewRecord compiles cfg, using defaults for any field cfg leaves empty
+func NewRecord(cfg, defaults config.RecordConfig) (*Record, error) {
+	r := &Record{}
+	var err error
+	if r.agent, err = compile("agent", cfg.Agent, defaults.Agent); err != nil {
+		return nil, err
+	}
+	if r.topic, err = compile("topic", cfg.Topic, defaults.Topic); err != nil {
+		return nil, err
+	}
+	if r.name, err = compile("name", cfg.Name, defaults.Name); err != nil {
+		return nil, err
+	}
+	if r.source, err = compile("source", cfg.Source, defaults.Source); err != nil {
+		return nil, err
+	}
+	if r.payload, err = compileMap("payload", cfg.Payload); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Render evaluates the templates against data. Payload is left nil when no
+// payload mapping is configured so callers can supply their own default.
+func (r *Record) Render(data interface{}) (*servicenow.ECCQueuePayload, error) {
+	record := &servicenow.ECCQueuePayload{}
+	var err error
+	if record.Agent, err = execute(r.agent, data); err != nil {
+		return nil, err
+	}
+	if record.Topic, err = execute(r.topic, data); err != nil {
+		return nil, err
+	}
+	if record.Name, err = execute(r.name, data); err != nil {
+		return nil, err
+	}
+	if record.Source, err = execute(r.source, data); err != nil {
+		return nil, err
+	}
+	payload, err := executeMap(r.payload, data)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		record.Payload = payload
+	}
+	return record, nil
+}
+
+// Event renders Event Management events
+type Event struct {
+	source         *template.Template
+	node           *template.Template
+	eventType      *template.Template
+	resource       *template.Template
+	metricName     *template.Template
+	eventClass     *template.Template
+	severity       *template.Template
+	messageKey     *template.Template
+	description    *template.Template
+	additionalInfo map[string]*template.Template
+}
+
+// NewEvent compiles cfg, using defaults for any field cfg leaves empty
+func NewEvent(cfg, defaults config.EventConfig) (*Event, error) {
+	e := &Event{}
+	fields := []struct {
+		target   **template.Template
+		name     string
+		value    string
+		fallback string
+	}{
+		{&e.source, "source", cfg.Source, defaults.Source},
+		{&e.node, "node", cfg.Node, defaults.Node},
+		{&e.eventType, "type", cfg.Type, defaults.Type},
+		{&e.resource, "resource", cfg.Resource, defaults.Resource},
+		{&e.metricName, "metric_name", cfg.MetricName, defaults.MetricName},
+		{&e.eventClass, "event_class", cfg.EventClass, defaults.EventClass},
+		{&e.severity, "severity", cfg.Severity, defaults.Severity},
+		{&e.messageKey, "message_key", cfg.MessageKey, defaults.MessageKey},
+		{&e.description, "description", cfg.Description, defaults.Description},
+	}
+	for _, f := range fields {
+		tmpl, err := compile(f.name, f.value, f.fallback)
+		if err != nil {
+			return nil, err
+		}
+		*f.target = tmpl
+	}
+
+	info := cfg.AdditionalInfo
+	if len(info) == 0 {
+		info = defaults.AdditionalInfo
+	}
+	var err error
+	if e.additionalInfo, err = compileMap("additional_info", info); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Render evaluates the templates against data. The rendered severity is
+// normalised with SeverityCode, so templates may produce names like "critical".
+// AdditionalInfo is left nil when no mapping is configured.
+func (e *Event) Render(data interface{}) (*servicenow.Event, error) {
+	event := &servicenow.Event{}
+	fields := []struct {
+		tmpl   *template.Template
+		target *string
+	}{
+		{e.source, &event.Source},
+		{e.node, &event.Node},
+		{e.eventType, &event.Type},
+		{e.resource, &event.Resource},
+		{e.metricName, &event.MetricName},
+		{e.eventClass, &event.EventClass},
+		{e.severity, &event.Severity},
+		{e.messageKey, &event.MessageKey},
+		{e.description, &event.Description},
+	}
+	for _, f := range fields {
+		value, err := execute(f.tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		*f.target = strings.TrimSpace(value)
+	}
+	event.Severity = SeverityCode(event.Severity)
+
+	info, err := executeMap(e.additionalInfo, data)
+	if err != nil {
+		return nil, err
+	}
+	event.AdditionalInfo = info
+
+	return event, nil
+}
@@ -0,0 +1,536 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+)
+
+const (
+	agentActive = "active"
+	agentStale  = "stale"
+
+	// agentPayloadSummary caps the payload summary kept per agent
+	agentPayloadSummary = 256
+	// agentCheckInterval is how often stale agents are looked for and the
+	// registry is saved
+	agentCheckInterval = 5 * time.Second
+	// maxAgents caps the registry; agent and source come from the client, so
+	// the least recently seen agent is dropped to make room for a new one
+	maxAgents = 1000
+	// agentRetention is how long an agent that stopped reporting is kept
+	agentRetention = 7 * 24 * time.Hour
+)
+
+// AgentInfo is the registry entry of one agent, identified by its agent name
+// and source. Address is the IP the last record came from. Interval is the
+// reporting interval in seconds, declared in the payload ("interval") or
+// measured between records.
+type AgentInfo struct {
+	Agent       string    `json:"agent"`
+	Source      string    `json:"source"`
+	Address     string    `json:"address"`
+	Version     string    `json:"version,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Interval    int       `json:"interval"`
+	Declared    bool      `json:"interval_declared"`
+	Records     uint64    `json:"records"`
+	Failed      uint64    `json:"failed"`
+	LastTopic   string    `json:"last_topic"`
+	LastName    string    `json:"last_name"`
+	LastPayload string    `json:"last_payload"`
+	LastError   string    `json:"last_error,omitempty"`
+	Status      string    `json:"status"`
+	StaleSince  time.Time `json:"stale_since,omitzero"`
+}
+
+type agentEntry struct {
+	info      AgentInfo
+	recovered bool
+}
+
+// agentRegistry tracks the agents posting records and keeps them in a JSON
+// file so they survive restarts
+type agentRegistry struct {
+	mu     sync.Mutex
+	path   string
+	agents map[string]*agentEntry
+	dirty  bool
+}
+
+func newAgentRegistry(path string) *agentRegistry {
+	return &agentRegistry{
+		path:   path,
+		agents: make(map[string]*agentEntry),
+	}
+}
+
+func agentKey(agent, source string) string {
+	return agent + "@" + source
+}
+
+// load reads the registry file; a missing file is an empty registry
+func (a *agentRegistry) load() error {
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var agents []AgentInfo
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, info := range agents {
+		a.agents[agentKey(info.Agent, info.Source)] = &agentEntry{info: info}
+	}
+	for len(a.agents) > maxAgents {
+		a.evictOldestLocked()
+	}
+	return nil
+}
+
+// evictOldestLocked drops the least recently seen agent
+func (a *agentRegistry) evictOldestLocked() {
+	var oldest string
+	var oldestSeen time.Time
+	for key, entry := range a.agents {
+		if oldest == "" || entry.info.LastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = key, entry.info.LastSeen
+		}
+	}
+	if oldest != "" {
+		delete(a.agents, oldest)
+		a.dirty = true
+	}
+}
+
+// prune drops the agents not seen for agentRetention and returns how many
+func (a *agentRegistry) prune(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pruned := 0
+	for key, entry := range a.agents {
+		if now.Sub(entry.info.LastSeen) > agentRetention {
+			delete(a.agents, key)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		a.dirty = true
+	}
+	return pruned
+}
+
+// save writes the registry file when it changed since the last save
+func (a *agentRegistry) save() error {
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return nil
+	}
+	a.dirty = false
+	data, err := json.MarshalIndent(a.listLocked(), "", "  ")
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// observe updates the agent that sent a record posted over HTTP
+func (a *agentRegistry) observe(proxyReq *ProxyRequest, received time.Time, err error) {
+	address := proxyReq.RemoteAddr
+	if host, _, splitErr := net.SplitHostPort(address); splitErr == nil {
+		address = host
+	}
+	version, interval := agentMetadata(proxyReq.Payload)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := agentKey(proxyReq.Agent, proxyReq.Source)
+	entry := a.agents[key]
+	if entry == nil {
+		if len(a.agents) >= maxAgents {
+			a.evictOldestLocked()
+		}
+		entry = &agentEntry{info: AgentInfo{
+			Agent:     proxyReq.Agent,
+			Source:    proxyReq.Source,
+			FirstSeen: received,
+			Status:    agentActive,
+		}}
+		a.agents[key] = entry
+	}
+	info := &entry.info
+
+	switch {
+	case interval > 0:
+		info.Interval = interval
+		info.Declared = true
+	case !info.Declared && info.Status == agentActive && !info.LastSeen.IsZero():
+		// Records sent together (several topics, a stream) are one report
+		if gap := int(received.Sub(info.LastSeen).Round(time.Second).Seconds()); gap >= 1 {
+			if info.Interval == 0 {
+				info.Interval = gap
+			} else {
+				info.Interval = (3*info.Interval + gap) / 4
+			}
+		}
+	}
+
+	if info.Status == agentStale {
+		info.Status = agentActive
+		info.StaleSince = time.Time{}
+		entry.recovered = true
+	}
+	info.Address = address
+	if version != "" {
+		info.Version = version
+	}
+	info.LastSeen = received
+	info.Records++
+	info.LastTopic = proxyReq.Topic
+	info.LastName = proxyReq.Name
+	info.LastPayload = summarizePayload(proxyReq.Payload)
+	info.LastError = ""
+	if err != nil {
+		info.Failed++
+		info.LastError = err.Error()
+	}
+	a.dirty = true
+}
+
+// list returns the registry ordered by agent and source
+func (a *agentRegistry) list() []AgentInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.listLocked()
+}
+
+func (a *agentRegistry) listLocked() []AgentInfo {
+	agents := make([]AgentInfo, 0, len(a.agents))
+	for _, entry := range a.agents {
+		agents = append(agents, entry.info)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Agent != agents[j].Agent {
+			return agents[i].Agent < agents[j].Agent
+		}
+		return agents[i].Source < agents[j].Source
+	})
+	return agents
+}
+
+// agentTransition is an agent that went stale or recovered
+type agentTransition struct {
+	info   AgentInfo
+	missed int
+}
+
+// transitions marks the agents that missed missedIntervals intervals as stale
+// and returns them with the agents that recovered since the last call. Time
+// before since (when the server started) does not count as missed.
+func (a *agentRegistry) transitions(now, since time.Time, missedIntervals, defaultInterval int) []agentTransition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var changed []agentTransition
+	for _, entry := range a.agents {
+		info := &entry.info
+		if entry.recovered {
+			entry.recovered = false
+			changed = append(changed, agentTransition{info: *info})
+			continue
+		}
+
+		interval := info.Interval
+		if interval == 0 {
+			interval = defaultInterval
+		}
+		if info.Status != agentActive || interval == 0 {
+			continue
+		}
+		last := info.LastSeen
+		if last.Before(since) {
+			last = since
+		}
+		missed := int(now.Sub(last) / (time.Duration(interval) * time.Second))
+		if missed < missedIntervals {
+			continue
+		}
+		info.Status = agentStale
+		info.StaleSince = now
+		a.dirty = true
+		changed = append(changed, agentTransition{info: *info, missed: missed})
+	}
+	return changed
+}
+
+// agentMetadata looks for the agent version and declared interval (seconds)
+// at the top of the payload or one object below, where the bundled agent puts
+// them (endpoint_metrics.agent_version)
+func agentMetadata(payload interface{}) (version string, interval int) {
+	fields, ok := payload.(map[string]interface{})
+	if !ok {
+		return "", 0
+	}
+	scan := func(fields map[string]interface{}) {
+		for _, key := range []string{"agent_version", "version"} {
+			if v, ok := fields[key].(string); ok && version == "" {
+				version = v
+			}
+		}
+		if v, ok := fields["interval"].(float64); ok && interval == 0 && v >= 1 {
+			interval = int(v)
+		}
+	}
+	scan(fields)
+	for _, value := range fields {
+		if nested, ok := value.(map[string]interface{}); ok {
+			scan(nested)
+		}
+	}
+	return version, interval
+}
+
+// summarizePayload is the start of the payload as JSON
+func summarizePayload(payload interface{}) string {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	if len(data) > agentPayloadSummary {
+		n := agentPayloadSummary
+		for n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		return string(data[:n]) + "…"
+	}
+	return string(data)
+}
+
+// agentAlertData is passed to the agents record and event templates
+type agentAlertData struct {
+	Agent    string
+	Source   string
+	Address  string
+	Version  string
+	Status   string
+	Severity string
+	LastSeen string
+	Interval int
+	Missed   int
+}
+
+var defaultAgentRecord = config.RecordConfig{
+	Agent:  "litemidgo",
+	Topic:  "agentHeartbeat",
+	Name:   "{{.Agent}}@{{.Source}}",
+	Source: "{{.Source}}",
+}
+
+var defaultAgentEvent = config.EventConfig{
+	Source:     "LiteMIDgo",
+	Node:       "{{.Source}}",
+	Type:       "Agent Heartbeat",
+	Resource:   "{{.Agent}}",
+	Severity:   "{{.Severity}}",
+	MessageKey: "litemidgo-agent:{{.Agent}}@{{.Source}}",
+	Description: `{{if eq .Status "stale"}}Agent {{.Agent}} on {{.Source}} missed {{.Missed}} reporting intervals, last seen {{.LastSeen}}` +
+		`{{else}}Agent {{.Agent}} on {{.Source}} is reporting again{{end}}`,
+}
+
+// agentMonitor reports agents that go stale and recover, and saves the
+// registry periodically
+type agentMonitor struct {
+	server   *Server
+	config   *config.AgentsConfig
+	record   *mapping.Record
+	event    *mapping.Event
+	toECC    bool
+	toEvent  bool
+	started  time.Time
+	done     chan struct{}
+	finished chan struct{}
+}
+
+func (s *Server) startAgents() error {
+	cfg := &s.config.Agents
+	if err := s.agents.load(); err != nil {
+		return fmt.Errorf("failed to load agent registry %s: %w", s.agents.path, err)
+	}
+
+	monitor := &agentMonitor{
+		server:   s,
+		config:   cfg,
+		started:  time.Now(),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if cfg.Alerts {
+		var err error
+		if monitor.toECC, monitor.toEvent, err = parseTarget("agents target", cfg.Target); err != nil {
+			return err
+		}
+		if monitor.record, err = mapping.NewRecord(cfg.Record, defaultAgentRecord); err != nil {
+			return fmt.Errorf("invalid agents record mapping: %w", err)
+		}
+		if monitor.event, err = mapping.NewEvent(cfg.Event, defaultAgentEvent); err != nil {
+			return fmt.Errorf("invalid agents event mapping: %w", err)
+		}
+	}
+
+	go monitor.run()
+	s.agentMonitor = monitor
+	return nil
+}
+
+func (m *agentMonitor) run() {
+	defer close(m.finished)
+	ticker := time.NewTicker(agentCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			m.save()
+			return
+		case now := <-ticker.C:
+			for _, t := range m.server.agents.transitions(now, m.started, m.config.MissedIntervals, m.config.DefaultInterval) {
+				if t.info.Status == agentStale {
+					log.Printf("⚠️  Agent %s on %s is stale: no records for %d interval(s) of %ds", t.info.Agent, t.info.Source, t.missed, t.info.Interval)
+				} else {
+					log.Printf("✓ Agent %s on %s is reporting again", t.info.Agent, t.info.Source)
+				}
+				if m.config.Alerts {
+					if err := m.forward(t); err != nil {
+						log.Printf("Failed to forward agent %s alert for %s on %s: %v", t.info.Status, t.info.Agent, t.info.Source, err)
+					}
+				}
+			}
+			if n := m.server.agents.prune(now); n > 0 {
+				log.Printf("Removed %d agent(s) not seen for %s from the registry", n, agentRetention)
+			}
+			m.save()
+		}
+	}
+}
+
+func (m *agentMonitor) save() {
+	if err := m.server.agents.save(); err != nil {
+		log.Printf("Failed to save agent registry %s: %v", m.server.agents.path, err)
+	}
+}
+
+// stop saves the registry and waits for the monitor to finish
+func (m *agentMonitor) stop() {
+	close(m.done)
+	<-m.finished
+}
+
+func (m *agentMonitor) forward(t agentTransition) error {
+	data := agentAlertData{
+		Agent:    t.info.Agent,
+		Source:   t.info.Source,
+		Address:  t.info.Address,
+		Version:  t.info.Version,
+		Status:   t.info.Status,
+		Severity: "0",
+		LastSeen: t.info.LastSeen.UTC().Format(time.RFC3339),
+		Interval: t.info.Interval,
+		Missed:   t.missed,
+	}
+	if t.info.Status == agentStale {
+		data.Severity = "2"
+	} else {
+		data.Status = "recovered"
+	}
+
+	if m.toECC {
+		record, err := m.record.Render(data)
+		if err != nil {
+			return err
+		}
+		if record.Payload == nil {
+			record.Payload = map[string]interface{}{
+				"status":    data.Status,
+				"agent":     t.info,
+				"missed":    t.missed,
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+			}
+		}
+		if err := m.server.forwardRecord(record); err != nil {
+			return err
+		}
+	}
+
+	if m.toEvent {
+		event, err := m.event.Render(data)
+		if err != nil {
+			return err
+		}
+		if event.AdditionalInfo == nil {
+			event.AdditionalInfo = map[string]interface{}{
+				"agent_status":   data.Status,
+				"agent_address":  t.info.Address,
+				"agent_version":  t.info.Version,
+				"agent_interval": t.info.Interval,
+			}
+		}
+		if err := m.server.sendEvent(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleAgents lists the agent registry
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"agents":    s.agents.list(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// ReadAgents reads the agent registry file kept by a server, ordered by agent
+// and source
+func ReadAgents(path string) ([]AgentInfo, error) {
+	registry := newAgentRegistry(path)
+	if err := registry.load(); err != nil {
+		return nil, err
+	}
+	return registry.list(), nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAgentRegistryIsBounded(t *testing.T) {
+	registry := newAgentRegistry(filepath.Join(t.TempDir(), "agents.json"))
+	start := time.Now()
+	for i := 0; i < maxAgents+10; i++ {
+		registry.observe(&ProxyRequest{
+			Agent:      "agent",
+			Source:     fmt.Sprintf("host-%d", i),
+			RemoteAddr: "10.0.0.1:5000",
+		}, start.Add(time.Duration(i)*time.Second), nil)
+	}
+
+	agents := registry.list()
+	if len(agents) != maxAgents {
+		t.Fatalf("got %d agents, want %d", len(agents), maxAgents)
+	}
+	for _, info := range agents {
+		if info.Source == "host-0" || info.Source == "host-9" {
+			t.Errorf("%s was seen least recently and should have been evicted", info.Source)
+		}
+	}
+
+	// Everything but the last agent stopped reporting long ago
+	now := start.Add(time.Duration(maxAgents+9)*time.Second + agentRetention)
+	if n := registry.prune(now); n != maxAgents-1 {
+		t.Errorf("pruned %d agents, want %d", n, maxAgents-1)
+	}
+	if agents := registry.list(); len(agents) != 1 || agents[0].Source != fmt.Sprintf("host-%d", maxAgents+9) {
+		t.Errorf("got %v, want only the last agent", agents)
+	}
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+	"litemidgo/internal/servicenow"
+)
+
+// AlertmanagerWebhook is the Prometheus Alertmanager webhook payload (version 4)
+type AlertmanagerWebhook struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertData is passed to the record and event templates, one per alert
+type alertData struct {
+	Status            string
+	Receiver          string
+	GroupKey          string
+	Fingerprint       string
+	StartsAt          string
+	EndsAt            string
+	GeneratorURL      string
+	ExternalURL       string
+	Labels            map[string]string
+	Annotations       map[string]string
+	GroupLabels       map[string]string
+	CommonLabels      map[string]string
+	CommonAnnotations map[string]string
+}
+
+var defaultAlertmanagerRecord = config.RecordConfig{
+	Agent:  "litemidgo-alertmanager",
+	Topic:  "alertmanager",
+	Name:   "{{.Fingerprint}}",
+	Source: "{{.Labels.instance | default .Labels.job}}",
+}
+
+var defaultAlertmanagerEvent = config.EventConfig{
+	Source:      "Prometheus",
+	Node:        "{{.Labels.instance | default .Labels.job}}",
+	Type:        "{{.Labels.alertname}}",
+	Resource:    "{{.Labels.job}}",
+	Severity:    `{{.Labels.severity | default "warning"}}`,
+	MessageKey:  "{{.Fingerprint}}",
+	Description: "{{.Annotations.summary | default .Annotations.description | default .Labels.alertname}}",
+}
+
+const (
+	// alertRetryWindow is how long an ECC record sent for an alert is
+	// remembered, so that Alertmanager retrying a notification that failed
+	// part way does not insert it again. Repeat notifications come much later
+	// (repeat_interval, 4h by default) and are sent.
+	alertRetryWindow = 10 * time.Minute
+	// maxSentAlerts caps the records remembered within the window
+	maxSentAlerts = 10000
+)
+
+type alertmanagerReceiver struct {
+	record  *mapping.Record
+	event   *mapping.Event
+	toECC   bool
+	toEvent bool
+	mu      sync.Mutex
+	sent    map[string]time.Time
+}
+
+func (s *Server) startAlertmanager() error {
+	cfg := &s.config.Alertmanager
+	receiver := &alertmanagerReceiver{sent: make(map[string]time.Time)}
+
+	var err error
+	if receiver.toECC, receiver.toEvent, err = parseTarget("alertmanager target", cfg.Target); err != nil {
+		return err
+	}
+	if receiver.record, err = mapping.NewRecord(cfg.Record, defaultAlertmanagerRecord); err != nil {
+		return fmt.Errorf("invalid alertmanager record mapping: %w", err)
+	}
+	if receiver.event, err = mapping.NewEvent(cfg.Event, defaultAlertmanagerEvent); err != nil {
+		return fmt.Errorf("invalid alertmanager event mapping: %w", err)
+	}
+
+	s.alertmanager = receiver
+	return nil
+}
+
+// handleAlertmanagerWebhook accepts Alertmanager notifications. Each alert is
+// correlated by its fingerprint, so a resolved notification clears the event
+// opened by the firing one instead of creating a new alert in ServiceNow.
+func (s *Server) handleAlertmanagerWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var webhook AlertmanagerWebhook
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1048576)).Decode(&webhook); err != nil {
+		s.writeJSONResponse(w, http.StatusBadRequest, ProxyResponse{
+			Success:   false,
+			Message:   "Invalid JSON payload",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if webhook.Version != "4" {
+		s.writeJSONResponse(w, http.StatusBadRequest, ProxyResponse{
+			Success:   false,
+			Message:   fmt.Sprintf("Unsupported webhook version %q (expected 4)", webhook.Version),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	log.Printf("📥 Received %d %s alert(s) from Alertmanager receiver %s", len(webhook.Alerts), webhook.Status, webhook.Receiver)
+
+	if err := s.forwardAlerts(&webhook); err != nil {
+		log.Printf("Failed to forward Alertmanager alerts: %v", err)
+		// A 5xx makes Alertmanager retry the notification
+		s.writeJSONResponse(w, http.StatusInternalServerError, ProxyResponse{
+			Success:   false,
+			Message:   "Failed to send to ServiceNow",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, ProxyResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Forwarded %d alert(s) to ServiceNow", len(webhook.Alerts)),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// alertRecord is a rendered ECC record and the key it is remembered by
+type alertRecord struct {
+	key    string
+	record *servicenow.ECCQueuePayload
+}
+
+// forwardAlerts renders every record and event before sending any, so a
+// template error sends nothing. Records already sent within alertRetryWindow
+// are skipped: a failure returns 500 and Alertmanager retries the whole
+// group, and ECC records, unlike events, are not deduplicated by ServiceNow.
+func (s *Server) forwardAlerts(webhook *AlertmanagerWebhook) error {
+	receiver := s.alertmanager
+	var records []alertRecord
+	var events []servicenow.Event
+
+	for _, alert := range webhook.Alerts {
+		data := newAlertData(webhook, &alert)
+		resolved := alert.Status == "resolved"
+
+		// ECC records are inbound messages with no state: a resolved alert is a
+		// new record that a sensor matches to the firing one by name
+		// (the fingerprint by default) and payload status
+		if receiver.toECC {
+			record, err := receiver.record.Render(data)
+			if err != nil {
+				return err
+			}
+			if record.Payload == nil {
+				record.Payload = map[string]interface{}{
+					"status":        alert.Status,
+					"fingerprint":   data.Fingerprint,
+					"labels":        alert.Labels,
+					"annotations":   alert.Annotations,
+					"starts_at":     data.StartsAt,
+					"ends_at":       data.EndsAt,
+					"generator_url": alert.GeneratorURL,
+					"receiver":      webhook.Receiver,
+				}
+			}
+			key := strings.Join([]string{data.Fingerprint, alert.Status, data.StartsAt, data.EndsAt}, "|")
+			records = append(records, alertRecord{key: key, record: record})
+		}
+
+		if receiver.toEvent {
+			event, err := receiver.event.Render(data)
+			if err != nil {
+				return err
+			}
+			event.TimeOfEvent = alertTime(alert.StartsAt)
+			if resolved {
+				// Clearing the severity closes the alert with the same message key
+				event.Severity = "0"
+				event.TimeOfEvent = alertTime(alert.EndsAt)
+			}
+			if event.AdditionalInfo == nil {
+				info := make(map[string]interface{}, len(alert.Labels)+len(alert.Annotations)+2)
+				for k, v := range alert.Labels {
+					info[k] = v
+				}
+				for k, v := range alert.Annotations {
+					info[k] = v
+				}
+				info["alert_status"] = alert.Status
+				info["generator_url"] = alert.GeneratorURL
+				event.AdditionalInfo = info
+			}
+			events = append(events, *event)
+		}
+	}
+
+	for _, r := range records {
+		if receiver.wasSent(r.key) {
+			continue
+		}
+		if err := s.forwardRecord(r.record); err != nil {
+			return err
+		}
+		receiver.markSent(r.key)
+	}
+
+	// Events are correlated by message key, so sending them again is harmless
+	if len(events) > 0 {
+		if _, err := s.snowClient.SendEvents(events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *alertmanagerReceiver) wasSent(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sent, ok := a.sent[key]
+	return ok && time.Since(sent) < alertRetryWindow
+}
+
+// markSent remembers a sent record, forgetting those older than the window
+func (a *alertmanagerReceiver) markSent(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if len(a.sent) >= maxSentAlerts {
+		for k, sent := range a.sent {
+			if now.Sub(sent) >= alertRetryWindow {
+				delete(a.sent, k)
+			}
+		}
+		if len(a.sent) >= maxSentAlerts {
+			return
+		}
+	}
+	a.sent[key] = now
+}
+
+func newAlertData(webhook *AlertmanagerWebhook, alert *AlertmanagerAlert) alertData {
+	fingerprint := alert.Fingerprint
+	if fingerprint == "" {
+		fingerprint = labelsFingerprint(alert.Labels)
+	}
+
+	return alertData{
+		Status:            alert.Status,
+		Receiver:          webhook.Receiver,
+		GroupKey:          webhook.GroupKey,
+		Fingerprint:       fingerprint,
+		StartsAt:          alertTime(alert.StartsAt),
+		EndsAt:            alertTime(alert.EndsAt),
+		GeneratorURL:      alert.GeneratorURL,
+		ExternalURL:       webhook.ExternalURL,
+		Labels:            alert.Labels,
+		Annotations:       alert.Annotations,
+		GroupLabels:       webhook.GroupLabels,
+		CommonLabels:      webhook.CommonLabels,
+		CommonAnnotations: webhook.CommonAnnotations,
+	}
+}
+
+// labelsFingerprint stands in for the fingerprint older Alertmanager releases
+// do not send; it is stable for a given label set.
+func labelsFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\xff%s\xff", name, labels[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// alertTime formats a timestamp the way ServiceNow expects; Alertmanager uses
+// the zero time for alerts that have not ended.
+func alertTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"litemidgo/config"
+)
+
+func TestAlertmanagerRetryDoesNotDuplicateRecords(t *testing.T) {
+	s, upstream := newTestServer(t, func(cfg *config.Config) {
+		cfg.Alertmanager.Target = "both"
+	})
+	if err := s.startAlertmanager(); err != nil {
+		t.Fatalf("startAlertmanager: %v", err)
+	}
+
+	started := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	webhook := &AlertmanagerWebhook{
+		Version:  "4",
+		Status:   "firing",
+		Receiver: "servicenow",
+		Alerts: []AlertmanagerAlert{
+			{Status: "firing", Fingerprint: "aaa", StartsAt: started, Labels: map[string]string{"alertname": "DiskFull", "job": "node"}},
+			{Status: "firing", Fingerprint: "bbb", StartsAt: started, Labels: map[string]string{"alertname": "CPUHigh", "job": "node"}},
+		},
+	}
+
+	// The records go out, then the events fail: Alertmanager retries the group
+	upstream.failNext("/api/global/em/jsonv2", 1)
+	if err := s.forwardAlerts(webhook); err == nil {
+		t.Fatal("forwardAlerts: expected the event failure to be returned")
+	}
+	if n := len(upstream.Store().Records("ecc_queue")); n != 2 {
+		t.Fatalf("got %d ECC records after the failed attempt, want 2", n)
+	}
+
+	if err := s.forwardAlerts(webhook); err != nil {
+		t.Fatalf("forwardAlerts retry: %v", err)
+	}
+	if n := len(upstream.Store().Records("ecc_queue")); n != 2 {
+		t.Errorf("got %d ECC records after the retry, want 2", n)
+	}
+	if n := len(upstream.Store().Records("em_event")); n != 2 {
+		t.Errorf("got %d events, want 2", n)
+	}
+
+	// The resolution is a new notification and is sent
+	webhook.Alerts[0].Status = "resolved"
+	webhook.Alerts[0].EndsAt = started.Add(time.Hour)
+	webhook.Alerts = webhook.Alerts[:1]
+	if err := s.forwardAlerts(webhook); err != nil {
+		t.Fatalf("forwardAlerts resolved: %v", err)
+	}
+	if n := len(upstream.Store().Records("ecc_queue")); n != 3 {
+		t.Errorf("got %d ECC records after the resolution, want 3", n)
+	}
+}
+
+func TestAlertmanagerTemplateErrorSendsNothing(t *testing.T) {
+	s, upstream := newTestServer(t, func(cfg *config.Config) {
+		cfg.Alertmanager.Target = "ecc"
+		cfg.Alertmanager.Record.Name = `{{if eq .Labels.alertname "Bad"}}{{.Missing.Field}}{{else}}{{.Fingerprint}}{{end}}`
+	})
+	if err := s.startAlertmanager(); err != nil {
+		t.Fatalf("startAlertmanager: %v", err)
+	}
+
+	webhook := &AlertmanagerWebhook{Version: "4", Alerts: []AlertmanagerAlert{
+		{Status: "firing", Fingerprint: "aaa", Labels: map[string]string{"alertname": "Good"}},
+		{Status: "firing", Fingerprint: "bbb", Labels: map[string]string{"alertname": "Bad"}},
+	}}
+	if err := s.forwardAlerts(webhook); err == nil {
+		t.Fatal("forwardAlerts: expected a template error")
+	}
+	if n := len(upstream.Store().Records("ecc_queue")); n != 0 {
+		t.Errorf("got %d ECC records, want none sent before the template error", n)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"litemidgo/internal/servicenow"
+)
+
+type AttachmentResponse struct {
+	Success     bool                    `json:"success"`
+	Message     string                  `json:"message"`
+	Table       string                  `json:"table,omitempty"`
+	SysID       string                  `json:"sys_id,omitempty"`
+	Rule        string                  `json:"rule,omitempty"`
+	Attachments []servicenow.Attachment `json:"attachments,omitempty"`
+	Timestamp   string                  `json:"timestamp"`
+}
+
+// handleAttachmentUpload streams multipart file uploads to the Attachment
+// API. Form fields must precede the files: table and sys_id select the record
+// to attach to; without them an ECC queue record is created first (agent,
+// topic, name and source fields fill it in) and the files are attached to it.
+// Restricted clients may only attach to the record they create.
+func (s *Server) handleAttachmentUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Uploads may take longer than the server's read/write timeouts; the
+	// read deadline moves forward as the body arrives, so only a stalled
+	// client is cut off
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	r.Body = &deadlineBody{ReadCloser: r.Body, rc: rc}
+
+	if s.config.Attachments.MaxUploadBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.Attachments.MaxUploadBytes)
+	}
+
+	response := AttachmentResponse{}
+	fail := func(statusCode int, message string) {
+		response.Success = false
+		response.Message = message
+		response.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		s.writeJSONResponse(w, statusCode, response)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		fail(http.StatusBadRequest, "Expected a multipart/form-data upload")
+		return
+	}
+
+	var record ProxyRequest
+	created := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(http.StatusBadRequest, fmt.Sprintf("Invalid multipart body: %v", err))
+			return
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 4096))
+			if err != nil {
+				fail(http.StatusBadRequest, "Invalid form field")
+				return
+			}
+			switch part.FormName() {
+			case "table":
+				response.Table = string(value)
+			case "sys_id":
+				response.SysID = string(value)
+			case "agent":
+				record.Agent = string(value)
+			case "topic":
+				record.Topic = string(value)
+			case "name":
+				record.Name = string(value)
+			case "source":
+				record.Source = string(value)
+			}
+			continue
+		}
+
+		if response.SysID == "" {
+			if response.Table != "" && response.Table != "ecc_queue" {
+				fail(http.StatusBadRequest, "sys_id is required when table is set")
+				return
+			}
+			s.applyDefaults(&record, r)
+			if perr := s.authorizeRecord(r, &record); perr != nil {
+				response.Rule = perr.Rule
+				fail(http.StatusForbidden, "Forbidden: "+perr.Error())
+				return
+			}
+			record.Payload = map[string]interface{}{"attachment": part.FileName()}
+			eccResp, err := s.forwardToECC(&record)
+			if err != nil {
+				log.Printf("Failed to create ECC record for attachment: %v", err)
+				fail(http.StatusInternalServerError, "Failed to send to ServiceNow")
+				return
+			}
+			response.Table = "ecc_queue"
+			response.SysID = eccResp.Result.SysID
+			created = true
+		} else if client := requestClient(r); client != nil && !created {
+			// Restricted clients cannot reach records they did not create
+			response.Rule = "attachments"
+			fail(http.StatusForbidden, fmt.Sprintf("Forbidden: client %q may only attach files to a new ECC record", client.Username))
+			return
+		}
+
+		attachment, err := s.snowClient.UploadAttachment(r.Context(), response.Table, response.SysID, part.FileName(), part.Header.Get("Content-Type"), part, s.config.Attachments.MaxUploadBytes)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds %d bytes", maxBytesErr.Limit))
+				return
+			}
+			log.Printf("Failed to upload attachment %s: %v", part.FileName(), err)
+			fail(http.StatusInternalServerError, "Failed to send to ServiceNow")
+			return
+		}
+		log.Printf("📎 Attached %s (%s bytes) to %s/%s", attachment.FileName, attachment.SizeBytes, response.Table, response.SysID)
+		response.Attachments = append(response.Attachments, *attachment)
+	}
+
+	if len(response.Attachments) == 0 {
+		fail(http.StatusBadRequest, "No files in upload")
+		return
+	}
+
+	response.Success = true
+	response.Message = fmt.Sprintf("Uploaded %d attachment(s) to ServiceNow", len(response.Attachments))
+	response.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// uploadIdleTimeout is how long an upload body may stall before the read fails
+const uploadIdleTimeout = 30 * time.Second
+
+// deadlineBody extends the connection read deadline before every read
+type deadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	b.rc.SetReadDeadline(time.Now().Add(uploadIdleTimeout))
+	return b.ReadCloser.Read(p)
+}
+
+// offloadToAttachment sends the ECC record with a small placeholder payload
+// and uploads the full payload as payload.json attached to it.
+func (s *Server) offloadToAttachment(eccPayload *servicenow.ECCQueuePayload, data []byte) (*servicenow.ECCQueueResponse, error) {
+	original := eccPayload.Payload
+	eccPayload.Payload = map[string]interface{}{
+		"attachment": "payload.json",
+		"size_bytes": len(data),
+	}
+
+	eccResp, err := s.snowClient.SendToECCQueue(eccPayload)
+	eccPayload.Payload = original
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.snowClient.UploadAttachment(context.Background(), "ecc_queue", eccResp.Result.SysID, "payload.json", "application/json", bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("failed to attach payload to ECC record %s: %w", eccResp.Result.SysID, err)
+	}
+
+	log.Printf("📎 Offloaded %d byte payload to attachment on ECC record %s", len(data), eccResp.Result.SysID)
+	return eccResp, nil
+}
+
+// payloadForOffload returns the encoded payload when it is larger than the
+// configured offload threshold
+func (s *Server) payloadForOffload(payload interface{}) ([]byte, bool) {
+	threshold := s.config.Attachments.OffloadThreshold
+	if threshold <= 0 {
+		return nil, false
+	}
+	data, err := json.Marshal(payload)
+	if err != nil || len(data) <= threshold {
+		return nil, false
+	}
+	return data, true
+}
@@ -1,12 +1,26 @@
 package server
 
 import (
+	"context"
 	"crypto/subtle"
+	"fmt"
+	"log"
 	"net/http"
+
+	"litemidgo/config"
 )
 
-// BasicAuth middleware for protecting endpoints
+// BasicAuth middleware for protecting endpoints. Only the server.auth
+// credentials are accepted; restricted clients are refused with 403.
 func (s *Server) BasicAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.basicAuth(next, false)
+}
+
+// basicAuth authenticates the server.auth credentials and, when allowClients
+// is set, the restricted clients, whose policy is added to the request context.
+// The server.auth credentials are exempt from every client policy, so the
+// first record sent with them while clients are configured is logged.
+func (s *Server) basicAuth(next http.HandlerFunc, allowClients bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication for health endpoint
 		if r.URL.Path == "/health" {
@@ -25,17 +39,42 @@ func (s *Server) BasicAuth(next http.HandlerFunc) http.HandlerFunc {
 		validUsername := s.config.Server.Auth.Username
 		validPassword := s.config.Server.Auth.Password
 
-		if subtle.ConstantTimeCompare([]byte(username), []byte(validUsername)) != 1 ||
-			subtle.ConstantTimeCompare([]byte(password), []byte(validPassword)) != 1 {
+		if subtle.ConstantTimeCompare([]byte(username), []byte(validUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(validPassword)) == 1 {
+			if allowClients && len(s.config.Server.Auth.Clients) > 0 && s.adminRecordsWarned.CompareAndSwap(false, true) {
+				log.Printf("⚠️  %s sent records with the server.auth credentials, which no client policy applies to; give each agent its own server.auth.clients entry", r.RemoteAddr)
+			}
+			next(w, r)
+			return
+		}
+
+		client := s.findClient(username, password)
+		if client == nil {
 			w.Header().Set("WWW-Authenticate", `Basic realm="LiteMIDgo"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		if !allowClients {
+			http.Error(w, fmt.Sprintf("Forbidden: client %q may only send ECC records", client.Username), http.StatusForbidden)
+			return
+		}
 
-		next(w, r)
+		next(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, client)))
 	}
 }
 
+// findClient returns the restricted client with these credentials, if any
+func (s *Server) findClient(username, password string) *config.ClientConfig {
+	for i := range s.config.Server.Auth.Clients {
+		client := &s.config.Server.Auth.Clients[i]
+		if subtle.ConstantTimeCompare([]byte(username), []byte(client.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(client.Password)) == 1 {
+			return client
+		}
+	}
+	return nil
+}
+
 // SecurityHeaders middleware for adding security headers
 func (s *Server) SecurityHeaders(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -48,3 +87,20 @@ func (s *Server) SecurityHeaders(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// protect applies security headers and, when enabled, basic authentication
+func (s *Server) protect(next http.HandlerFunc) http.HandlerFunc {
+	if s.config.Server.Auth.Enabled {
+		return s.SecurityHeaders(s.BasicAuth(next))
+	}
+	return s.SecurityHeaders(next)
+}
+
+// protectRecords is protect for the endpoints that send ECC records, which
+// also accept the restricted clients; see authorizeRecord
+func (s *Server) protectRecords(next http.HandlerFunc) http.HandlerFunc {
+	if s.config.Server.Auth.Enabled {
+		return s.SecurityHeaders(s.basicAuth(next, true))
+	}
+	return s.SecurityHeaders(next)
+}
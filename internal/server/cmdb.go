@@ -0,0 +1,270 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"litemidgo/internal/cmdb"
+	"litemidgo/internal/servicenow"
+)
+
+const (
+	// cmdbQueueSize and cmdbWorkers bound the reconciliations of ingested
+	// records waiting for and in flight to the IRE; more are dropped
+	cmdbQueueSize = 256
+	cmdbWorkers   = 2
+)
+
+// CMDBStats counts the reconciliations of ingested records since start
+type CMDBStats struct {
+	Queued     uint64 `json:"queued"`
+	Reconciled uint64 `json:"reconciled"`
+	Failed     uint64 `json:"failed"`
+	Dropped    uint64 `json:"dropped"`
+}
+
+// cmdbReconciler reconciles the inventory of ingested records in the
+// background with a fixed number of workers
+type cmdbReconciler struct {
+	queue      chan *cmdb.EndpointMetrics
+	done       chan struct{}
+	wg         sync.WaitGroup
+	queued     atomic.Uint64
+	reconciled atomic.Uint64
+	failed     atomic.Uint64
+	dropped    atomic.Uint64
+}
+
+func (s *Server) startCMDB() {
+	reconciler := &cmdbReconciler{
+		queue: make(chan *cmdb.EndpointMetrics, cmdbQueueSize),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < cmdbWorkers; i++ {
+		reconciler.wg.Add(1)
+		go s.reconcileLoop(reconciler)
+	}
+	s.cmdb = reconciler
+}
+
+// stop waits for the reconciliations in flight; queued ones are dropped
+func (c *cmdbReconciler) stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+func (c *cmdbReconciler) Stats() CMDBStats {
+	return CMDBStats{
+		Queued:     c.queued.Load(),
+		Reconciled: c.reconciled.Load(),
+		Failed:     c.failed.Load(),
+		Dropped:    c.dropped.Load(),
+	}
+}
+
+// CIResult reports what the IRE did with one CI
+type CIResult struct {
+	ClassName string   `json:"class_name"`
+	Name      string   `json:"name"`
+	Operation string   `json:"operation"`
+	SysID     string   `json:"sys_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+type CMDBResponse struct {
+	Success    bool                   `json:"success"`
+	Message    string                 `json:"message"`
+	DataSource string                 `json:"data_source"`
+	DryRun     bool                   `json:"dry_run"`
+	Items      []CIResult             `json:"items,omitempty"`
+	Payload    *servicenow.IREPayload `json:"payload,omitempty"`
+	Timestamp  string                 `json:"timestamp"`
+}
+
+// reconcile sends a host's inventory to the IRE and pairs each result with
+// the CI it was generated from.
+func (s *Server) reconcile(metrics *cmdb.EndpointMetrics, dryRun bool) (*servicenow.IREPayload, []CIResult, error) {
+	payload := cmdb.ToIRE(metrics, s.config.CMDB.ClassName)
+
+	ireResp, err := s.snowClient.IdentifyReconcile(payload, s.config.CMDB.DataSource, dryRun)
+	if err != nil {
+		return payload, nil, err
+	}
+
+	results := make([]CIResult, len(payload.Items))
+	for i, item := range payload.Items {
+		results[i] = CIResult{
+			ClassName: item.ClassName,
+			Name:      fmt.Sprint(item.Values["name"]),
+		}
+		if i >= len(ireResp.Result.Items) {
+			continue
+		}
+		itemResult := ireResp.Result.Items[i]
+		results[i].Operation = itemResult.Operation
+		results[i].SysID = itemResult.SysID
+		for _, ireErr := range itemResult.Errors {
+			results[i].Errors = append(results[i].Errors, fmt.Sprintf("%s: %s", ireErr.ErrorType, ireErr.Message))
+		}
+	}
+	return payload, results, nil
+}
+
+// reconcileRecord queues inventory found in an ingested record for
+// reconciliation in the background, dropping it when the queue is full.
+func (s *Server) reconcileRecord(proxyReq *ProxyRequest) {
+	metrics, ok, err := cmdb.FindEndpointMetrics(proxyReq.Payload)
+	if !ok {
+		return
+	}
+	if err != nil {
+		log.Printf("Skipping CMDB reconciliation for %s: %v", proxyReq.Source, err)
+		return
+	}
+
+	select {
+	case s.cmdb.queue <- metrics:
+		s.cmdb.queued.Add(1)
+	default:
+		s.cmdb.dropped.Add(1)
+	}
+}
+
+// reconcileLoop reconciles queued inventory, logging the outcome for each CI
+func (s *Server) reconcileLoop(c *cmdbReconciler) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case metrics := <-c.queue:
+			_, results, err := s.reconcile(metrics, s.config.CMDB.DryRun)
+			if err != nil {
+				c.failed.Add(1)
+				log.Printf("CMDB reconciliation failed for %s: %v", metrics.Hostname, err)
+				continue
+			}
+			c.reconciled.Add(1)
+			for _, result := range results {
+				if len(result.Errors) > 0 {
+					log.Printf("🗄️  CMDB %s %s: %v", result.ClassName, result.Name, result.Errors)
+					continue
+				}
+				log.Printf("🗄️  CMDB %s %s: %s %s", result.ClassName, result.Name, result.Operation, result.SysID)
+			}
+		}
+	}
+}
+
+func (s *Server) handleCMDBStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.cmdb == nil {
+		response := ProxyResponse{
+			Success:   false,
+			Message:   "CMDB reconciliation of ingested records is not enabled",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		s.writeJSONResponse(w, http.StatusNotFound, response)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"stats":     s.cmdb.Stats(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// handleCMDBReconcile reconciles a single agent inventory and reports the
+// result per CI. The body is an agent record (payload.endpoint_metrics) or
+// {"endpoint_metrics": {...}}; ?dry_run=true only asks the IRE how the CIs
+// would be identified and echoes the generated payload.
+func (s *Server) handleCMDBReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun := s.config.CMDB.DryRun
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			s.writeJSONResponse(w, http.StatusBadRequest, ProxyResponse{
+				Success:   false,
+				Message:   "dry_run must be true or false",
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		dryRun = parsed
+	}
+
+	// Limit request size to prevent DoS attacks
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.writeJSONResponse(w, http.StatusBadRequest, ProxyResponse{
+			Success:   false,
+			Message:   "Invalid JSON payload",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var payload interface{} = body
+	if inner, ok := body["payload"]; ok {
+		payload = inner
+	}
+	metrics, ok, err := cmdb.FindEndpointMetrics(payload)
+	if !ok || err != nil {
+		message := "Payload has no endpoint_metrics"
+		if err != nil {
+			message = err.Error()
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, ProxyResponse{
+			Success:   false,
+			Message:   message,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	irePayload, results, err := s.reconcile(metrics, dryRun)
+	response := CMDBResponse{
+		DataSource: s.config.CMDB.DataSource,
+		DryRun:     dryRun,
+		Items:      results,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	}
+	if dryRun {
+		response.Payload = irePayload
+	}
+	if err != nil {
+		log.Printf("CMDB reconciliation failed for %s: %v", metrics.Hostname, err)
+		response.Message = "Failed to send to ServiceNow"
+		s.writeJSONResponse(w, http.StatusInternalServerError, response)
+		return
+	}
+
+	response.Success = true
+	response.Message = fmt.Sprintf("Reconciled %d CI(s) for %s", len(results), metrics.Hostname)
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			response.Success = false
+			response.Message = fmt.Sprintf("Reconciliation of %s reported errors", metrics.Hostname)
+			break
+		}
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
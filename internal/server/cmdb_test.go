@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/cmdb"
+)
+
+func inventoryRecord(hostname string) *ProxyRequest {
+	return &ProxyRequest{
+		Agent:  "litemidgo-agent",
+		Topic:  "endpointData",
+		Source: hostname,
+		Payload: map[string]interface{}{
+			"endpoint_metrics": map[string]interface{}{"hostname": hostname},
+		},
+	}
+}
+
+func TestReconcileRecordDropsWhenSaturated(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	// No workers: the queue fills up
+	s.cmdb = &cmdbReconciler{queue: make(chan *cmdb.EndpointMetrics, cmdbQueueSize)}
+
+	for i := 0; i < cmdbQueueSize+5; i++ {
+		s.reconcileRecord(inventoryRecord("web01"))
+	}
+	if stats := s.cmdb.Stats(); stats.Queued != cmdbQueueSize || stats.Dropped != 5 {
+		t.Errorf("got %+v, want %d queued and 5 dropped", stats, cmdbQueueSize)
+	}
+}
+
+func TestReconcileRecordInBackground(t *testing.T) {
+	s, upstream := newTestServer(t, func(cfg *config.Config) {
+		cfg.CMDB.DataSource = "LiteMIDgo"
+	})
+	s.startCMDB()
+
+	s.reconcileRecord(inventoryRecord("web01"))
+	s.reconcileRecord(&ProxyRequest{Payload: map[string]interface{}{"cpu": 1}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.cmdb.Stats().Reconciled+s.cmdb.Stats().Failed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("inventory was not reconciled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.cmdb.stop()
+
+	if stats := s.cmdb.Stats(); stats.Queued != 1 || stats.Reconciled != 1 {
+		t.Errorf("got %+v, want 1 queued and reconciled", stats)
+	}
+	if n := len(upstream.Store().Records("cmdb_ci_linux_server")) + len(upstream.Store().Records("cmdb_ci_computer")); n != 1 {
+		t.Errorf("instance has %d host CIs, want 1", n)
+	}
+}
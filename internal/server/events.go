@@ -0,0 +1,264 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+	"litemidgo/internal/servicenow"
+)
+
+// defaultRecordEvent maps an ingested ECC record to an event when
+// events.output includes "event" and no events.event mapping is configured
+var defaultRecordEvent = config.EventConfig{
+	Source:      "{{.Agent}}",
+	Node:        "{{.Source}}",
+	Type:        "{{.Topic}}",
+	Resource:    "{{.Name}}",
+	Severity:    "5",
+	MessageKey:  "{{.Agent}}:{{.Topic}}:{{.Name}}:{{.Source}}",
+	Description: "{{.Topic}} from {{.Source}}",
+}
+
+// startEvents sets up the event batcher and the record-to-event mapping used
+// by ingest.
+func (s *Server) startEvents() error {
+	cfg := &s.config.Events
+
+	var err error
+	if s.eccOutput, s.eventOutput, err = parseTarget("events output", cfg.Output); err != nil {
+		return err
+	}
+	if s.recordEvent, err = mapping.NewEvent(cfg.Event, defaultRecordEvent); err != nil {
+		return fmt.Errorf("invalid events mapping: %w", err)
+	}
+
+	s.events = servicenow.NewEventBatcher(s.snowClient, cfg.BatchSize, cfg.MaxPending, time.Duration(cfg.FlushInterval)*time.Second)
+	return nil
+}
+
+// ingest delivers a record posted to the ECC endpoints or produced by a
+// receiver according to events.output. The sys_id is only known for records
+// written to the ECC queue; events are queued and sent in batches. Agent
+// inventory is also reconciled into the CMDB when cmdb.enabled is set, and
+// the agents posting over HTTP are tracked in the agent registry.
+func (s *Server) ingest(proxyReq *ProxyRequest) (sysID string, err error) {
+	var upstream time.Duration
+	var response interface{}
+	received := time.Now()
+	s.stats.begin()
+	defer func() {
+		s.stats.done(proxyReq.Topic, upstream, err)
+		entry := RequestLogEntry{
+			Time:     received,
+			Source:   proxyReq.Source,
+			Agent:    proxyReq.Agent,
+			Topic:    proxyReq.Topic,
+			Name:     proxyReq.Name,
+			Success:  err == nil,
+			Latency:  time.Since(received),
+			SysID:    sysID,
+			Payload:  logBody(proxyReq.Payload),
+			Response: logBody(response),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		s.requestLog.add(entry)
+		if proxyReq.RemoteAddr != "" {
+			s.agents.observe(proxyReq, received, err)
+		}
+	}()
+
+	if s.eccOutput {
+		start := time.Now()
+		eccResp, err := s.forwardToECC(proxyReq)
+		upstream = time.Since(start)
+		if err != nil {
+			return "", err
+		}
+		sysID = eccResp.Result.SysID
+		response = eccResp.Result
+	}
+
+	if s.eventOutput {
+		event, err := s.recordEvent.Render(proxyReq)
+		if err != nil {
+			return sysID, fmt.Errorf("failed to map record to event: %w", err)
+		}
+		if event.AdditionalInfo == nil {
+			if payload, ok := proxyReq.Payload.(map[string]interface{}); ok {
+				event.AdditionalInfo = payload
+			} else {
+				event.AdditionalInfo = map[string]interface{}{"payload": proxyReq.Payload}
+			}
+		}
+		if !s.events.Add(*event) {
+			// A record already written to the ECC queue is not failed, or the
+			// client would send it again
+			if !s.eccOutput {
+				return sysID, errEventQueueFull
+			}
+			log.Printf("Event queue full, dropped the event for %s record %s", proxyReq.Topic, proxyReq.Name)
+		}
+	}
+
+	if s.cmdb != nil {
+		s.reconcileRecord(proxyReq)
+	}
+
+	return sysID, nil
+}
+
+// EventResult is the outcome of one event of a /proxy/em_event request;
+// Index is its position in the request
+type EventResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// EventsResponse answers a /proxy/em_event request that was only partly sent
+type EventsResponse struct {
+	ProxyResponse
+	Sent    int           `json:"sent"`
+	Failed  int           `json:"failed"`
+	Results []EventResult `json:"results"`
+}
+
+// handleEMEventProxy sends events straight to the Event Management events API.
+// The body may be a single event, an array of events or {"records": [...]}.
+// Events go out in batches of events.batch_size; when only some batches are
+// sent the response is 207 with a result per event, so the client can resend
+// just the failed ones.
+func (s *Server) handleEMEventProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Limit request size to prevent DoS attacks
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	events, err := decodeEvents(r)
+	if err != nil {
+		response := ProxyResponse{
+			Success:   false,
+			Message:   fmt.Sprintf("Invalid event payload: %v", err),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	for i := range events {
+		if events[i].Source == "" {
+			events[i].Source = "litemidgo"
+		}
+		if events[i].Node == "" {
+			events[i].Node = r.RemoteAddr
+		}
+		events[i].Severity = mapping.SeverityCode(events[i].Severity)
+	}
+
+	batchSize := s.config.Events.BatchSize
+	if batchSize < 1 {
+		batchSize = 100
+	}
+	results := make([]EventResult, len(events))
+	sent := 0
+	for start := 0; start < len(events); start += batchSize {
+		end := min(start+batchSize, len(events))
+		_, err := s.snowClient.SendEvents(events[start:end])
+		if err != nil {
+			log.Printf("Failed to send events %d-%d of %d to ServiceNow: %v", start+1, end, len(events), err)
+		} else {
+			sent += end - start
+		}
+		for i := start; i < end; i++ {
+			results[i] = EventResult{Index: i, Success: err == nil, Message: "Sent to ServiceNow"}
+			if err != nil {
+				results[i].Message = fmt.Sprintf("Failed to send to ServiceNow: %v", err)
+			}
+		}
+	}
+
+	switch {
+	case sent == 0:
+		// Nothing was sent, so the client can safely send everything again
+		s.writeJSONResponse(w, http.StatusInternalServerError, ProxyResponse{
+			Success:   false,
+			Message:   fmt.Sprintf("Failed to send %d event(s) to ServiceNow", len(events)),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	case sent < len(events):
+		// Resending the whole request would duplicate the events already sent
+		s.writeJSONResponse(w, http.StatusMultiStatus, EventsResponse{
+			ProxyResponse: ProxyResponse{
+				Success:   false,
+				Message:   fmt.Sprintf("Sent %d of %d event(s) to ServiceNow", sent, len(events)),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+			Sent:    sent,
+			Failed:  len(events) - sent,
+			Results: results,
+		})
+	default:
+		s.writeJSONResponse(w, http.StatusOK, ProxyResponse{
+			Success:   true,
+			Message:   fmt.Sprintf("Sent %d event(s) to ServiceNow", len(events)),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+func decodeEvents(r *http.Request) ([]servicenow.Event, error) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+
+	var events []servicenow.Event
+	switch trimmed := bytes.TrimSpace(body); {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, err
+		}
+	default:
+		var batch struct {
+			Records []servicenow.Event `json:"records"`
+		}
+		if err := json.Unmarshal(trimmed, &batch); err == nil && batch.Records != nil {
+			events = batch.Records
+			break
+		}
+		var event servicenow.Event
+		if err := json.Unmarshal(trimmed, &event); err != nil {
+			return nil, err
+		}
+		events = []servicenow.Event{event}
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events")
+	}
+	return events, nil
+}
+
+func (s *Server) handleEventStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"output":    s.config.Events.Output,
+		"stats":     s.events.Stats(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
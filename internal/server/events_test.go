@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"litemidgo/config"
+)
+
+func postEvents(s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/proxy/em_event", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.handleEMEventProxy(rec, req)
+	return rec
+}
+
+func TestEMEventProxyPartialFailure(t *testing.T) {
+	s, upstream := newTestServer(t, func(cfg *config.Config) {
+		cfg.Events.BatchSize = 2
+	})
+	body := `[{"node":"a"},{"node":"b"},{"node":"c"},{"node":"d"},{"node":"e"}]`
+
+	upstream.failNext("/api/global/em/jsonv2", 1)
+	rec := postEvents(s, body)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("got status %d, want 207: %s", rec.Code, rec.Body)
+	}
+	var resp EventsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Sent != 3 || resp.Failed != 2 || len(resp.Results) != 5 {
+		t.Fatalf("got %+v, want 3 sent and 2 failed", resp)
+	}
+	for i, result := range resp.Results {
+		if want := i >= 2; result.Index != i || result.Success != want {
+			t.Errorf("result %d = %+v, want success %v", i, result, want)
+		}
+	}
+	if n := len(upstream.Store().Records("em_event")); n != 3 {
+		t.Errorf("instance has %d events, want 3", n)
+	}
+
+	upstream.failNext("/api/global/em/jsonv2", 3)
+	if rec := postEvents(s, body); rec.Code != http.StatusInternalServerError {
+		t.Errorf("with every batch failing got status %d, want 500", rec.Code)
+	}
+	if rec := postEvents(s, body); rec.Code != http.StatusOK {
+		t.Errorf("with every batch sent got status %d, want 200", rec.Code)
+	}
+}
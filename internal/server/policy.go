@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+
+	"litemidgo/config"
+)
+
+// clientKey is the request context key of the authenticated restricted client
+type clientKey struct{}
+
+// requestClient returns the restricted client that sent r, or nil for the
+// server.auth credentials and unauthenticated servers
+func requestClient(r *http.Request) *config.ClientConfig {
+	client, _ := r.Context().Value(clientKey{}).(*config.ClientConfig)
+	return client
+}
+
+// clientIdentity is the verified source of a restricted client
+func clientIdentity(client *config.ClientConfig) string {
+	if client.Identity != "" {
+		return client.Identity
+	}
+	return client.Username
+}
+
+// policyError is a record refused by a client policy. Rule is the policy
+// setting that refused it: topics, agents, sources or source_mode.
+type policyError struct {
+	Client string
+	Rule   string
+	Field  string
+	Value  string
+}
+
+func (e *policyError) Error() string {
+	return fmt.Sprintf("client %q may not send %s %q (rule: %s)", e.Client, e.Field, e.Value, e.Rule)
+}
+
+// authorizeRecord applies the policy of the client that sent r to a record
+// after applyDefaults. With source_mode overwrite the record source is
+// replaced by the client identity.
+func (s *Server) authorizeRecord(r *http.Request, proxyReq *ProxyRequest) *policyError {
+	client := requestClient(r)
+	if client == nil {
+		return nil
+	}
+
+	deny := func(rule, field, value string) *policyError {
+		err := &policyError{Client: client.Username, Rule: rule, Field: field, Value: value}
+		log.Printf("🚫 Refused record from %s: %v", r.RemoteAddr, err)
+		return err
+	}
+
+	identity := clientIdentity(client)
+	switch client.SourceMode {
+	case "overwrite":
+		proxyReq.Source = identity
+	case "enforce":
+		if proxyReq.Source != identity {
+			return deny("source_mode", "source", proxyReq.Source)
+		}
+	}
+
+	if !matchAny(client.Topics, proxyReq.Topic) {
+		return deny("topics", "topic", proxyReq.Topic)
+	}
+	if !matchAny(client.Agents, proxyReq.Agent) {
+		return deny("agents", "agent", proxyReq.Agent)
+	}
+	if !matchAny(client.Sources, proxyReq.Source) {
+		return deny("sources", "source", proxyReq.Source)
+	}
+	return nil
+}
+
+// matchAny reports whether value matches one of the glob patterns; an empty
+// list matches everything
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	// requestLogSize is the number of recent records kept for the dashboard
+	requestLogSize = 500
+	// maxLoggedBody caps the payload and response kept per record
+	maxLoggedBody = 16 * 1024
+)
+
+// RequestLogEntry is one record handled by the server, as shown in the
+// dashboard's request log. Payload and Response are JSON, cut off after 16 KiB.
+type RequestLogEntry struct {
+	Seq      uint64        `json:"seq"`
+	Time     time.Time     `json:"time"`
+	Source   string        `json:"source"`
+	Agent    string        `json:"agent"`
+	Topic    string        `json:"topic"`
+	Name     string        `json:"name"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Latency  time.Duration `json:"latency"`
+	SysID    string        `json:"sys_id,omitempty"`
+	Payload  string        `json:"payload"`
+	Response string        `json:"response,omitempty"`
+}
+
+// requestLog is a ring buffer of the most recent records
+type requestLog struct {
+	mu      sync.Mutex
+	entries []RequestLogEntry
+	next    int
+	seq     uint64
+}
+
+func newRequestLog() *requestLog {
+	return &requestLog{entries: make([]RequestLogEntry, 0, requestLogSize)}
+}
+
+func (l *requestLog) add(entry RequestLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	entry.Seq = l.seq
+	if len(l.entries) < requestLogSize {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % requestLogSize
+}
+
+// since returns the entries after sequence number seq, oldest first
+func (l *requestLog) since(seq uint64) []RequestLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []RequestLogEntry
+	start := 0
+	if len(l.entries) == requestLogSize {
+		start = l.next
+	}
+	for i := range l.entries {
+		entry := l.entries[(start+i)%len(l.entries)]
+		if entry.Seq > seq {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// RecentRequests returns the logged records with a sequence number after seq,
+// oldest first. Pass 0 for every record still kept.
+func (s *Server) RecentRequests(seq uint64) []RequestLogEntry {
+	return s.requestLog.since(seq)
+}
+
+// logBody marshals a payload or response for the request log
+func logBody(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	if len(data) > maxLoggedBody {
+		return string(data[:maxLoggedBody]) + "… (truncated)"
+	}
+	return string(data)
+}
@@ -0,0 +1,598 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SensuMetadata, SensuEntity, SensuCheck and SensuEvent cover the parts of the
+// Sensu Go core/v2 event that liteMIDgo acts on. The full event as received is
+// kept alongside and forwarded unchanged.
+type SensuMetadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type SensuEntity struct {
+	Metadata    SensuMetadata `json:"metadata"`
+	EntityClass string        `json:"entity_class"`
+	Deregister  bool          `json:"deregister"`
+}
+
+type SensuCheck struct {
+	Metadata SensuMetadata `json:"metadata"`
+	Status   uint32        `json:"status"`
+	Output   string        `json:"output"`
+	Interval uint32        `json:"interval"`
+	TTL      int64         `json:"ttl"`
+	Timeout  uint32        `json:"timeout"`
+	Issued   int64         `json:"issued"`
+}
+
+type SensuEvent struct {
+	Entity    *SensuEntity `json:"entity"`
+	Check     *SensuCheck  `json:"check"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+const (
+	sensuKeepaliveCheck = "keepalive"
+	// maxSensuStates caps the events kept; entity and check names come from
+	// the client, so the least recently seen is dropped to make room
+	maxSensuStates = 10000
+	// sensuRetention is how long an event that stopped arriving is kept
+	sensuRetention = 7 * 24 * time.Hour
+)
+
+// sensuEventData is passed to the record and event templates
+type sensuEventData struct {
+	Namespace string
+	Entity    string
+	Check     string
+	Status    uint32
+	Output    string
+	Severity  string
+	Labels    map[string]string
+	Event     map[string]interface{}
+}
+
+var defaultSensuRecord = config.RecordConfig{
+	Agent:  "litemidgo-sensu",
+	Topic:  "sensuEvent",
+	Name:   "{{.Entity}}/{{.Check}}",
+	Source: "{{.Entity}}",
+}
+
+var defaultSensuEvent = config.EventConfig{
+	Source:      "Sensu",
+	Node:        "{{.Entity}}",
+	Type:        "{{.Check}}",
+	Severity:    "{{.Severity}}",
+	MessageKey:  "sensu:{{.Namespace}}/{{.Entity}}/{{.Check}}",
+	Description: "{{.Output}}",
+}
+
+type sensuState struct {
+	event      SensuEvent
+	raw        map[string]interface{}
+	lastSeen   time.Time
+	status     uint32
+	ttlExpired bool
+}
+
+// sensuBackend keeps the latest event per entity/check so it can answer GET
+// requests, detect status transitions and raise keepalive and TTL failures the
+// way a Sensu backend would.
+type sensuBackend struct {
+	server  *Server
+	config  *config.SensuConfig
+	record  *mapping.Record
+	event   *mapping.Event
+	toECC   bool
+	toEvent bool
+	mu      sync.Mutex
+	states  map[string]*sensuState
+	agents  *sensuAgents
+	done    chan struct{}
+}
+
+func (s *Server) startSensu() error {
+	cfg := &s.config.Sensu
+	backend := &sensuBackend{
+		server: s,
+		config: cfg,
+		states: make(map[string]*sensuState),
+		done:   make(chan struct{}),
+	}
+
+	var err error
+	if backend.toECC, backend.toEvent, err = parseTarget("sensu target", cfg.Target); err != nil {
+		return err
+	}
+	if backend.record, err = mapping.NewRecord(cfg.Record, defaultSensuRecord); err != nil {
+		return fmt.Errorf("invalid sensu record mapping: %w", err)
+	}
+	if backend.event, err = mapping.NewEvent(cfg.Event, defaultSensuEvent); err != nil {
+		return fmt.Errorf("invalid sensu event mapping: %w", err)
+	}
+
+	if cfg.AgentAddress != "" {
+		if err := s.startSensuAgents(backend); err != nil {
+			return err
+		}
+	}
+
+	go backend.monitor()
+	s.sensu = backend
+	return nil
+}
+
+func (b *sensuBackend) stop() {
+	if b.agents != nil {
+		b.agents.stop()
+	}
+	close(b.done)
+}
+
+// sensuAuth accepts Sensu API keys ("Authorization: Key <key>") when any are
+// configured, and otherwise falls back to the server's basic authentication.
+func (s *Server) sensuAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.SecurityHeaders(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Key "); ok {
+			for _, valid := range s.config.Sensu.APIKeys {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+					next(w, r)
+					return
+				}
+			}
+			writeSensuError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+
+		if s.config.Server.Auth.Enabled {
+			s.BasicAuth(next)(w, r)
+			return
+		}
+		if len(s.config.Sensu.APIKeys) > 0 {
+			writeSensuError(w, http.StatusUnauthorized, "API key required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func writeSensuError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// handleSensuEvents serves /api/core/v2/namespaces/{namespace}/events
+func (s *Server) handleSensuEvents(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSONResponse(w, http.StatusOK, s.sensu.list(namespace))
+	case http.MethodPost:
+		s.createSensuEvent(w, r, namespace, "", "")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSensuEvent serves /api/core/v2/namespaces/{namespace}/events/{entity}/{check}
+func (s *Server) handleSensuEvent(w http.ResponseWriter, r *http.Request) {
+	namespace, entity, check := r.PathValue("namespace"), r.PathValue("entity"), r.PathValue("check")
+
+	switch r.Method {
+	case http.MethodGet:
+		raw, ok := s.sensu.get(namespace, entity, check)
+		if !ok {
+			writeSensuError(w, http.StatusNotFound, "resource not found")
+			return
+		}
+		s.writeJSONResponse(w, http.StatusOK, raw)
+	case http.MethodPut, http.MethodPost:
+		s.createSensuEvent(w, r, namespace, entity, check)
+	case http.MethodDelete:
+		if !s.sensu.delete(namespace, entity, check) {
+			writeSensuError(w, http.StatusNotFound, "resource not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createSensuEvent(w http.ResponseWriter, r *http.Request, namespace, entity, check string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1048576))
+	if err != nil {
+		writeSensuError(w, http.StatusBadRequest, "request body too large or unreadable")
+		return
+	}
+
+	event, raw, err := decodeSensuEvent(body)
+	if err != nil {
+		writeSensuError(w, http.StatusBadRequest, "invalid event JSON")
+		return
+	}
+
+	if err := checkSensuEvent(namespace, &event, raw); err != nil {
+		writeSensuError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if entity != "" && (event.Entity.Metadata.Name != entity || event.Check.Metadata.Name != check) {
+		writeSensuError(w, http.StatusBadRequest, "entity and check in URL do not match the event")
+		return
+	}
+
+	if err := s.sensu.observe(namespace, event, raw); err != nil {
+		log.Printf("Failed to forward Sensu event %s/%s: %v", event.Entity.Metadata.Name, event.Check.Metadata.Name, err)
+		writeSensuError(w, http.StatusInternalServerError, "failed to send to ServiceNow")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkSensuEvent rejects events without an entity or check name or from
+// another namespace, and fills in the timestamps a Sensu backend would
+func checkSensuEvent(namespace string, event *SensuEvent, raw map[string]interface{}) error {
+	if event.Entity == nil || event.Entity.Metadata.Name == "" {
+		return errors.New("entity name must not be empty")
+	}
+	if event.Check == nil || event.Check.Metadata.Name == "" {
+		return errors.New("check name must not be empty")
+	}
+	for _, ns := range []string{event.Entity.Metadata.Namespace, event.Check.Metadata.Namespace} {
+		if ns != "" && ns != namespace {
+			return errors.New("namespace in URL does not match the event")
+		}
+	}
+
+	now := time.Now().Unix()
+	if event.Timestamp == 0 {
+		event.Timestamp = now
+		raw["timestamp"] = now
+	}
+	if event.Check.Issued == 0 {
+		event.Check.Issued = now
+	}
+	return nil
+}
+
+// decodeSensuEvent parses an event once into the raw JSON object, which is
+// kept and forwarded, and derives the typed fields from it
+func decodeSensuEvent(data []byte) (SensuEvent, map[string]interface{}, error) {
+	var event SensuEvent
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return event, nil, err
+	}
+	if raw == nil {
+		return event, nil, errors.New("event is not a JSON object")
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: "json",
+		Result:  &event,
+	})
+	if err != nil {
+		return event, nil, err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return event, nil, err
+	}
+	return event, raw, nil
+}
+
+func sensuKey(namespace, entity, check string) string {
+	return namespace + "/" + entity + "/" + check
+}
+
+// observe records an event and forwards it when it is not OK or resolves a
+// previous failure (or always, with forward_ok).
+func (b *sensuBackend) observe(namespace string, event SensuEvent, raw map[string]interface{}) error {
+	key := sensuKey(namespace, event.Entity.Metadata.Name, event.Check.Metadata.Name)
+
+	b.mu.Lock()
+	state, ok := b.states[key]
+	if !ok {
+		if len(b.states) >= maxSensuStates {
+			b.evictOldestLocked()
+		}
+		state = &sensuState{}
+		b.states[key] = state
+	}
+	previous := state.status
+	state.event = event
+	state.raw = raw
+	state.lastSeen = time.Now()
+	state.status = event.Check.Status
+	state.ttlExpired = false
+	b.mu.Unlock()
+
+	if !b.config.ForwardOK && event.Check.Status == 0 && previous == 0 {
+		return nil
+	}
+	return b.forward(namespace, event, raw)
+}
+
+// evictOldestLocked drops the least recently seen event
+func (b *sensuBackend) evictOldestLocked() {
+	var oldest string
+	var oldestSeen time.Time
+	for key, state := range b.states {
+		if oldest == "" || state.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = key, state.lastSeen
+		}
+	}
+	delete(b.states, oldest)
+}
+
+// deleteEntityLocked drops every event of an entity
+func (b *sensuBackend) deleteEntityLocked(namespace, entity string) {
+	prefix := sensuKey(namespace, entity, "")
+	for key := range b.states {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.states, key)
+		}
+	}
+}
+
+func (b *sensuBackend) list(namespace string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := make([]string, 0, len(b.states))
+	for key := range b.states {
+		if strings.HasPrefix(key, namespace+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	events := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		events = append(events, b.states[key].raw)
+	}
+	return events
+}
+
+func (b *sensuBackend) get(namespace, entity, check string) (map[string]interface{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[sensuKey(namespace, entity, check)]
+	if !ok {
+		return nil, false
+	}
+	return state.raw, true
+}
+
+func (b *sensuBackend) delete(namespace, entity, check string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := sensuKey(namespace, entity, check)
+	if _, ok := b.states[key]; !ok {
+		return false
+	}
+	delete(b.states, key)
+	return true
+}
+
+// monitor raises keepalive failures for agents that stop sending keepalives and
+// TTL failures for checks whose results stop arriving.
+func (b *sensuBackend) monitor() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-ticker.C:
+			for _, failure := range b.expired(now) {
+				if err := b.forward(failure.namespace, failure.event, failure.raw); err != nil {
+					log.Printf("Failed to forward Sensu %s failure for %s: %v",
+						failure.event.Check.Metadata.Name, failure.event.Entity.Metadata.Name, err)
+				}
+			}
+		}
+	}
+}
+
+type sensuFailure struct {
+	namespace string
+	event     SensuEvent
+	raw       map[string]interface{}
+}
+
+// expired returns the keepalive and TTL failures due at now. Events not seen
+// for sensuRetention are dropped, and so are the events of an entity with
+// deregister set once its keepalive goes critical, as a Sensu backend deletes
+// such an entity instead of raising the failure.
+func (b *sensuBackend) expired(now time.Time) []sensuFailure {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var failures []sensuFailure
+	var deregistered []SensuEntity
+	for key, state := range b.states {
+		if now.Sub(state.lastSeen) > sensuRetention {
+			delete(b.states, key)
+			continue
+		}
+		elapsed := int64(now.Sub(state.lastSeen).Seconds())
+		check := *state.event.Check
+
+		var status uint32
+		var output string
+		if check.Metadata.Name == sensuKeepaliveCheck {
+			warning := int64(b.config.KeepaliveWarningTimeout)
+			if check.Timeout > 0 {
+				warning = int64(check.Timeout)
+			}
+			critical := int64(b.config.KeepaliveCriticalTimeout)
+			if check.TTL > 0 {
+				critical = check.TTL
+			}
+
+			switch {
+			case critical > 0 && elapsed >= critical:
+				status = 2
+			case warning > 0 && elapsed >= warning:
+				status = 1
+			}
+			if status == 0 || status <= state.status {
+				continue
+			}
+			if status == 2 && state.event.Entity.Deregister {
+				deregistered = append(deregistered, SensuEntity{Metadata: SensuMetadata{
+					Name:      state.event.Entity.Metadata.Name,
+					Namespace: strings.SplitN(key, "/", 2)[0],
+				}})
+				continue
+			}
+			output = fmt.Sprintf("No keepalive sent from %s for %d seconds", state.event.Entity.Metadata.Name, elapsed)
+		} else {
+			if check.TTL <= 0 || state.ttlExpired || elapsed < check.TTL {
+				continue
+			}
+			status = 1
+			state.ttlExpired = true
+			output = fmt.Sprintf("Last check execution was %d seconds ago", elapsed)
+		}
+
+		check.Status = status
+		check.Output = output
+		check.Issued = now.Unix()
+		event := SensuEvent{Entity: state.event.Entity, Check: &check, Timestamp: now.Unix()}
+		state.status = status
+
+		failures = append(failures, sensuFailure{
+			namespace: strings.SplitN(key, "/", 2)[0],
+			event:     event,
+			raw:       withCheckResult(state.raw, status, output, now.Unix()),
+		})
+	}
+
+	for _, entity := range deregistered {
+		log.Printf("Sensu entity %s/%s stopped sending keepalives and was deregistered", entity.Metadata.Namespace, entity.Metadata.Name)
+		b.deleteEntityLocked(entity.Metadata.Namespace, entity.Metadata.Name)
+		kept := failures[:0]
+		for _, failure := range failures {
+			if failure.namespace != entity.Metadata.Namespace || failure.event.Entity.Metadata.Name != entity.Metadata.Name {
+				kept = append(kept, failure)
+			}
+		}
+		failures = kept
+	}
+	return failures
+}
+
+// withCheckResult copies a raw event with a new check status and output
+func withCheckResult(raw map[string]interface{}, status uint32, output string, timestamp int64) map[string]interface{} {
+	copied := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		copied[k] = v
+	}
+	check := make(map[string]interface{})
+	if original, ok := raw["check"].(map[string]interface{}); ok {
+		for k, v := range original {
+			check[k] = v
+		}
+	}
+	check["status"] = status
+	check["output"] = output
+	check["issued"] = timestamp
+	copied["check"] = check
+	copied["timestamp"] = timestamp
+	return copied
+}
+
+// sensuSeverity maps a Sensu check status to an Event Management severity
+func sensuSeverity(status uint32) string {
+	switch status {
+	case 0:
+		return "0"
+	case 1:
+		return "4"
+	case 2:
+		return "1"
+	default:
+		return "3"
+	}
+}
+
+func (b *sensuBackend) forward(namespace string, event SensuEvent, raw map[string]interface{}) error {
+	labels := make(map[string]string)
+	for k, v := range event.Entity.Metadata.Labels {
+		labels[k] = v
+	}
+	for k, v := range event.Check.Metadata.Labels {
+		labels[k] = v
+	}
+
+	data := sensuEventData{
+		Namespace: namespace,
+		Entity:    event.Entity.Metadata.Name,
+		Check:     event.Check.Metadata.Name,
+		Status:    event.Check.Status,
+		Output:    strings.TrimSpace(event.Check.Output),
+		Severity:  sensuSeverity(event.Check.Status),
+		Labels:    labels,
+		Event:     raw,
+	}
+
+	if b.toECC {
+		record, err := b.record.Render(data)
+		if err != nil {
+			return err
+		}
+		if record.Payload == nil {
+			record.Payload = raw
+		}
+		if err := b.server.forwardRecord(record); err != nil {
+			return err
+		}
+	}
+
+	if b.toEvent {
+		snEvent, err := b.event.Render(data)
+		if err != nil {
+			return err
+		}
+		if snEvent.AdditionalInfo == nil {
+			snEvent.AdditionalInfo = map[string]interface{}{
+				"sensu_namespace": namespace,
+				"sensu_status":    event.Check.Status,
+				"sensu_labels":    labels,
+			}
+		}
+		snEvent.TimeOfEvent = time.Unix(event.Timestamp, 0).UTC().Format("2006-01-02 15:04:05")
+		if err := b.server.sendEvent(snEvent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
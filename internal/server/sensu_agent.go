@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Headers and message types of the Sensu agent transport. An agent connects
+// to its backend-url over a websocket and sends each message as its type, a
+// newline and the JSON payload.
+const (
+	sensuHeaderAgentName = "Sensu-AgentName"
+	sensuHeaderNamespace = "Sensu-Namespace"
+
+	sensuMessageKeepalive = "keepalive"
+	sensuMessageEvent     = "event"
+
+	// maxSensuAgents caps concurrently connected agents
+	maxSensuAgents = 1024
+	// sensuAgentIdleTimeout closes a connection that sends no message for
+	// this long; agents send a keepalive every 20 seconds by default
+	sensuAgentIdleTimeout = 5 * time.Minute
+	// sensuAgentMessageSize caps a single message, as the events API caps a
+	// request body
+	sensuAgentMessageSize = 1048576
+)
+
+var sensuUpgrader = websocket.Upgrader{
+	HandshakeTimeout: 10 * time.Second,
+}
+
+// sensuAgents serves the agent websocket on its own listener, as a Sensu
+// backend serves agents on port 8081
+type sensuAgents struct {
+	backend  *sensuBackend
+	server   *http.Server
+	listener net.Listener
+	slots    chan struct{}
+	mu       sync.Mutex
+	conns    map[*websocket.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func (s *Server) startSensuAgents(b *sensuBackend) error {
+	listener, err := net.Listen("tcp", s.config.Sensu.AgentAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen for Sensu agents on %s: %w", s.config.Sensu.AgentAddress, err)
+	}
+
+	agents := &sensuAgents{
+		backend:  b,
+		listener: listener,
+		slots:    make(chan struct{}, maxSensuAgents),
+		conns:    make(map[*websocket.Conn]struct{}),
+	}
+	var handler http.HandlerFunc = agents.handle
+	if s.config.Server.Auth.Enabled {
+		handler = s.BasicAuth(handler)
+	}
+	agents.server = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := agents.server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Sensu agent listener failed: %v", err)
+		}
+	}()
+	b.agents = agents
+	log.Printf("📥 Sensu agent websocket listening on %s", listener.Addr())
+	return nil
+}
+
+// stop closes the listener and the agent connections and waits for them
+func (a *sensuAgents) stop() {
+	a.server.Close()
+	a.mu.Lock()
+	a.closed = true
+	for conn := range a.conns {
+		conn.Close()
+	}
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+func (a *sensuAgents) handle(w http.ResponseWriter, r *http.Request) {
+	agent := r.Header.Get(sensuHeaderAgentName)
+	namespace := r.Header.Get(sensuHeaderNamespace)
+	if namespace == "" {
+		namespace = "default"
+	}
+	if agent == "" {
+		http.Error(w, "missing "+sensuHeaderAgentName+" header", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+	default:
+		log.Printf("⚠️  Refused Sensu agent %s from %s: %d agents connected", agent, r.RemoteAddr, maxSensuAgents)
+		http.Error(w, "too many agents connected", http.StatusServiceUnavailable)
+		return
+	}
+	defer func() { <-a.slots }()
+
+	// Agents use the serialization the backend names in its handshake response
+	conn, err := sensuUpgrader.Upgrade(w, r, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		conn.Close()
+		return
+	}
+	a.conns[conn] = struct{}{}
+	a.wg.Add(1)
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.conns, conn)
+		a.mu.Unlock()
+		conn.Close()
+		a.wg.Done()
+	}()
+
+	log.Printf("✓ Sensu agent %s connected from %s (namespace %s)", agent, r.RemoteAddr, namespace)
+	conn.SetReadLimit(sensuAgentMessageSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(sensuAgentIdleTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			log.Printf("Sensu agent %s disconnected: %v", agent, err)
+			return
+		}
+		if err := a.receive(namespace, message); err != nil {
+			log.Printf("Failed to handle message from Sensu agent %s: %v", agent, err)
+		}
+	}
+}
+
+// receive handles one agent message. Keepalives and check results are kept
+// and forwarded like events posted to the events API; other messages, such as
+// metrics-only events, are ignored.
+func (a *sensuAgents) receive(namespace string, message []byte) error {
+	msgType, payload, ok := bytes.Cut(message, []byte("\n"))
+	if !ok {
+		return errors.New("message without a type")
+	}
+	switch string(msgType) {
+	case sensuMessageKeepalive, sensuMessageEvent:
+	default:
+		return nil
+	}
+
+	event, raw, err := decodeSensuEvent(payload)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", msgType, err)
+	}
+	if event.Check == nil && event.Entity != nil && string(msgType) == sensuMessageEvent {
+		return nil
+	}
+	if err := checkSensuEvent(namespace, &event, raw); err != nil {
+		return fmt.Errorf("invalid %s: %w", msgType, err)
+	}
+	if string(msgType) == sensuMessageKeepalive && event.Check.Metadata.Name != sensuKeepaliveCheck {
+		return fmt.Errorf("keepalive for check %q", event.Check.Metadata.Name)
+	}
+
+	if err := a.backend.observe(namespace, event, raw); err != nil {
+		return fmt.Errorf("failed to forward %s/%s: %w", event.Entity.Metadata.Name, event.Check.Metadata.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"litemidgo/config"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDecodeSensuEvent(t *testing.T) {
+	event, raw, err := decodeSensuEvent([]byte(`{
+		"entity": {"metadata": {"name": "web01", "labels": {"team": "ops"}}, "entity_class": "agent"},
+		"check": {"metadata": {"name": "disk"}, "status": 2, "output": "full", "ttl": 90},
+		"extra": true
+	}`))
+	if err != nil {
+		t.Fatalf("decodeSensuEvent: %v", err)
+	}
+	if event.Entity.Metadata.Name != "web01" || event.Entity.Metadata.Labels["team"] != "ops" {
+		t.Errorf("unexpected entity %+v", event.Entity)
+	}
+	if event.Check.Metadata.Name != "disk" || event.Check.Status != 2 || event.Check.TTL != 90 {
+		t.Errorf("unexpected check %+v", event.Check)
+	}
+	if raw["extra"] != true {
+		t.Errorf("raw event lost unknown fields: %v", raw)
+	}
+
+	for _, body := range []string{`null`, `[]`, `{"check": {"status": "bad"}}`, `{`} {
+		if _, _, err := decodeSensuEvent([]byte(body)); err == nil {
+			t.Errorf("decodeSensuEvent(%s): expected an error", body)
+		}
+	}
+}
+
+func newTestSensuBackend() *sensuBackend {
+	return &sensuBackend{
+		config: &config.SensuConfig{KeepaliveWarningTimeout: 120, KeepaliveCriticalTimeout: 180},
+		states: make(map[string]*sensuState),
+	}
+}
+
+func sensuTestEvent(entity, check string, deregister bool) SensuEvent {
+	return SensuEvent{
+		Entity: &SensuEntity{Metadata: SensuMetadata{Name: entity}, Deregister: deregister},
+		Check:  &SensuCheck{Metadata: SensuMetadata{Name: check}},
+	}
+}
+
+func TestSensuStatesAreBounded(t *testing.T) {
+	b := newTestSensuBackend()
+	for i := 0; i < maxSensuStates+5; i++ {
+		if err := b.observe("default", sensuTestEvent(fmt.Sprintf("host-%d", i), "disk", false), map[string]interface{}{}); err != nil {
+			t.Fatalf("observe: %v", err)
+		}
+	}
+	if n := len(b.states); n != maxSensuStates {
+		t.Fatalf("got %d events, want %d", n, maxSensuStates)
+	}
+
+	if failures := b.expired(time.Now().Add(sensuRetention + time.Minute)); len(failures) != 0 {
+		t.Errorf("got %d failures for retired events", len(failures))
+	}
+	if n := len(b.states); n != 0 {
+		t.Errorf("got %d events after the retention period, want 0", n)
+	}
+}
+
+func TestSensuDeregisteredEntityIsRemoved(t *testing.T) {
+	b := newTestSensuBackend()
+	raw := map[string]interface{}{}
+	b.observe("default", sensuTestEvent("web01", sensuKeepaliveCheck, true), raw)
+	b.observe("default", sensuTestEvent("web01", "disk", true), raw)
+	b.observe("default", sensuTestEvent("db01", sensuKeepaliveCheck, false), raw)
+
+	failures := b.expired(time.Now().Add(200 * time.Second))
+	if len(failures) != 1 || failures[0].event.Entity.Metadata.Name != "db01" || failures[0].event.Check.Status != 2 {
+		t.Fatalf("got failures %+v, want a critical keepalive for db01 only", failures)
+	}
+	if _, ok := b.get("default", "web01", "disk"); ok {
+		t.Error("events of the deregistered entity were kept")
+	}
+	if _, ok := b.get("default", "db01", sensuKeepaliveCheck); !ok {
+		t.Error("keepalive of the registered entity was dropped")
+	}
+}
+
+func TestSensuAgentWebsocket(t *testing.T) {
+	b := newTestSensuBackend()
+	agents := &sensuAgents{
+		backend: b,
+		slots:   make(chan struct{}, maxSensuAgents),
+		conns:   make(map[*websocket.Conn]struct{}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(agents.handle))
+	defer srv.Close()
+
+	header := http.Header{sensuHeaderAgentName: {"web01"}, sensuHeaderNamespace: {"ops"}}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("handshake Content-Type = %q, want application/json", ct)
+	}
+
+	keepalive := `{"entity":{"metadata":{"name":"web01","namespace":"ops"},"entity_class":"agent"},` +
+		`"check":{"metadata":{"name":"keepalive","namespace":"ops"},"interval":20,"timeout":120,"ttl":180}}`
+	for _, message := range []string{
+		"keepalive\n" + keepalive,
+		"event\n" + `{"entity":{"metadata":{"name":"web01"}},"metrics":{"points":[]}}`,
+		"check_result_unknown\n{}",
+	} {
+		if err := conn.WriteMessage(websocket.BinaryMessage, []byte(message)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := b.get("ops", "web01", sensuKeepaliveCheck); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("keepalive was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(b.list("ops")); n != 1 {
+		t.Errorf("got %d events, want only the keepalive", n)
+	}
+
+	failures := b.expired(time.Now().Add(130 * time.Second))
+	if len(failures) != 1 || failures[0].event.Check.Status != 1 {
+		t.Fatalf("got failures %+v, want a keepalive warning", failures)
+	}
+}
+
+func TestSensuAgentMessages(t *testing.T) {
+	agents := &sensuAgents{backend: newTestSensuBackend()}
+	for _, message := range []string{
+		"keepalive",
+		"keepalive\nnot json",
+		"keepalive\n" + `{"entity":{"metadata":{"name":"web01"}},"check":{"metadata":{"name":"disk"}}}`,
+		"event\n" + `{"entity":{"metadata":{"name":"web01","namespace":"other"}},"check":{"metadata":{"name":"disk"}}}`,
+	} {
+		if err := agents.receive("default", []byte(message)); err == nil {
+			t.Errorf("receive(%q): expected an error", message)
+		}
+	}
+}
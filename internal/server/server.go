@@ -2,19 +2,44 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"litemidgo/config"
+	"litemidgo/internal/mapping"
 	"litemidgo/internal/servicenow"
+	"litemidgo/internal/snmp"
+	"litemidgo/internal/syslog"
 )
 
 type Server struct {
-	config     *config.Config
-	snowClient *servicenow.Client
-	httpServer *http.Server
+	config       *config.Config
+	snowClient   *servicenow.Client
+	httpServer   *http.Server
+	listener     net.Listener
+	started      time.Time
+	syslog       *syslog.Receiver
+	snmp         *snmp.Receiver
+	sensu        *sensuBackend
+	cmdb         *cmdbReconciler
+	alertmanager *alertmanagerReceiver
+	events       *servicenow.EventBatcher
+	recordEvent  *mapping.Event
+	eccOutput    bool
+	eventOutput  bool
+	stats        *requestStats
+	requestLog   *requestLog
+	agents       *agentRegistry
+	agentMonitor *agentMonitor
+	// adminRecordsWarned is set once records were sent with the server.auth
+	// credentials while client policies are configured
+	adminRecordsWarned atomic.Bool
 }
 
 type ProxyRequest struct {
@@ -23,31 +48,59 @@ type ProxyRequest struct {
 	Name    string      `json:"name"`
 	Source  string      `json:"source"`
 	Payload interface{} `json:"payload"`
+	// RemoteAddr is the client address of records posted over HTTP
+	RemoteAddr string `json:"-"`
 }
 
 type ProxyResponse struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
 	SysID     string `json:"sys_id,omitempty"`
+	Rule      string `json:"rule,omitempty"`
 	Timestamp string `json:"timestamp"`
 }
 
-func NewServer(cfg *config.Config) *Server {
-	snowClient := servicenow.NewClient(&cfg.ServiceNow)
+func NewServer(cfg *config.Config) (*Server, error) {
+	snowClient, err := servicenow.NewClient(&cfg.ServiceNow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ServiceNow client: %w", err)
+	}
 
 	return &Server{
 		config:     cfg,
 		snowClient: snowClient,
-	}
+		stats:      newRequestStats(),
+		requestLog: newRequestLog(),
+		agents:     newAgentRegistry(cfg.Agents.RegistryPath()),
+	}, nil
 }
 
+// Start runs the server until Stop is called; see Listen and Serve.
 func (s *Server) Start() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Listen tests the ServiceNow connection, starts the receivers and binds the
+// HTTP listener. Once it returns nil the server accepts connections, which
+// are handled when Serve is called.
+func (s *Server) Listen() error {
 	// Test ServiceNow connection before starting
 	if err := s.snowClient.TestConnection(); err != nil {
 		return fmt.Errorf("ServiceNow connection test failed: %w", err)
 	}
 
 	log.Printf("✓ ServiceNow connection established to %s", s.snowClient.GetInstanceURL())
+	log.Printf("   Connection path: %s", s.snowClient.ConnectionPath())
+
+	if err := s.startEvents(); err != nil {
+		return err
+	}
+	if err := s.startAgents(); err != nil {
+		return err
+	}
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
@@ -57,14 +110,57 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/health", s.SecurityHeaders(s.handleHealth))
 
 	// Apply authentication to protected endpoints
+	mux.HandleFunc("/proxy/ecc_queue", s.protectRecords(s.handleECCQueueProxy))
+	mux.HandleFunc("/proxy/ecc_queue/stream", s.protectRecords(s.handleECCQueueStream))
+	mux.HandleFunc("/proxy/em_event", s.protect(s.handleEMEventProxy))
+	mux.HandleFunc("/events/stats", s.protect(s.handleEventStats))
+	mux.HandleFunc("/proxy/cmdb", s.protect(s.handleCMDBReconcile))
+	mux.HandleFunc("/cmdb/stats", s.protect(s.handleCMDBStats))
+	mux.HandleFunc("/proxy/attachment", s.protectRecords(s.handleAttachmentUpload))
+	mux.HandleFunc("/syslog/stats", s.protect(s.handleSyslogStats))
+	mux.HandleFunc("/snmp/stats", s.protect(s.handleSNMPStats))
+	mux.HandleFunc("/agents", s.protect(s.handleAgents))
 	if s.config.Server.Auth.Enabled {
-		mux.HandleFunc("/proxy/ecc_queue", s.SecurityHeaders(s.BasicAuth(s.handleECCQueueProxy)))
 		log.Printf("🔐 Authentication enabled for protected endpoints")
+		if n := len(s.config.Server.Auth.Clients); n > 0 {
+			log.Printf("   %d restricted client(s) may send ECC records", n)
+		}
 	} else {
-		mux.HandleFunc("/proxy/ecc_queue", s.SecurityHeaders(s.handleECCQueueProxy))
 		log.Printf("⚠️  Authentication disabled - endpoints are open")
 	}
 
+	if s.config.CMDB.Enabled {
+		s.startCMDB()
+	}
+	if s.config.Syslog.Enabled {
+		if err := s.startSyslog(); err != nil {
+			return err
+		}
+	}
+	if s.config.SNMP.Enabled {
+		if err := s.startSNMP(); err != nil {
+			return err
+		}
+	}
+	if s.config.Sensu.Enabled {
+		if err := s.startSensu(); err != nil {
+			return err
+		}
+		mux.HandleFunc("/api/core/v2/namespaces/{namespace}/events", s.sensuAuth(s.handleSensuEvents))
+		mux.HandleFunc("/api/core/v2/namespaces/{namespace}/events/{entity}/{check}", s.sensuAuth(s.handleSensuEvent))
+	}
+	if s.config.Alertmanager.Enabled {
+		if err := s.startAlertmanager(); err != nil {
+			return err
+		}
+		mux.HandleFunc("/integrations/alertmanager", s.protect(s.handleAlertmanagerWebhook))
+	}
+	if s.config.Server.Web.Enabled {
+		if err := s.webRoutes(mux); err != nil {
+			return err
+		}
+	}
+
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port),
 		Handler:      mux,
@@ -73,20 +169,83 @@ func (s *Server) Start() error {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+	s.listener = listener
+	s.started = time.Now()
+
 	log.Printf("🚀 Starting LiteMIDgo server on %s:%d", s.config.Server.Host, s.config.Server.Port)
 	log.Printf("📡 Available endpoints:")
 	log.Printf("   - GET  /health - Health check")
 	log.Printf("   - POST /proxy/ecc_queue - Proxy to ServiceNow ECC Queue")
+	log.Printf("   - POST /proxy/ecc_queue/stream - Stream NDJSON records to ServiceNow ECC Queue")
+	log.Printf("   - POST /proxy/em_event - Send events to ServiceNow Event Management")
+	log.Printf("   - GET  /events/stats - Event batching counters")
+	log.Printf("   - POST /proxy/cmdb - Reconcile agent inventory into the CMDB")
+	log.Printf("   - POST /proxy/attachment - Upload files as ServiceNow attachments")
+	log.Printf("   - GET  /agents - Agent registry")
+	if s.cmdb != nil {
+		log.Printf("   - GET  /cmdb/stats - CMDB reconciliation counters")
+	}
+	if s.syslog != nil {
+		log.Printf("   - GET  /syslog/stats - Syslog receiver counters")
+	}
+	if s.snmp != nil {
+		log.Printf("   - GET  /snmp/stats - SNMP trap receiver counters")
+	}
+	if s.sensu != nil {
+		log.Printf("   - POST /api/core/v2/namespaces/{namespace}/events - Sensu Go events API")
+	}
+	if s.alertmanager != nil {
+		log.Printf("   - POST /integrations/alertmanager - Prometheus Alertmanager webhook")
+	}
+	if s.config.Server.Web.Enabled {
+		log.Printf("   - GET  /ui/ - Web dashboard")
+	}
 	log.Printf("   - GET  / - Server information")
 
-	return s.httpServer.ListenAndServe()
+	return nil
+}
+
+// Serve handles connections on the listener bound by Listen. It returns nil
+// once the server is stopped.
+func (s *Server) Serve() error {
+	if err := s.httpServer.Serve(s.listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 func (s *Server) Stop() error {
+	if s.syslog != nil {
+		s.syslog.Stop()
+	}
+	if s.snmp != nil {
+		s.snmp.Stop()
+	}
+	if s.sensu != nil {
+		s.sensu.stop()
+	}
+	if s.agentMonitor != nil {
+		s.agentMonitor.stop()
+	}
+	if s.cmdb != nil {
+		s.cmdb.stop()
+	}
+	if s.events != nil {
+		s.events.Close()
+	}
+	var err error
 	if s.httpServer != nil {
-		return s.httpServer.Close()
+		err = s.httpServer.Close()
 	}
-	return nil
+	if s.listener != nil {
+		// Serve may not have taken over the listener yet
+		s.listener.Close()
+	}
+	return err
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -135,19 +294,7 @@ func (s *Server) handleECCQueueProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if proxyReq.Agent == "" {
-		proxyReq.Agent = "litemidgo"
-	}
-	if proxyReq.Topic == "" {
-		proxyReq.Topic = "endpointData"
-	}
-	if proxyReq.Name == "" {
-		proxyReq.Name = "default"
-	}
-	if proxyReq.Source == "" {
-		proxyReq.Source = r.RemoteAddr
-	}
+	s.applyDefaults(&proxyReq, r)
 
 	// Validate payload (basic check)
 	if proxyReq.Payload == nil {
@@ -160,17 +307,19 @@ func (s *Server) handleECCQueueProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create ECC Queue payload
-	eccPayload := &servicenow.ECCQueuePayload{
-		Agent:   proxyReq.Agent,
-		Topic:   proxyReq.Topic,
-		Name:    proxyReq.Name,
-		Source:  proxyReq.Source,
-		Payload: proxyReq.Payload,
+	if perr := s.authorizeRecord(r, &proxyReq); perr != nil {
+		response := ProxyResponse{
+			Success:   false,
+			Message:   "Forbidden: " + perr.Error(),
+			Rule:      perr.Rule,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		s.writeJSONResponse(w, http.StatusForbidden, response)
+		return
 	}
 
 	// Send to ServiceNow
-	_, err := s.snowClient.SendToECCQueue(eccPayload)
+	sysID, err := s.ingest(&proxyReq)
 	if err != nil {
 		response := ProxyResponse{
 			Success:   false,
@@ -184,11 +333,90 @@ func (s *Server) handleECCQueueProxy(w http.ResponseWriter, r *http.Request) {
 	response := ProxyResponse{
 		Success:   true,
 		Message:   "Data sent to ServiceNow successfully",
+		SysID:     sysID,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
 	s.writeJSONResponse(w, http.StatusOK, response)
 }
 
+// applyDefaults fills in the optional fields of a proxy request. The source
+// defaults to the identity of a restricted client, or else the remote address.
+func (s *Server) applyDefaults(proxyReq *ProxyRequest, r *http.Request) {
+	if proxyReq.Agent == "" {
+		proxyReq.Agent = "litemidgo"
+	}
+	if proxyReq.Topic == "" {
+		proxyReq.Topic = "endpointData"
+	}
+	if proxyReq.Name == "" {
+		proxyReq.Name = "default"
+	}
+	proxyReq.RemoteAddr = r.RemoteAddr
+	if proxyReq.Source == "" {
+		if client := requestClient(r); client != nil {
+			proxyReq.Source = clientIdentity(client)
+		} else {
+			proxyReq.Source = r.RemoteAddr
+		}
+	}
+}
+
+// forwardToECC sends a validated proxy request to the ServiceNow ECC queue.
+// Payloads above attachments.offload_threshold are sent as an attachment.
+func (s *Server) forwardToECC(proxyReq *ProxyRequest) (*servicenow.ECCQueueResponse, error) {
+	eccPayload := &servicenow.ECCQueuePayload{
+		Agent:   proxyReq.Agent,
+		Topic:   proxyReq.Topic,
+		Name:    proxyReq.Name,
+		Source:  proxyReq.Source,
+		Payload: proxyReq.Payload,
+	}
+
+	if data, ok := s.payloadForOffload(proxyReq.Payload); ok {
+		return s.offloadToAttachment(eccPayload, data)
+	}
+
+	return s.snowClient.SendToECCQueue(eccPayload)
+}
+
+// forwardRecord sends a record produced by one of the receivers (syslog, SNMP)
+// through the same path as records posted to /proxy/ecc_queue.
+func (s *Server) forwardRecord(record *servicenow.ECCQueuePayload) error {
+	_, err := s.ingest(&ProxyRequest{
+		Agent:   record.Agent,
+		Topic:   record.Topic,
+		Name:    record.Name,
+		Source:  record.Source,
+		Payload: record.Payload,
+	})
+	return err
+}
+
+// errEventQueueFull is returned for events dropped because events.max_pending
+// events are already waiting to be sent
+var errEventQueueFull = errors.New("event queue is full")
+
+// sendEvent queues an event for the next Event Management batch
+func (s *Server) sendEvent(event *servicenow.Event) error {
+	if !s.events.Add(*event) {
+		return errEventQueueFull
+	}
+	return nil
+}
+
+// parseTarget interprets an output setting (ecc, event or both)
+func parseTarget(setting, target string) (toECC, toEvent bool, err error) {
+	switch strings.ToLower(target) {
+	case "", "ecc":
+		return true, false, nil
+	case "event":
+		return false, true, nil
+	case "both":
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("unknown %s %q (use ecc, event or both)", setting, target)
+}
+
 func (s *Server) handleDefault(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -202,6 +430,11 @@ func (s *Server) handleDefault(w http.ResponseWriter, r *http.Request) {
 		"endpoints": map[string]string{
 			"health":     "/health",
 			"ecc_queue":  "/proxy/ecc_queue",
+			"ecc_stream": "/proxy/ecc_queue/stream",
+			"em_event":   "/proxy/em_event",
+			"cmdb":       "/proxy/cmdb",
+			"attachment": "/proxy/attachment",
+			"agents":     "/agents",
 			"servicenow": s.snowClient.GetInstanceURL(),
 		},
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"litemidgo/config"
+	"litemidgo/mockinstance"
+)
+
+// testUpstream is a mock instance that can fail the next requests to a path
+type testUpstream struct {
+	*mockinstance.Instance
+	mu   sync.Mutex
+	fail map[string]int
+}
+
+// failNext makes the next n requests to path fail with 503
+func (u *testUpstream) failNext(path string, n int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.fail[path] = n
+}
+
+func (u *testUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	failing := u.fail[r.URL.Path] > 0
+	if failing {
+		u.fail[r.URL.Path]--
+	}
+	u.mu.Unlock()
+
+	if failing {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	u.Instance.ServeHTTP(w, r)
+}
+
+// newTestServer returns a server connected to a mock instance, with event
+// output set up but no listener or receivers started
+func newTestServer(t *testing.T, configure func(*config.Config)) (*Server, *testUpstream) {
+	t.Helper()
+	inst, err := mockinstance.New(mockinstance.Options{})
+	if err != nil {
+		t.Fatalf("mockinstance.New: %v", err)
+	}
+	upstream := &testUpstream{Instance: inst, fail: make(map[string]int)}
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		ServiceNow: config.ServiceNowConfig{
+			Instance: strings.TrimPrefix(srv.URL, "http://"),
+			Username: "admin",
+			Password: "admin",
+			Timeout:  5,
+		},
+		Agents: config.AgentsConfig{RegistryFile: filepath.Join(t.TempDir(), "agents.json")},
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.startEvents(); err != nil {
+		t.Fatalf("startEvents: %v", err)
+	}
+	t.Cleanup(s.events.Close)
+	return s, upstream
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"litemidgo/internal/snmp"
+)
+
+// startSNMP binds the SNMP trap listener. Traps become ECC records, Event
+// Management events or both depending on snmp.target.
+func (s *Server) startSNMP() error {
+	receiver, err := snmp.NewReceiver(&s.config.SNMP, s.forwardRecord, s.sendEvent)
+	if err != nil {
+		return fmt.Errorf("invalid snmp configuration: %w", err)
+	}
+
+	if err := receiver.Start(); err != nil {
+		return err
+	}
+	s.snmp = receiver
+	return nil
+}
+
+func (s *Server) handleSNMPStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.snmp == nil {
+		response := ProxyResponse{
+			Success:   false,
+			Message:   "SNMP trap receiver is not enabled",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		s.writeJSONResponse(w, http.StatusNotFound, response)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"stats":     s.snmp.Stats(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySamples is how many recent upstream latencies the percentiles
+	// are computed from
+	latencySamples = 1024
+	// rateWindow is the number of seconds of requests-per-second history
+	rateWindow = 60
+	// upstreamFailureThreshold is the number of consecutive failed records
+	// after which the upstream is reported as failing
+	upstreamFailureThreshold = 5
+	// maxTopics bounds the per-topic breakdown; topics are client supplied,
+	// so records for topics beyond the first maxTopics are counted under
+	// otherTopic
+	maxTopics  = 100
+	otherTopic = "other"
+)
+
+// TopicStats counts the records ingested for one ECC topic
+type TopicStats struct {
+	Total  uint64 `json:"total"`
+	Failed uint64 `json:"failed"`
+}
+
+// RequestStats is a snapshot of the records ingested since the server started:
+// counters, a per-topic breakdown (at most 100 topics, then "other"), upstream (ServiceNow) latency percentiles
+// and the number of records started in each of the last 60 seconds, oldest
+// first. ConsecutiveFailures counts the failures since the last success.
+type RequestStats struct {
+	Total               uint64                `json:"total"`
+	Success             uint64                `json:"success"`
+	Failed              uint64                `json:"failed"`
+	InFlight            int64                 `json:"in_flight"`
+	Topics              map[string]TopicStats `json:"topics"`
+	LatencyP50          time.Duration         `json:"latency_p50"`
+	LatencyP90          time.Duration         `json:"latency_p90"`
+	LatencyP99          time.Duration         `json:"latency_p99"`
+	PerSecond           []int                 `json:"per_second"`
+	ConsecutiveFailures int                   `json:"consecutive_failures"`
+	LastError           string                `json:"last_error,omitempty"`
+	LastErrorAt         time.Time             `json:"last_error_at,omitempty"`
+}
+
+type requestStats struct {
+	mu        sync.Mutex
+	total     uint64
+	success   uint64
+	failed    uint64
+	inFlight  int64
+	topics    map[string]*TopicStats
+	latencies []time.Duration
+	next      int
+	buckets   [rateWindow]int
+	second    int64
+
+	consecutive int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func newRequestStats() *requestStats {
+	return &requestStats{
+		topics:    make(map[string]*TopicStats),
+		latencies: make([]time.Duration, 0, latencySamples),
+	}
+}
+
+// begin counts a record as in flight and in the current second
+func (r *requestStats) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight++
+	r.advance(time.Now())
+	r.buckets[r.second%rateWindow]++
+}
+
+// done records the outcome of a record counted by begin. upstream is the time
+// spent in ServiceNow, or zero when the record was not sent upstream directly.
+func (r *requestStats) done(topic string, upstream time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight--
+	r.total++
+	t := r.topics[topic]
+	if t == nil {
+		if len(r.topics) >= maxTopics {
+			topic = otherTopic
+		}
+		if t = r.topics[topic]; t == nil {
+			t = &TopicStats{}
+			r.topics[topic] = t
+		}
+	}
+	t.Total++
+	if err != nil {
+		r.failed++
+		t.Failed++
+		r.consecutive++
+		r.lastError = err.Error()
+		r.lastErrorAt = time.Now()
+	} else {
+		r.success++
+		r.consecutive = 0
+	}
+
+	if upstream > 0 {
+		if len(r.latencies) < latencySamples {
+			r.latencies = append(r.latencies, upstream)
+		} else {
+			r.latencies[r.next] = upstream
+		}
+		r.next = (r.next + 1) % latencySamples
+	}
+}
+
+// advance moves the per-second buckets forward to now, clearing the seconds
+// without requests; the caller holds the lock
+func (r *requestStats) advance(now time.Time) {
+	sec := now.Unix()
+	if sec <= r.second {
+		return
+	}
+	if sec-r.second >= rateWindow {
+		r.buckets = [rateWindow]int{}
+	} else {
+		for s := r.second + 1; s <= sec; s++ {
+			r.buckets[s%rateWindow] = 0
+		}
+	}
+	r.second = sec
+}
+
+func (r *requestStats) snapshot() RequestStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.advance(time.Now())
+	stats := RequestStats{
+		Total:     r.total,
+		Success:   r.success,
+		Failed:    r.failed,
+		InFlight:  r.inFlight,
+		Topics:    make(map[string]TopicStats, len(r.topics)),
+		PerSecond: make([]int, rateWindow),
+
+		ConsecutiveFailures: r.consecutive,
+		LastError:           r.lastError,
+		LastErrorAt:         r.lastErrorAt,
+	}
+	for topic, t := range r.topics {
+		stats.Topics[topic] = *t
+	}
+	for i := range stats.PerSecond {
+		stats.PerSecond[i] = r.buckets[(r.second+1+int64(i))%rateWindow]
+	}
+
+	if len(r.latencies) > 0 {
+		sorted := append([]time.Duration(nil), r.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		percentile := func(p int) time.Duration {
+			return sorted[(len(sorted)-1)*p/100]
+		}
+		stats.LatencyP50 = percentile(50)
+		stats.LatencyP90 = percentile(90)
+		stats.LatencyP99 = percentile(99)
+	}
+	return stats
+}
+
+// UpstreamFailing reports whether the last records all failed
+func (r RequestStats) UpstreamFailing() bool {
+	return r.ConsecutiveFailures >= upstreamFailureThreshold
+}
+
+// Stats returns the request statistics since the server was created
+func (s *Server) Stats() RequestStats {
+	return s.stats.snapshot()
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRequestStatsTopicsAreCapped(t *testing.T) {
+	stats := newRequestStats()
+	for i := 0; i < maxTopics+50; i++ {
+		stats.begin()
+		stats.done(fmt.Sprintf("topic-%d", i), 0, nil)
+	}
+	stats.begin()
+	stats.done("topic-0", 0, errors.New("upstream failed"))
+
+	snapshot := stats.snapshot()
+	if len(snapshot.Topics) != maxTopics+1 {
+		t.Fatalf("got %d topics, want %d plus %q", len(snapshot.Topics), maxTopics, otherTopic)
+	}
+	if other := snapshot.Topics[otherTopic]; other.Total != 50 {
+		t.Errorf("%q total = %d, want 50", otherTopic, other.Total)
+	}
+	if first := snapshot.Topics["topic-0"]; first.Total != 2 || first.Failed != 1 {
+		t.Errorf("topic-0 = %+v, want 2 total and 1 failed", first)
+	}
+	if snapshot.Total != maxTopics+51 {
+		t.Errorf("total = %d, want %d", snapshot.Total, maxTopics+51)
+	}
+}
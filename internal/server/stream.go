@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StreamResult is written back for every non-empty line of an NDJSON upload.
+type StreamResult struct {
+	Line      int    `json:"line"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	SysID     string `json:"sys_id,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// StreamSummary is the final line of an NDJSON response.
+type StreamSummary struct {
+	Done      bool   `json:"done"`
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// handleECCQueueStream reads newline-delimited ProxyRequest objects and forwards
+// them to the ECC queue as they arrive, streaming one result line back per record.
+// Records are processed with bounded concurrency, so results may be written out of
+// order; the line number identifies which input record each result belongs to.
+func (s *Server) handleECCQueueStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Large uploads outlive the server's read/write timeouts, and results are
+	// written while the request body is still being read
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	if err := rc.EnableFullDuplex(); err != nil {
+		log.Printf("Full duplex not supported for stream request: %v", err)
+	}
+
+	concurrency := s.config.Server.Stream.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	maxLineBytes := s.config.Server.Stream.MaxLineBytes
+	if maxLineBytes < 1 {
+		maxLineBytes = 1048576
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		summary StreamSummary
+	)
+	encoder := json.NewEncoder(w)
+	writeResult := func(result StreamResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		summary.Total++
+		if result.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		result.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		if err := encoder.Encode(result); err != nil {
+			log.Printf("Failed to write stream result: %v", err)
+			return
+		}
+		rc.Flush()
+	}
+
+	sem := make(chan struct{}, concurrency)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var proxyReq ProxyRequest
+		if err := json.Unmarshal(raw, &proxyReq); err != nil {
+			writeResult(StreamResult{Line: line, Message: "Invalid JSON payload"})
+			continue
+		}
+		s.applyDefaults(&proxyReq, r)
+		if proxyReq.Payload == nil {
+			writeResult(StreamResult{Line: line, Message: "Payload cannot be empty"})
+			continue
+		}
+		if perr := s.authorizeRecord(r, &proxyReq); perr != nil {
+			writeResult(StreamResult{Line: line, Message: "Forbidden: " + perr.Error(), Rule: perr.Rule})
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-r.Context().Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(line int, proxyReq ProxyRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sysID, err := s.ingest(&proxyReq)
+			if err != nil {
+				writeResult(StreamResult{Line: line, Message: "Failed to send to ServiceNow"})
+				return
+			}
+			writeResult(StreamResult{
+				Line:    line,
+				Success: true,
+				Message: "Data sent to ServiceNow successfully",
+				SysID:   sysID,
+			})
+		}(line, proxyReq)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	summary.Done = true
+	if err := scanner.Err(); err != nil {
+		summary.Error = fmt.Sprintf("stream aborted after line %d: %v", line, err)
+	}
+	summary.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	if err := encoder.Encode(summary); err != nil {
+		log.Printf("Failed to write stream summary: %v", err)
+		return
+	}
+	rc.Flush()
+}
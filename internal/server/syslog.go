@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"litemidgo/internal/syslog"
+)
+
+// startSyslog binds the syslog listeners; matching messages are forwarded
+// through the same path as records posted to /proxy/ecc_queue.
+func (s *Server) startSyslog() error {
+	receiver, err := syslog.NewReceiver(&s.config.Syslog, s.forwardRecord)
+	if err != nil {
+		return fmt.Errorf("invalid syslog configuration: %w", err)
+	}
+
+	if err := receiver.Start(); err != nil {
+		return err
+	}
+	s.syslog = receiver
+	return nil
+}
+
+func (s *Server) handleSyslogStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.syslog == nil {
+		response := ProxyResponse{
+			Success:   false,
+			Message:   "Syslog receiver is not enabled",
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		s.writeJSONResponse(w, http.StatusNotFound, response)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"stats":     s.syslog.Stats(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
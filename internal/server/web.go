@@ -0,0 +1,166 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"time"
+)
+
+//go:embed web
+var webAssets embed.FS
+
+const (
+	// webUpdateInterval is how often the dashboard stream sends a snapshot
+	webUpdateInterval = time.Second
+	// webInitialRequests is the number of recent requests in the first snapshot
+	webInitialRequests = 100
+	// webMaxAgents is the number of recently seen agents in a snapshot
+	webMaxAgents = 25
+)
+
+// webSnapshot is one update of the web dashboard. Requests only holds the
+// records logged since the previous update on the same stream.
+type webSnapshot struct {
+	Time     time.Time         `json:"time"`
+	Health   webHealth         `json:"health"`
+	Stats    RequestStats      `json:"stats"`
+	Requests []RequestLogEntry `json:"requests"`
+	Agents   []AgentInfo       `json:"agents"`
+}
+
+type webHealth struct {
+	Status         string    `json:"status"`
+	Instance       string    `json:"instance"`
+	ConnectionPath string    `json:"connection_path"`
+	StartedAt      time.Time `json:"started_at"`
+	Receivers      []string  `json:"receivers"`
+}
+
+// webRoutes registers the dashboard page, its assets and the event stream
+func (s *Server) webRoutes(mux *http.ServeMux) error {
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		return fmt.Errorf("failed to load web dashboard: %w", err)
+	}
+	files := http.StripPrefix("/ui/", http.FileServerFS(assets))
+
+	mux.HandleFunc("/ui/", s.protect(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	mux.HandleFunc("/ui", s.SecurityHeaders(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+	}))
+	mux.HandleFunc("/ui/events", s.protect(s.handleWebEvents))
+	return nil
+}
+
+// handleWebEvents streams dashboard snapshots as server-sent events
+func (s *Server) handleWebEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var seq uint64
+	if requests := s.requestLog.since(0); len(requests) > webInitialRequests {
+		seq = requests[len(requests)-webInitialRequests-1].Seq
+	}
+
+	ticker := time.NewTicker(webUpdateInterval)
+	defer ticker.Stop()
+	for {
+		snapshot := s.webSnapshot(seq)
+		if n := len(snapshot.Requests); n > 0 {
+			seq = snapshot.Requests[n-1].Seq
+		}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) webSnapshot(seq uint64) webSnapshot {
+	stats := s.stats.snapshot()
+	health := webHealth{
+		Status:         "ok",
+		Instance:       s.snowClient.GetInstanceURL(),
+		ConnectionPath: s.snowClient.ConnectionPath().String(),
+		StartedAt:      s.started,
+		Receivers:      []string{},
+	}
+	if stats.UpstreamFailing() {
+		health.Status = "degraded"
+	}
+	for name, enabled := range map[string]bool{
+		"syslog":       s.syslog != nil,
+		"snmp":         s.snmp != nil,
+		"sensu":        s.sensu != nil,
+		"alertmanager": s.alertmanager != nil,
+	} {
+		if enabled {
+			health.Receivers = append(health.Receivers, name)
+		}
+	}
+	sort.Strings(health.Receivers)
+
+	// Payloads and responses stay in the TUI; the page only lists requests
+	requests := s.requestLog.since(seq)
+	for i := range requests {
+		requests[i].Payload = ""
+		requests[i].Response = ""
+	}
+	if requests == nil {
+		requests = []RequestLogEntry{}
+	}
+
+	return webSnapshot{
+		Time:     time.Now(),
+		Health:   health,
+		Stats:    stats,
+		Requests: requests,
+		Agents:   s.recentAgents(),
+	}
+}
+
+// recentAgents returns the most recently seen agents of the registry
+func (s *Server) recentAgents() []AgentInfo {
+	agents := s.agents.list()
+	sort.Slice(agents, func(i, j int) bool { return agents[i].LastSeen.After(agents[j].LastSeen) })
+	if len(agents) > webMaxAgents {
+		agents = agents[:webMaxAgents]
+	}
+	return agents
+}
@@ -0,0 +1,171 @@
+// LiteMIDgo web dashboard: renders the snapshots sent on /ui/events.
+(function () {
+  "use strict";
+
+  const MAX_REQUESTS = 100;
+  const MAX_ERRORS = 20;
+  const LATENCY_POINTS = 300;
+
+  const requests = [];
+  const latency = [];
+
+  const $ = (id) => document.getElementById(id);
+
+  function ms(nanoseconds) {
+    return nanoseconds / 1e6;
+  }
+
+  function formatLatency(nanoseconds) {
+    const value = ms(nanoseconds);
+    return value < 1 ? value.toFixed(2) + " ms" : Math.round(value) + " ms";
+  }
+
+  function formatTime(value) {
+    return new Date(value).toLocaleTimeString();
+  }
+
+  function formatDuration(seconds) {
+    const d = Math.floor(seconds / 86400);
+    const h = Math.floor((seconds % 86400) / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    const s = Math.floor(seconds % 60);
+    return (d ? d + "d " : "") + (d || h ? h + "h " : "") + m + "m " + s + "s";
+  }
+
+  // row builds a table row; cells are set as text so record fields are never
+  // interpreted as HTML
+  function row(cells, classes) {
+    const tr = document.createElement("tr");
+    cells.forEach((text, i) => {
+      const td = document.createElement("td");
+      td.textContent = text;
+      if (classes && classes[i]) {
+        td.className = classes[i];
+      }
+      tr.appendChild(td);
+    });
+    return tr;
+  }
+
+  function fill(id, rows) {
+    $(id).replaceChildren(...rows);
+  }
+
+  function drawBars(canvas, values) {
+    const ctx = canvas.getContext("2d");
+    const { width, height } = canvas;
+    ctx.clearRect(0, 0, width, height);
+    const peak = Math.max(1, ...values);
+    const barWidth = width / values.length;
+    ctx.fillStyle = "#a49bf5";
+    values.forEach((value, i) => {
+      const barHeight = (value / peak) * (height - 20);
+      ctx.fillRect(i * barWidth + 1, height - barHeight, barWidth - 2, barHeight);
+    });
+    ctx.fillStyle = "#8a87a0";
+    ctx.font = "12px sans-serif";
+    ctx.fillText("peak " + peak + "/s", 4, 12);
+  }
+
+  function drawLines(canvas, series) {
+    const ctx = canvas.getContext("2d");
+    const { width, height } = canvas;
+    ctx.clearRect(0, 0, width, height);
+    const peak = Math.max(1, ...series.flatMap((s) => s.values));
+    series.forEach((s) => {
+      ctx.strokeStyle = s.color;
+      ctx.lineWidth = 2;
+      ctx.beginPath();
+      s.values.forEach((value, i) => {
+        const x = (i / Math.max(1, LATENCY_POINTS - 1)) * width;
+        const y = height - (value / peak) * (height - 20);
+        if (i === 0) {
+          ctx.moveTo(x, y);
+        } else {
+          ctx.lineTo(x, y);
+        }
+      });
+      ctx.stroke();
+    });
+    ctx.fillStyle = "#8a87a0";
+    ctx.font = "12px sans-serif";
+    ctx.fillText("max " + peak.toFixed(1) + " ms", 4, 12);
+  }
+
+  function render(snapshot) {
+    const { health, stats } = snapshot;
+
+    const status = $("status");
+    status.textContent = health.status === "ok" ? "healthy" : "upstream failing";
+    status.className = "pill " + health.status;
+    $("instance").textContent = health.instance;
+    const started = new Date(health.started_at);
+    $("uptime").textContent = "up " + formatDuration((new Date(snapshot.time) - started) / 1000);
+
+    $("total").textContent = stats.total;
+    $("success").textContent = stats.success;
+    $("failed").textContent = stats.failed;
+    $("inflight").textContent = stats.in_flight;
+    $("latency").textContent = stats.latency_p50
+      ? [stats.latency_p50, stats.latency_p90, stats.latency_p99].map(formatLatency).join(" / ")
+      : "n/a";
+
+    // The last second is still being counted
+    drawBars($("rate"), stats.per_second.slice(0, -1));
+    latency.push([ms(stats.latency_p50), ms(stats.latency_p99)]);
+    if (latency.length > LATENCY_POINTS) {
+      latency.shift();
+    }
+    drawLines($("latencyChart"), [
+      { color: "#4ade80", values: latency.map((l) => l[0]) },
+      { color: "#f87171", values: latency.map((l) => l[1]) },
+    ]);
+
+    requests.push(...snapshot.requests);
+    requests.splice(0, Math.max(0, requests.length - MAX_REQUESTS));
+    fill(
+      "requests",
+      requests
+        .slice()
+        .reverse()
+        .map((r) =>
+          row(
+            [formatTime(r.time), r.source, r.agent, r.topic, r.name, r.success ? "ok" : "failed", formatLatency(r.latency), r.sys_id || "-"],
+            [null, null, null, null, null, r.success ? "ok" : "bad"]
+          )
+        )
+    );
+
+    const failures = requests.filter((r) => !r.success).slice(-MAX_ERRORS).reverse();
+    $("upstreamError").classList.toggle("hidden", failures.length === 0 && !stats.last_error);
+    $("lastError").textContent = stats.last_error
+      ? stats.consecutive_failures + " consecutive failure(s); last error at " + formatTime(stats.last_error_at) + ": " + stats.last_error
+      : "";
+    fill(
+      "errors",
+      failures.map((r) => row([formatTime(r.time), r.agent, r.topic, r.error], [null, null, null, "error"]))
+    );
+
+    fill(
+      "agents",
+      snapshot.agents.map((a) =>
+        row(
+          [a.agent, a.source, a.address, a.version || "-", a.status, formatTime(a.last_seen), a.interval ? a.interval + " s" : "-", a.records, a.failed],
+          [null, null, null, null, a.status === "stale" ? "bad" : "ok"]
+        )
+      )
+    );
+
+    $("path").textContent = health.connection_path;
+    $("receivers").textContent = health.receivers.length ? health.receivers.join(", ") : "none";
+    $("updated").textContent = formatTime(snapshot.time);
+  }
+
+  const events = new EventSource("events");
+  events.addEventListener("snapshot", (e) => render(JSON.parse(e.data)));
+  events.onerror = () => {
+    const status = $("status");
+    status.textContent = "disconnected";
+    status.className = "pill down";
+  };
+})();
@@ -0,0 +1,140 @@
+package servicenow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// minUploadRate is the slowest attachment upload allowed, in bytes per second.
+// An upload of n bytes must finish within the client timeout plus the time n
+// bytes take at this rate.
+const minUploadRate = 64 * 1024
+
+// Attachment is a sys_attachment record as returned by the Attachment API
+type Attachment struct {
+	SysID        string `json:"sys_id"`
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"`
+	SizeBytes    string `json:"size_bytes"`
+	TableName    string `json:"table_name"`
+	TableSysID   string `json:"table_sys_id"`
+	DownloadLink string `json:"download_link"`
+}
+
+type AttachmentResponse struct {
+	Result Attachment `json:"result"`
+	Error  struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	} `json:"error"`
+}
+
+// UploadAttachment streams content to /api/now/attachment/file, attaching it
+// to the record sysID of table. The content is not buffered, so uploads of any
+// size use constant memory. size is the content length, or an upper bound on
+// it, and sets the upload deadline; with size 0 there is no overall deadline.
+// Either way the upload is cancelled when content or the response stalls for
+// longer than the client timeout.
+func (c *Client) UploadAttachment(ctx context.Context, table, sysID, fileName, contentType string, content io.Reader, size int64) (*Attachment, error) {
+	query := url.Values{}
+	query.Set("table_name", table)
+	query.Set("table_sys_id", sysID)
+	query.Set("file_name", fileName)
+	apiURL := fmt.Sprintf("%s://%s/api/now/attachment/file?%s", c.getProtocol(), c.instance, query.Encode())
+
+	deadline := time.Duration(0)
+	if size > 0 {
+		deadline = c.timeout + time.Duration(size/minUploadRate)*time.Second
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeout(ctx, deadline)
+		defer cancelDeadline()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stalled atomic.Bool
+	stall := time.AfterFunc(c.timeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer stall.Stop()
+	content = &progressReader{reader: content, progress: func() { stall.Reset(c.timeout) }}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	// The overall client timeout would cut off large uploads; the deadline
+	// and stall timer above bound the transfer instead
+	client := *c.httpClient
+	client.Timeout = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, uploadError(ctx, &stalled, c.timeout, deadline, "failed to send request", err)
+	}
+	defer resp.Body.Close()
+	stall.Reset(c.timeout)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, uploadError(ctx, &stalled, c.timeout, deadline, "failed to read response", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("ServiceNow API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var attachmentResp AttachmentResponse
+	if err := json.Unmarshal(body, &attachmentResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w\nResponse body: %s", err, string(body))
+	}
+
+	if attachmentResp.Error.Message != "" {
+		return nil, fmt.Errorf("ServiceNow error: %s - %s", attachmentResp.Error.Message, attachmentResp.Error.Detail)
+	}
+
+	if attachmentResp.Result.SysID == "" {
+		return nil, fmt.Errorf("ServiceNow error: No SysID returned in response")
+	}
+
+	return &attachmentResp.Result, nil
+}
+
+// uploadError explains why an upload failed, naming the timeout that cut it off
+func uploadError(ctx context.Context, stalled *atomic.Bool, timeout, deadline time.Duration, failed string, err error) error {
+	switch {
+	case stalled.Load():
+		return fmt.Errorf("attachment upload stalled for %s: %w", timeout, err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("attachment upload did not finish within %s: %w", deadline, err)
+	}
+	return fmt.Errorf("%s: %w", failed, err)
+}
+
+// progressReader reports every read of an upload body
+type progressReader struct {
+	reader   io.Reader
+	progress func()
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.progress()
+	return n, err
+}
@@ -0,0 +1,201 @@
+package servicenow
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxRetryBackoff caps the wait between attempts to send a failed batch
+const maxRetryBackoff = 5 * time.Minute
+
+// EventBatcher queues events and sends them to Event Management in batches,
+// either when size events are pending or every interval, whichever comes
+// first. At most capacity events are queued; more are dropped. A batch that
+// fails to send stays at the head of the queue and is retried with a backoff
+// that doubles from interval up to maxRetryBackoff, unless the instance
+// rejected it, in which case it is logged and dropped.
+type EventBatcher struct {
+	client   *Client
+	size     int
+	capacity int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []Event
+	backoff time.Duration
+	retryAt time.Time
+
+	// sending serializes Flush, which sends from the head of pending
+	sending sync.Mutex
+
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+	retries atomic.Int64
+}
+
+// BatcherStats counts events handled by an EventBatcher. Failed events were
+// rejected by the instance, Dropped events arrived with the queue full and
+// Retries counts failed attempts that are retried.
+type BatcherStats struct {
+	Pending int   `json:"pending"`
+	Sent    int64 `json:"sent"`
+	Failed  int64 `json:"failed"`
+	Dropped int64 `json:"dropped"`
+	Retries int64 `json:"retries"`
+}
+
+func NewEventBatcher(client *Client, size, capacity int, interval time.Duration) *EventBatcher {
+	if size < 1 {
+		size = 100
+	}
+	if capacity < size {
+		capacity = size
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	b := &EventBatcher{
+		client:   client,
+		size:     size,
+		capacity: capacity,
+		interval: interval,
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Add queues an event for the next batch. It returns false, and counts the
+// event as dropped, when the queue is full.
+func (b *EventBatcher) Add(event Event) bool {
+	b.mu.Lock()
+	if len(b.pending) >= b.capacity {
+		b.mu.Unlock()
+		b.dropped.Add(1)
+		return false
+	}
+	b.pending = append(b.pending, event)
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (b *EventBatcher) loop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			if err := b.Flush(); err != nil {
+				if n := b.Stats().Pending; n > 0 {
+					log.Printf("Dropping %d unsent event(s) on shutdown", n)
+				}
+			}
+			return
+		case <-ticker.C:
+			if b.due() {
+				b.Flush()
+			}
+		case <-b.full:
+			if b.due() {
+				b.Flush()
+			}
+		}
+	}
+}
+
+// due reports whether the backoff after a failed batch has passed
+func (b *EventBatcher) due() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.retryAt)
+}
+
+// Flush sends everything queued so far. It stops at the first batch that
+// fails and may succeed later, which is kept for the next attempt, and
+// returns that error.
+func (b *EventBatcher) Flush() error {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+
+	for {
+		b.mu.Lock()
+		n := min(len(b.pending), b.size)
+		batch := b.pending[:n:n]
+		b.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		_, err := b.client.SendEvents(batch)
+
+		var apiErr *APIError
+		if err != nil && (!errors.As(err, &apiErr) || apiErr.Temporary()) {
+			b.mu.Lock()
+			b.backoff = min(max(2*b.backoff, b.interval), maxRetryBackoff)
+			b.retryAt = time.Now().Add(b.backoff)
+			backoff := b.backoff
+			b.mu.Unlock()
+			b.retries.Add(1)
+			log.Printf("Failed to send %d event(s) to ServiceNow, retrying in %s: %v", n, backoff, err)
+			return err
+		}
+
+		b.mu.Lock()
+		b.pending = b.pending[n:]
+		if len(b.pending) == 0 {
+			b.pending = nil
+		}
+		b.backoff = 0
+		b.retryAt = time.Time{}
+		b.mu.Unlock()
+
+		if err != nil {
+			log.Printf("ServiceNow rejected %d event(s), dropping them: %v", n, err)
+			b.failed.Add(int64(n))
+			continue
+		}
+		b.sent.Add(int64(n))
+	}
+}
+
+// Close stops the background flush and sends any remaining events
+func (b *EventBatcher) Close() {
+	close(b.done)
+	b.wg.Wait()
+}
+
+func (b *EventBatcher) Stats() BatcherStats {
+	b.mu.Lock()
+	pending := len(b.pending)
+	b.mu.Unlock()
+
+	return BatcherStats{
+		Pending: pending,
+		Sent:    b.sent.Load(),
+		Failed:  b.failed.Load(),
+		Dropped: b.dropped.Load(),
+		Retries: b.retries.Load(),
+	}
+}
@@ -0,0 +1,119 @@
+package servicenow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"litemidgo/config"
+)
+
+// eventInstance answers the events API with the queued status codes, then 200
+type eventInstance struct {
+	mu       sync.Mutex
+	statuses []int
+	received []Event
+}
+
+func (e *eventInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Records []Event `json:"records"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.statuses) > 0 {
+		status := e.statuses[0]
+		e.statuses = e.statuses[1:]
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	e.received = append(e.received, body.Records...)
+	w.Write([]byte(`{"result":{}}`))
+}
+
+func newEventClient(t *testing.T, statuses ...int) (*Client, *eventInstance) {
+	t.Helper()
+	instance := &eventInstance{statuses: statuses}
+	srv := httptest.NewServer(instance)
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(&config.ServiceNowConfig{
+		Instance: strings.TrimPrefix(srv.URL, "http://"),
+		Username: "admin",
+		Password: "admin",
+		Timeout:  5,
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, instance
+}
+
+func TestEventBatcherDropsWhenFull(t *testing.T) {
+	client, instance := newEventClient(t)
+	b := NewEventBatcher(client, 2, 3, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		b.Add(Event{Node: "web01"})
+	}
+	if stats := b.Stats(); stats.Pending != 3 || stats.Dropped != 2 {
+		t.Errorf("got %+v, want 3 pending and 2 dropped", stats)
+	}
+
+	b.Close()
+	if n := len(instance.received); n != 3 {
+		t.Errorf("instance received %d events, want 3", n)
+	}
+}
+
+func TestEventBatcherRetriesFailedBatch(t *testing.T) {
+	client, instance := newEventClient(t, http.StatusServiceUnavailable)
+	b := NewEventBatcher(client, 10, 100, time.Hour)
+	defer b.Close()
+
+	b.Add(Event{Node: "web01"})
+	b.Add(Event{Node: "web02"})
+	if err := b.Flush(); err == nil {
+		t.Fatal("Flush: expected the outage to be returned")
+	}
+	if stats := b.Stats(); stats.Pending != 2 || stats.Retries != 1 || stats.Failed != 0 {
+		t.Fatalf("after the failure got %+v, want both events kept for a retry", stats)
+	}
+	if b.due() {
+		t.Error("batch is due again before the backoff has passed")
+	}
+
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush retry: %v", err)
+	}
+	if stats := b.Stats(); stats.Pending != 0 || stats.Sent != 2 {
+		t.Errorf("after the retry got %+v, want 2 sent", stats)
+	}
+	if len(instance.received) != 2 || instance.received[0].Node != "web01" {
+		t.Errorf("instance received %+v, want web01 and web02 in order", instance.received)
+	}
+}
+
+func TestEventBatcherDropsRejectedBatch(t *testing.T) {
+	client, instance := newEventClient(t, http.StatusBadRequest)
+	b := NewEventBatcher(client, 1, 100, time.Hour)
+	defer b.Close()
+
+	b.Add(Event{Node: "bad"})
+	b.Add(Event{Node: "good"})
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if stats := b.Stats(); stats.Failed != 1 || stats.Sent != 1 || stats.Pending != 0 {
+		t.Errorf("got %+v, want the rejected event failed and the next sent", stats)
+	}
+	if len(instance.received) != 1 || instance.received[0].Node != "good" {
+		t.Errorf("instance received %+v, want only the good event", instance.received)
+	}
+}
@@ -2,10 +2,12 @@ package servicenow
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"litemidgo/config"
@@ -18,6 +20,11 @@ type Client struct {
 	useHTTPS   bool
 	timeout    time.Duration
 	httpClient *http.Client
+
+	// pathMu guards path, whose TLSVersion TestConnection records while
+	// other requests may be reading it
+	pathMu sync.RWMutex
+	path   ConnectionPath
 }
 
 type ECCQueuePayload struct {
@@ -28,43 +35,51 @@ type ECCQueuePayload struct {
 	Payload  interface{} `json:"payload"`
 }
 
+// ECCRecord is an ecc_queue record as returned by the Table API
+type ECCRecord struct {
+	Agent           string `json:"agent"`
+	Signature       string `json:"signature"`
+	ResponseTo      string `json:"response_to"`
+	SysModCount     string `json:"sys_mod_count"`
+	FromSysID       string `json:"from_sys_id"`
+	Source          string `json:"source"`
+	SysUpdatedOn    string `json:"sys_updated_on"`
+	AgentCorrelator string `json:"agent_correlator"`
+	Priority        string `json:"priority"`
+	SysDomainPath   string `json:"sys_domain_path"`
+	ErrorString     string `json:"error_string"`
+	Processed       string `json:"processed"`
+	Sequence        string `json:"sequence"`
+	SysID           string `json:"sys_id"`
+	SysUpdatedBy    string `json:"sys_updated_by"`
+	FromHost        string `json:"from_host"`
+	Payload         string `json:"payload"`
+	SysCreatedOn    string `json:"sys_created_on"`
+	SysDomain       struct {
+		Link  string `json:"link"`
+		Value string `json:"value"`
+	} `json:"sys_domain"`
+	Name        string `json:"name"`
+	Topic       string `json:"topic"`
+	State       string `json:"state"`
+	Queue       string `json:"queue"`
+	SysCreatedBy string `json:"sys_created_by"`
+}
+
 type ECCQueueResponse struct {
-	Result struct {
-		Agent           string `json:"agent"`
-		Signature       string `json:"signature"`
-		ResponseTo      string `json:"response_to"`
-		SysModCount     string `json:"sys_mod_count"`
-		FromSysID       string `json:"from_sys_id"`
-		Source          string `json:"source"`
-		SysUpdatedOn    string `json:"sys_updated_on"`
-		AgentCorrelator string `json:"agent_correlator"`
-		Priority        string `json:"priority"`
-		SysDomainPath   string `json:"sys_domain_path"`
-		ErrorString     string `json:"error_string"`
-		Processed       string `json:"processed"`
-		Sequence        string `json:"sequence"`
-		SysID           string `json:"sys_id"`
-		SysUpdatedBy    string `json:"sys_updated_by"`
-		FromHost        string `json:"from_host"`
-		Payload         string `json:"payload"`
-		SysCreatedOn    string `json:"sys_created_on"`
-		SysDomain       struct {
-			Link  string `json:"link"`
-			Value string `json:"value"`
-		} `json:"sys_domain"`
-		Name        string `json:"name"`
-		Topic       string `json:"topic"`
-		State       string `json:"state"`
-		Queue       string `json:"queue"`
-		SysCreatedBy string `json:"sys_created_by"`
-	} `json:"result"`
+	Result ECCRecord `json:"result"`
 	Error struct {
 		Message string `json:"message"`
 		Detail  string `json:"detail"`
 	} `json:"error"`
 }
 
-func NewClient(cfg *config.ServiceNowConfig) *Client {
+func NewClient(cfg *config.ServiceNowConfig) (*Client, error) {
+	transport, path, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
 		instance: cfg.Instance,
 		username: cfg.Username,
@@ -72,9 +87,11 @@ func NewClient(cfg *config.ServiceNowConfig) *Client {
 		useHTTPS: cfg.UseHTTPS,
 		timeout:  time.Duration(cfg.Timeout) * time.Second,
 		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+			Transport: transport,
 		},
-	}
+		path: path,
+	}, nil
 }
 
 func (c *Client) SendToECCQueue(payload *ECCQueuePayload) (*ECCQueueResponse, error) {
@@ -152,6 +169,12 @@ func (c *Client) TestConnection() error {
 	}
 	defer resp.Body.Close()
 
+	if resp.TLS != nil {
+		c.pathMu.Lock()
+		c.path.TLSVersion = tls.VersionName(resp.TLS.Version)
+		c.pathMu.Unlock()
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("ServiceNow connection test failed: %d", resp.StatusCode)
 	}
@@ -159,6 +182,14 @@ func (c *Client) TestConnection() error {
 	return nil
 }
 
+// ConnectionPath reports the proxy, CA and TLS settings used to reach the
+// instance, including the TLS version negotiated by TestConnection
+func (c *Client) ConnectionPath() ConnectionPath {
+	c.pathMu.RLock()
+	defer c.pathMu.RUnlock()
+	return c.path
+}
+
 func (c *Client) GetInstanceURL() string {
 	return fmt.Sprintf("%s://%s", c.getProtocol(), c.instance)
 }
@@ -169,3 +200,139 @@ func (c *Client) getProtocol() string {
 	}
 	return "http"
 }
+
+// Event is a ServiceNow Event Management event (em_event). Severity is a
+// string from "0" (clear) to "5" (info); events sharing a MessageKey update
+// the same alert.
+type Event struct {
+	Source         string                 `json:"source"`
+	Node           string                 `json:"node,omitempty"`
+	Type           string                 `json:"type,omitempty"`
+	Resource       string                 `json:"resource,omitempty"`
+	MetricName     string                 `json:"metric_name,omitempty"`
+	EventClass     string                 `json:"event_class,omitempty"`
+	Severity       string                 `json:"severity"`
+	MessageKey     string                 `json:"message_key,omitempty"`
+	Description    string                 `json:"description,omitempty"`
+	TimeOfEvent    string                 `json:"time_of_event,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes AdditionalInfo as the JSON string the events API expects
+func (e Event) MarshalJSON() ([]byte, error) {
+	type wireEvent Event
+	wire := struct {
+		wireEvent
+		AdditionalInfo string `json:"additional_info,omitempty"`
+	}{wireEvent: wireEvent(e)}
+
+	if len(e.AdditionalInfo) > 0 {
+		info, err := json.Marshal(e.AdditionalInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal additional_info: %w", err)
+		}
+		wire.AdditionalInfo = string(info)
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON accepts additional_info either as an object or as the JSON
+// string form the events API uses
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type wireEvent Event
+	wire := struct {
+		*wireEvent
+		AdditionalInfo json.RawMessage `json:"additional_info"`
+	}{wireEvent: (*wireEvent)(e)}
+
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	info := wire.AdditionalInfo
+	var encoded string
+	if json.Unmarshal(info, &encoded) == nil {
+		if encoded == "" {
+			return nil
+		}
+		info = json.RawMessage(encoded)
+	}
+	if len(info) == 0 || string(info) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(info, &e.AdditionalInfo); err != nil {
+		return fmt.Errorf("additional_info must be a JSON object: %w", err)
+	}
+	return nil
+}
+
+type EventResponse struct {
+	Result map[string]interface{} `json:"result"`
+	Error  struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	} `json:"error"`
+}
+
+// APIError is a request the instance answered with an error status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ServiceNow API error: %d - %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether sending the request again may succeed: the
+// instance was unavailable, overloaded or failed, rather than rejecting it
+func (e *APIError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout
+}
+
+// SendEvents posts events to the Event Management JSONv2 endpoint
+func (c *Client) SendEvents(events []Event) (*EventResponse, error) {
+	apiURL := fmt.Sprintf("%s://%s/api/global/em/jsonv2", c.getProtocol(), c.instance)
+
+	jsonData, err := json.Marshal(map[string]interface{}{"records": events})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var eventResp EventResponse
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &eventResp); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w\nResponse body: %s", err, string(body))
+		}
+	}
+
+	if eventResp.Error.Message != "" {
+		return nil, fmt.Errorf("ServiceNow error: %s - %s", eventResp.Error.Message, eventResp.Error.Detail)
+	}
+
+	return &eventResp, nil
+}
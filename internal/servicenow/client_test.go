@@ -0,0 +1,62 @@
+package servicenow
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"litemidgo/config"
+)
+
+// TestConnectionPathConcurrent runs TestConnection, as concurrent /health
+// requests do, while the connection path is read; run with -race.
+func TestConnectionPathConcurrent(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(&config.ServiceNowConfig{
+		Instance: strings.TrimPrefix(srv.URL, "https://"),
+		Username: "admin",
+		Password: "admin",
+		UseHTTPS: true,
+		Timeout:  5,
+		TLS:      config.TLSConfig{CAFiles: []string{caFile}},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := client.TestConnection(); err != nil {
+				t.Errorf("TestConnection: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_ = client.ConnectionPath().String()
+		}()
+	}
+	wg.Wait()
+
+	if version := client.ConnectionPath().TLSVersion; version != "TLS 1.3" {
+		t.Errorf("TLSVersion = %q, want %q", version, "TLS 1.3")
+	}
+}
@@ -0,0 +1,110 @@
+package servicenow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// IREPayload is the body of an Identification and Reconciliation request.
+// Relations refer to items by their index in Items.
+type IREPayload struct {
+	Items     []IREItem     `json:"items"`
+	Relations []IRERelation `json:"relations,omitempty"`
+}
+
+type IREItem struct {
+	ClassName string                 `json:"className"`
+	Values    map[string]interface{} `json:"values"`
+}
+
+type IRERelation struct {
+	Parent int    `json:"parent"`
+	Child  int    `json:"child"`
+	Type   string `json:"type"`
+}
+
+type IREError struct {
+	ErrorType string `json:"error"`
+	Message   string `json:"message"`
+}
+
+// IREItemResult reports what the IRE did with one item of the payload
+type IREItemResult struct {
+	ClassName string     `json:"className"`
+	Operation string     `json:"operation"`
+	SysID     string     `json:"sysId"`
+	Errors    []IREError `json:"errors"`
+}
+
+type IRERelationResult struct {
+	ClassName string     `json:"className"`
+	Operation string     `json:"operation"`
+	SysID     string     `json:"sysId"`
+	Errors    []IREError `json:"errors"`
+}
+
+type IREResponse struct {
+	Result struct {
+		Items     []IREItemResult     `json:"items"`
+		Relations []IRERelationResult `json:"relations"`
+	} `json:"result"`
+	Error struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	} `json:"error"`
+}
+
+// IdentifyReconcile sends CIs to the Identification and Reconciliation API
+// under the given data source. With dryRun the instance only reports how the
+// items would be identified (/identifyreconcile/query) and nothing is written.
+func (c *Client) IdentifyReconcile(payload *IREPayload, dataSource string, dryRun bool) (*IREResponse, error) {
+	path := "/api/now/identifyreconcile"
+	if dryRun {
+		path += "/query"
+	}
+	apiURL := fmt.Sprintf("%s://%s%s?sysparm_data_source=%s", c.getProtocol(), c.instance, path, url.QueryEscape(dataSource))
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("ServiceNow API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var ireResp IREResponse
+	if err := json.Unmarshal(body, &ireResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w\nResponse body: %s", err, string(body))
+	}
+
+	if ireResp.Error.Message != "" {
+		return nil, fmt.Errorf("ServiceNow error: %s - %s", ireResp.Error.Message, ireResp.Error.Detail)
+	}
+
+	return &ireResp, nil
+}
@@ -0,0 +1,256 @@
+package servicenow
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Diagnostic check statuses
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// DiagnosticCheck is the result of one step of Diagnose
+type DiagnosticCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+const (
+	certExpiryWarning = 30 * 24 * time.Hour
+	clockSkewWarning  = 30 * time.Second
+	clockSkewFailure  = 5 * time.Minute
+)
+
+// Diagnose steps through what a connection to the instance needs: DNS, TCP,
+// TLS and certificate expiry, authentication, read access to sys_user, write
+// access to ecc_queue (a probe record is inserted and deleted) and clock skew.
+// Steps that depend on a failed step are skipped.
+func (c *Client) Diagnose(ctx context.Context) []DiagnosticCheck {
+	var checks []DiagnosticCheck
+	failed := false
+	run := func(name string, step func() DiagnosticCheck) {
+		if failed {
+			checks = append(checks, DiagnosticCheck{Name: name, Status: CheckSkip, Detail: "skipped after an earlier failure"})
+			return
+		}
+		start := time.Now()
+		check := step()
+		check.Name = name
+		check.DurationMS = time.Since(start).Milliseconds()
+		failed = check.Status == CheckFail
+		checks = append(checks, check)
+	}
+
+	// DNS and TCP go to the proxy when one is used; the proxy resolves and
+	// connects to the instance itself
+	target, via := c.dialTarget()
+	host, port, _ := net.SplitHostPort(target)
+
+	run("DNS resolution", func() DiagnosticCheck {
+		if net.ParseIP(host) != nil {
+			return DiagnosticCheck{Status: CheckPass, Detail: fmt.Sprintf("%s is an IP address%s", host, via)}
+		}
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return DiagnosticCheck{Status: CheckFail, Detail: err.Error(),
+				Hint: "Check the instance name (servicenow.instance) and the DNS servers; behind a proxy set servicenow.proxy.url"}
+		}
+		return DiagnosticCheck{Status: CheckPass, Detail: fmt.Sprintf("%s → %s%s", host, strings.Join(addrs, ", "), via)}
+	})
+
+	run("TCP connect", func() DiagnosticCheck {
+		dialer := net.Dialer{Timeout: c.timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", target)
+		if err != nil {
+			return DiagnosticCheck{Status: CheckFail, Detail: err.Error(),
+				Hint: fmt.Sprintf("Check firewalls and outbound rules for port %s; a proxy may be required (servicenow.proxy.url)", port)}
+		}
+		conn.Close()
+		return DiagnosticCheck{Status: CheckPass, Detail: fmt.Sprintf("connected to %s%s", target, via)}
+	})
+
+	// One authenticated read serves the TLS, auth, read ACL and clock checks
+	var resp *http.Response
+	defer func() {
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}()
+
+	if !c.useHTTPS {
+		run("HTTP request (no TLS)", func() DiagnosticCheck {
+			var err error
+			if resp, err = c.doProbe(ctx, http.MethodGet, "/api/now/table/sys_user?sysparm_limit=1&sysparm_fields=sys_id", nil); err != nil {
+				return DiagnosticCheck{Status: CheckFail, Detail: err.Error(), Hint: "Check network access to the instance"}
+			}
+			return DiagnosticCheck{Status: CheckWarn, Detail: "use_https is false; traffic is not encrypted",
+				Hint: "Set servicenow.use_https: true for real instances"}
+		})
+	} else {
+		run("TLS handshake", func() DiagnosticCheck {
+			var err error
+			if resp, err = c.doProbe(ctx, http.MethodGet, "/api/now/table/sys_user?sysparm_limit=1&sysparm_fields=sys_id", nil); err != nil {
+				return DiagnosticCheck{Status: CheckFail, Detail: err.Error(),
+					Hint: "If a proxy inspects TLS, add its root CA to servicenow.tls.ca_files; check servicenow.tls.min_version and the client certificate"}
+			}
+			state := resp.TLS
+			if state == nil || len(state.PeerCertificates) == 0 {
+				return DiagnosticCheck{Status: CheckFail, Detail: "no TLS connection state"}
+			}
+			leaf := state.PeerCertificates[0]
+			detail := fmt.Sprintf("%s, %s, certificate %s expires %s", tls.VersionName(state.Version),
+				tls.CipherSuiteName(state.CipherSuite), leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02"))
+			if remaining := time.Until(leaf.NotAfter); remaining < certExpiryWarning {
+				return DiagnosticCheck{Status: CheckWarn, Detail: detail + fmt.Sprintf(" (in %d days)", int(remaining.Hours()/24)),
+					Hint: "The instance certificate expires soon; contact the instance owner"}
+			}
+			return DiagnosticCheck{Status: CheckPass, Detail: detail}
+		})
+	}
+
+	run("Authentication", func() DiagnosticCheck {
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			return DiagnosticCheck{Status: CheckFail, Detail: fmt.Sprintf("HTTP 401 as %s", c.username),
+				Hint: "Check servicenow.username and servicenow.password; the account may be locked or lack web service access"}
+		case http.StatusOK, http.StatusForbidden:
+			return DiagnosticCheck{Status: CheckPass, Detail: fmt.Sprintf("authenticated as %s", c.username)}
+		}
+		return DiagnosticCheck{Status: CheckFail, Detail: fmt.Sprintf("unexpected HTTP %d", resp.StatusCode),
+			Hint: "The instance may be hibernating, under maintenance or not a ServiceNow instance"}
+	})
+
+	run("Read access (sys_user)", func() DiagnosticCheck {
+		if resp.StatusCode == http.StatusForbidden {
+			return DiagnosticCheck{Status: CheckFail, Detail: "HTTP 403 reading sys_user",
+				Hint: "Grant the integration user a role with read access to sys_user (e.g. rest_api_explorer or a custom ACL)"}
+		}
+		return DiagnosticCheck{Status: CheckPass, Detail: "sys_user is readable"}
+	})
+
+	run("Write access (ecc_queue)", func() DiagnosticCheck {
+		return c.probeECCWrite(ctx)
+	})
+
+	run("Clock skew", func() DiagnosticCheck {
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return DiagnosticCheck{Status: CheckWarn, Detail: "instance sent no Date header"}
+		}
+		skew := time.Since(date).Round(time.Second)
+		if skew < 0 {
+			skew = -skew
+		}
+		detail := fmt.Sprintf("local clock differs from the instance by %s", skew)
+		switch {
+		case skew > clockSkewFailure:
+			return DiagnosticCheck{Status: CheckFail, Detail: detail, Hint: "Synchronise the local clock with NTP; tokens and certificates depend on it"}
+		case skew > clockSkewWarning:
+			return DiagnosticCheck{Status: CheckWarn, Detail: detail, Hint: "Synchronise the local clock with NTP"}
+		}
+		return DiagnosticCheck{Status: CheckPass, Detail: detail}
+	})
+
+	return checks
+}
+
+// probeECCWrite inserts a processed probe record into ecc_queue and deletes it
+func (c *Client) probeECCWrite(ctx context.Context) DiagnosticCheck {
+	probe := map[string]string{
+		"agent":  "litemidgo-doctor",
+		"topic":  "LiteMIDgoDoctor",
+		"name":   "connectivity probe",
+		"source": "litemidgo doctor",
+		"queue":  "input",
+		"state":  "processed",
+	}
+	body, _ := json.Marshal(probe)
+	resp, err := c.doProbe(ctx, http.MethodPost, "/api/now/table/ecc_queue", body)
+	if err != nil {
+		return DiagnosticCheck{Status: CheckFail, Detail: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return DiagnosticCheck{Status: CheckFail, Detail: "HTTP 403 inserting into ecc_queue",
+			Hint: "Grant the integration user the mid_server role or an ACL allowing create on ecc_queue"}
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return DiagnosticCheck{Status: CheckFail, Detail: fmt.Sprintf("HTTP %d inserting into ecc_queue", resp.StatusCode)}
+	}
+
+	var created ECCQueueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || created.Result.SysID == "" {
+		return DiagnosticCheck{Status: CheckWarn, Detail: "insert succeeded but no sys_id was returned"}
+	}
+
+	del, err := c.doProbe(ctx, http.MethodDelete, "/api/now/table/ecc_queue/"+created.Result.SysID, nil)
+	if err != nil {
+		return DiagnosticCheck{Status: CheckWarn, Detail: fmt.Sprintf("inserted %s but delete failed: %v", created.Result.SysID, err)}
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent && del.StatusCode != http.StatusOK {
+		return DiagnosticCheck{Status: CheckWarn, Detail: fmt.Sprintf("inserted %s but delete returned HTTP %d", created.Result.SysID, del.StatusCode),
+			Hint: "The probe record (topic LiteMIDgoDoctor) was left in ecc_queue; delete access is not needed for normal operation"}
+	}
+	return DiagnosticCheck{Status: CheckPass, Detail: fmt.Sprintf("inserted and deleted probe record %s", created.Result.SysID)}
+}
+
+func (c *Client) doProbe(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.GetInstanceURL()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(c.username, c.password)
+	return c.httpClient.Do(req)
+}
+
+// dialTarget returns the host:port the client connects to, which is the proxy
+// when one applies to the instance, and a note saying so
+func (c *Client) dialTarget() (string, string) {
+	instanceURL := &url.URL{Scheme: c.getProtocol(), Host: c.instance}
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok && transport.Proxy != nil {
+		if proxyURL, err := transport.Proxy(&http.Request{URL: instanceURL}); err == nil && proxyURL != nil {
+			return hostPort(proxyURL), " (via proxy " + proxyURL.Redacted() + ")"
+		}
+	}
+	return hostPort(instanceURL), ""
+}
+
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
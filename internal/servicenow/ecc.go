@@ -0,0 +1,130 @@
+package servicenow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ECCTimeFormat is the format of Table API date-time values, in UTC
+const ECCTimeFormat = "2006-01-02 15:04:05"
+
+// ECCFilter selects ecc_queue records. Empty fields are not filtered on.
+type ECCFilter struct {
+	Agent  string
+	Topic  string
+	Name   string
+	Source string
+	Queue  string
+	State  string
+	// Since and Until bound sys_created_on, or sys_updated_on with Updated
+	Since   time.Time
+	Until   time.Time
+	Updated bool
+	// Query is an extra encoded query ANDed with the other conditions
+	Query string
+}
+
+// Encode returns the filter as a sysparm_query with the given ORDERBY or
+// ORDERBYDESC clause appended. Field values are escaped, so a "^" in a value
+// is matched literally instead of starting another condition; Query is used
+// as is.
+func (f ECCFilter) Encode(order string) string {
+	var parts []string
+	add := func(field, value string) {
+		if value != "" {
+			parts = append(parts, field+"="+EscapeQueryValue(value))
+		}
+	}
+	add("agent", f.Agent)
+	add("topic", f.Topic)
+	add("name", f.Name)
+	add("source", f.Source)
+	add("queue", f.Queue)
+	add("state", f.State)
+
+	timeField := "sys_created_on"
+	if f.Updated {
+		timeField = "sys_updated_on"
+	}
+	if !f.Since.IsZero() {
+		parts = append(parts, timeField+">="+f.Since.UTC().Format(ECCTimeFormat))
+	}
+	if !f.Until.IsZero() {
+		parts = append(parts, timeField+"<"+f.Until.UTC().Format(ECCTimeFormat))
+	}
+	if f.Query != "" {
+		parts = append(parts, f.Query)
+	}
+	if order != "" {
+		parts = append(parts, order)
+	}
+	return strings.Join(parts, "^")
+}
+
+// EscapeQueryValue escapes a value for an encoded query, where "^" separates
+// conditions and "^^" stands for a literal "^"
+func EscapeQueryValue(value string) string {
+	return strings.ReplaceAll(value, "^", "^^")
+}
+
+// ListECCRecords returns one page of ecc_queue records matching an encoded query
+func (c *Client) ListECCRecords(ctx context.Context, query string, offset, limit int) ([]ECCRecord, error) {
+	params := url.Values{}
+	params.Set("sysparm_query", query)
+	params.Set("sysparm_offset", strconv.Itoa(offset))
+	params.Set("sysparm_limit", strconv.Itoa(limit))
+
+	var page struct {
+		Result []ECCRecord `json:"result"`
+	}
+	if err := c.getTable(ctx, "/api/now/table/ecc_queue?"+params.Encode(), &page); err != nil {
+		return nil, err
+	}
+	return page.Result, nil
+}
+
+// GetECCRecord returns the ecc_queue record with the given sys_id
+func (c *Client) GetECCRecord(ctx context.Context, sysID string) (*ECCRecord, error) {
+	var record ECCQueueResponse
+	if err := c.getTable(ctx, "/api/now/table/ecc_queue/"+url.PathEscape(sysID), &record); err != nil {
+		return nil, err
+	}
+	return &record.Result, nil
+}
+
+func (c *Client) getTable(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.GetInstanceURL()+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("record not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ServiceNow API error: %d - %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
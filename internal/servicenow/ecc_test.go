@@ -0,0 +1,39 @@
+package servicenow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestECCFilterEncodeEscapesValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ECCFilter
+		order  string
+		want   string
+	}{
+		{
+			name:   "plain values",
+			filter: ECCFilter{Agent: "litemidgo", Queue: "input"},
+			order:  "ORDERBYDESCsys_created_on",
+			want:   "agent=litemidgo^queue=input^ORDERBYDESCsys_created_on",
+		},
+		{
+			name:   "condition injected through a value",
+			filter: ECCFilter{Agent: "x^ORtopic!=y", Topic: "a^^b"},
+			want:   "agent=x^^ORtopic!=y^topic=a^^^^b",
+		},
+		{
+			name:   "raw query is not escaped",
+			filter: ECCFilter{Source: "web01", Since: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Query: "nameLIKEdisk^ORnameLIKEcpu"},
+			want:   "source=web01^sys_created_on>=2026-10-18 12:00:00^nameLIKEdisk^ORnameLIKEcpu",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Encode(tt.order); got != tt.want {
+				t.Errorf("Encode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
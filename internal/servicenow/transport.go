@@ -0,0 +1,173 @@
+package servicenow
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"litemidgo/config"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTransport builds the HTTP transport for instance traffic from the proxy
+// and TLS settings, and describes the resulting connection path
+func newTransport(cfg *config.ServiceNowConfig) (*http.Transport, ConnectionPath, error) {
+	var path ConnectionPath
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if v := cfg.TLS.MinVersion; v != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(v), "tls")]
+		if !ok {
+			return nil, path, fmt.Errorf("unsupported TLS min_version %q (use 1.0, 1.1, 1.2 or 1.3)", v)
+		}
+		tlsConfig.MinVersion = version
+	}
+	path.TLSMinVersion = tls.VersionName(tlsConfig.MinVersion)
+
+	if len(cfg.TLS.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range cfg.TLS.CAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, path, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, path, fmt.Errorf("no PEM certificates found in CA file %s", file)
+			}
+		}
+		tlsConfig.RootCAs = pool
+		path.CAFiles = cfg.TLS.CAFiles
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, path, fmt.Errorf("both tls.cert_file and tls.key_file are required for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, path, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		path.ClientCert = cfg.TLS.CertFile
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if cfg.Proxy.URL != "" {
+		proxyURL, err := url.Parse(cfg.Proxy.URL)
+		if err != nil {
+			return nil, path, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, path, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", proxyURL.Scheme)
+		}
+		if cfg.Proxy.Username != "" {
+			proxyURL.User = url.UserPassword(cfg.Proxy.Username, cfg.Proxy.Password)
+		}
+
+		noProxy := cfg.Proxy.NoProxy
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+		path.Proxy = proxyURL.Redacted()
+		if bypassProxy(hostOnly(cfg.Instance), noProxy) {
+			path.Proxy = "direct (instance matches no_proxy)"
+		}
+	} else if proxyURL, _ := http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: schemeFor(cfg.UseHTTPS), Host: cfg.Instance}}); proxyURL != nil {
+		path.Proxy = proxyURL.Redacted() + " (from environment)"
+	} else {
+		path.Proxy = "direct"
+	}
+
+	return transport, path, nil
+}
+
+// bypassProxy reports whether host matches a no_proxy entry: "*", an exact
+// host or IP, a domain suffix (".example.com" or "example.com" also matches
+// subdomains) or a CIDR range
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hostOnly(instance string) string {
+	if host, _, err := net.SplitHostPort(instance); err == nil {
+		return host
+	}
+	return instance
+}
+
+func schemeFor(useHTTPS bool) string {
+	if useHTTPS {
+		return "https"
+	}
+	return "http"
+}
+
+// ConnectionPath describes how the client reaches the instance, for
+// diagnostics such as `config test`
+type ConnectionPath struct {
+	Proxy         string
+	CAFiles       []string
+	ClientCert    string
+	TLSMinVersion string
+	// TLSVersion is the version negotiated by the last successful
+	// TestConnection, empty for plain HTTP
+	TLSVersion string
+}
+
+// String renders the path as a single human-readable line
+func (p ConnectionPath) String() string {
+	parts := []string{"proxy: " + p.Proxy}
+	if len(p.CAFiles) > 0 {
+		parts = append(parts, "CA: system + "+strings.Join(p.CAFiles, ", "))
+	} else {
+		parts = append(parts, "CA: system")
+	}
+	if p.ClientCert != "" {
+		parts = append(parts, "client cert: "+p.ClientCert)
+	}
+	parts = append(parts, "TLS min: "+p.TLSMinVersion)
+	if p.TLSVersion != "" {
+		parts = append(parts, "negotiated: "+p.TLSVersion)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,136 @@
+package snmp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MIB maps numeric OIDs to names. It is loaded from simple mapping files with
+// one "OID name" pair per line (blank lines and # comments are ignored), which
+// can be generated from real MIBs with e.g. `snmptranslate -Tz`.
+type MIB struct {
+	names map[string]string
+	oids  map[string]string
+}
+
+// builtinNames covers the standard notifications and varbinds most devices send
+var builtinNames = map[string]string{
+	"1.3.6.1.2.1.1.3.0":       "sysUpTime.0",
+	"1.3.6.1.2.1.1.5":         "sysName",
+	"1.3.6.1.2.1.2.2.1.1":     "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+	"1.3.6.1.2.1.2.2.1.7":     "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":     "ifOperStatus",
+	"1.3.6.1.6.3.1.1.4.1.0":   "snmpTrapOID.0",
+	"1.3.6.1.6.3.1.1.4.3.0":   "snmpTrapEnterprise.0",
+	"1.3.6.1.6.3.1.1.5.1":     "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":     "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":     "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":     "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":     "authenticationFailure",
+	"1.3.6.1.6.3.1.1.5.6":     "egpNeighborLoss",
+	"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+}
+
+// LoadMIB builds a MIB from the builtin names plus the given mapping files
+func LoadMIB(paths []string) (*MIB, error) {
+	m := &MIB{
+		names: make(map[string]string),
+		oids:  make(map[string]string),
+	}
+	for oid, name := range builtinNames {
+		m.add(oid, name)
+	}
+
+	for _, path := range paths {
+		if err := m.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *MIB) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open MIB mapping file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(line, "\"", ""))
+		if len(fields) < 2 {
+			return fmt.Errorf("%s:%d: expected \"OID name\"", path, lineNum)
+		}
+		oid, name := fields[0], fields[1]
+		// snmptranslate -Tz prints "name" "OID"
+		if !isNumericOID(oid) && isNumericOID(name) {
+			oid, name = name, oid
+		}
+		if !isNumericOID(oid) {
+			return fmt.Errorf("%s:%d: invalid OID %q", path, lineNum, oid)
+		}
+		m.add(oid, name)
+	}
+	return scanner.Err()
+}
+
+func (m *MIB) add(oid, name string) {
+	oid = strings.TrimPrefix(oid, ".")
+	m.names[oid] = name
+	m.oids[name] = oid
+}
+
+// Name resolves an OID using the longest known prefix, keeping any instance
+// suffix (1.3.6.1.2.1.2.2.1.2.3 becomes ifDescr.3). Unknown OIDs are returned
+// unchanged.
+func (m *MIB) Name(oid string) string {
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; prefix != ""; {
+		if name, ok := m.names[prefix]; ok {
+			return name + oid[len(prefix):]
+		}
+		dot := strings.LastIndexByte(prefix, '.')
+		if dot < 0 {
+			break
+		}
+		prefix = prefix[:dot]
+	}
+	return oid
+}
+
+// OID resolves a name from the mapping back to its numeric OID. Numeric input
+// is returned unchanged.
+func (m *MIB) OID(name string) string {
+	if isNumericOID(name) {
+		return strings.TrimPrefix(name, ".")
+	}
+	if oid, ok := m.oids[name]; ok {
+		return oid
+	}
+	return name
+}
+
+func isNumericOID(s string) bool {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && c != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,520 @@
+// Package snmp receives SNMP v1/v2c/v3 traps and converts them into ECC queue
+// records or Event Management events.
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+	"litemidgo/internal/servicenow"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// ForwardFunc delivers a record produced from a trap to the ECC queue
+type ForwardFunc func(record *servicenow.ECCQueuePayload) error
+
+// EventFunc delivers an event produced from a trap to Event Management
+type EventFunc func(event *servicenow.Event) error
+
+// Varbind is a decoded trap variable binding
+type Varbind struct {
+	OID   string      `json:"oid"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Trap is the decoded form of a received trap and the data passed to record
+// and event templates
+type Trap struct {
+	Source     string                 `json:"source"`
+	Version    string                 `json:"version"`
+	Community  string                 `json:"community,omitempty"`
+	User       string                 `json:"user,omitempty"`
+	TrapOID    string                 `json:"trap_oid"`
+	TrapName   string                 `json:"trap_name"`
+	Uptime     uint32                 `json:"uptime"`
+	Enterprise string                 `json:"enterprise,omitempty"`
+	Varbinds   []Varbind              `json:"varbinds"`
+	Values     map[string]interface{} `json:"values"`
+	ReceivedAt time.Time              `json:"received_at"`
+}
+
+const (
+	queueSize     = 1024
+	forwardWorker = 4
+)
+
+// Stats are the receiver's running counters since start. Dropped counts traps
+// discarded because the forward queue was full.
+type Stats struct {
+	Received      uint64 `json:"received"`
+	Rejected      uint64 `json:"rejected"`
+	Filtered      uint64 `json:"filtered"`
+	Forwarded     uint64 `json:"forwarded"`
+	ForwardErrors uint64 `json:"forward_errors"`
+	Dropped       uint64 `json:"dropped"`
+}
+
+type filter struct {
+	source  *net.IPNet
+	trapOID string
+	drop    bool
+}
+
+var defaultRecord = config.RecordConfig{
+	Agent:  "litemidgo-snmp",
+	Topic:  "snmpTrap",
+	Name:   "{{.TrapName}}",
+	Source: "{{.Source}}",
+}
+
+var defaultEvent = config.EventConfig{
+	Source:      "SNMP",
+	Node:        "{{.Source}}",
+	Type:        "{{.TrapName}}",
+	Severity:    "3",
+	MessageKey:  "{{.Source}}:{{.TrapOID}}",
+	Description: "SNMP trap {{.TrapName}} from {{.Source}}",
+}
+
+const (
+	oidSysUpTime   = "1.3.6.1.2.1.1.3.0"
+	oidSnmpTrapOID = "1.3.6.1.6.3.1.1.4.1.0"
+)
+
+// Receiver listens for SNMP traps and forwards them to ServiceNow
+type Receiver struct {
+	config      *config.SNMPConfig
+	mib         *MIB
+	communities map[string]bool
+	allowed     []*net.IPNet
+	filters     []filter
+	record      *mapping.Record
+	event       *mapping.Event
+	toECC       bool
+	toEvent     bool
+	forward     ForwardFunc
+	sendEvent   EventFunc
+	listener    *gosnmp.TrapListener
+	queue       chan *Trap
+	done        chan struct{}
+	wg          sync.WaitGroup
+	received    atomic.Uint64
+	rejected    atomic.Uint64
+	filtered    atomic.Uint64
+	forwarded   atomic.Uint64
+	forwardErrs atomic.Uint64
+	dropped     atomic.Uint64
+}
+
+func NewReceiver(cfg *config.SNMPConfig, forward ForwardFunc, sendEvent EventFunc) (*Receiver, error) {
+	mib, err := LoadMIB(cfg.MIBFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		config:      cfg,
+		mib:         mib,
+		communities: make(map[string]bool),
+		forward:     forward,
+		sendEvent:   sendEvent,
+		queue:       make(chan *Trap, queueSize),
+		done:        make(chan struct{}),
+	}
+
+	switch strings.ToLower(cfg.Target) {
+	case "", "ecc":
+		r.toECC = true
+	case "event":
+		r.toEvent = true
+	case "both":
+		r.toECC, r.toEvent = true, true
+	default:
+		return nil, fmt.Errorf("unknown snmp target %q (use ecc, event or both)", cfg.Target)
+	}
+
+	for _, community := range cfg.Communities {
+		r.communities[community] = true
+	}
+
+	for _, source := range cfg.AllowedSources {
+		network, err := parseSource(source)
+		if err != nil {
+			return nil, err
+		}
+		r.allowed = append(r.allowed, network)
+	}
+
+	for i, fc := range cfg.Filters {
+		f := filter{trapOID: mib.OID(fc.TrapOID)}
+		if fc.Source != "" {
+			if f.source, err = parseSource(fc.Source); err != nil {
+				return nil, err
+			}
+		}
+		switch strings.ToLower(fc.Action) {
+		case "", "forward":
+		case "drop":
+			f.drop = true
+		default:
+			return nil, fmt.Errorf("snmp filter %d: unknown action %q", i+1, fc.Action)
+		}
+		r.filters = append(r.filters, f)
+	}
+
+	if r.record, err = mapping.NewRecord(cfg.Record, defaultRecord); err != nil {
+		return nil, fmt.Errorf("snmp record mapping: %w", err)
+	}
+	if r.event, err = mapping.NewEvent(cfg.Event, defaultEvent); err != nil {
+		return nil, fmt.Errorf("snmp event mapping: %w", err)
+	}
+
+	return r, nil
+}
+
+func parseSource(source string) (*net.IPNet, error) {
+	if !strings.Contains(source, "/") {
+		ip := net.ParseIP(source)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid snmp source %q", source)
+		}
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snmp source %q: %w", source, err)
+	}
+	return network, nil
+}
+
+// Start binds the trap listener. It returns once the socket is listening or
+// binding has failed.
+func (r *Receiver) Start() error {
+	params := &gosnmp.GoSNMP{
+		Version:   gosnmp.Version2c,
+		Transport: "udp",
+		Logger:    gosnmp.NewLogger(log.New(io.Discard, "", 0)),
+	}
+
+	if len(r.config.Users) > 0 {
+		table := gosnmp.NewSnmpV3SecurityParametersTable(params.Logger)
+		for _, user := range r.config.Users {
+			usm, err := usmParameters(user)
+			if err != nil {
+				return err
+			}
+			if err := table.Add(user.Username, usm); err != nil {
+				return fmt.Errorf("invalid snmp user %s: %w", user.Username, err)
+			}
+		}
+		// v1/v2c traps are still decoded; the version is read from each packet
+		params.Version = gosnmp.Version3
+		params.TrapSecurityParametersTable = table
+		params.SecurityModel = gosnmp.UserSecurityModel
+		params.SecurityParameters = &gosnmp.UsmSecurityParameters{Logger: params.Logger}
+	}
+
+	r.listener = gosnmp.NewTrapListener()
+	r.listener.Params = params
+	r.listener.OnNewTrap = r.handleTrap
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.listener.Listen(r.config.Address)
+	}()
+
+	select {
+	case <-r.listener.Listening():
+		for i := 0; i < forwardWorker; i++ {
+			r.wg.Add(1)
+			go r.forwardLoop()
+		}
+		log.Printf("📥 SNMP trap receiver listening on udp %s", r.config.Address)
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("failed to listen for snmp traps on %s: %w", r.config.Address, err)
+	}
+}
+
+// Stop closes the listener and waits for in-flight forwards to finish
+func (r *Receiver) Stop() error {
+	close(r.done)
+	if r.listener != nil {
+		r.listener.Close()
+	}
+
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Receiver) Stats() Stats {
+	return Stats{
+		Received:      r.received.Load(),
+		Rejected:      r.rejected.Load(),
+		Filtered:      r.filtered.Load(),
+		Forwarded:     r.forwarded.Load(),
+		ForwardErrors: r.forwardErrs.Load(),
+		Dropped:       r.dropped.Load(),
+	}
+}
+
+func usmParameters(user config.SNMPUser) (*gosnmp.UsmSecurityParameters, error) {
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:                 user.Username,
+		AuthenticationProtocol:   gosnmp.NoAuth,
+		PrivacyProtocol:          gosnmp.NoPriv,
+		AuthenticationPassphrase: user.AuthPassphrase,
+		PrivacyPassphrase:        user.PrivPassphrase,
+	}
+
+	switch strings.ToUpper(user.AuthProtocol) {
+	case "", "NONE":
+	case "MD5":
+		usm.AuthenticationProtocol = gosnmp.MD5
+	case "SHA":
+		usm.AuthenticationProtocol = gosnmp.SHA
+	case "SHA224":
+		usm.AuthenticationProtocol = gosnmp.SHA224
+	case "SHA256":
+		usm.AuthenticationProtocol = gosnmp.SHA256
+	case "SHA384":
+		usm.AuthenticationProtocol = gosnmp.SHA384
+	case "SHA512":
+		usm.AuthenticationProtocol = gosnmp.SHA512
+	default:
+		return nil, fmt.Errorf("snmp user %s: unknown auth_protocol %q", user.Username, user.AuthProtocol)
+	}
+
+	switch strings.ToUpper(user.PrivProtocol) {
+	case "", "NONE":
+	case "DES":
+		usm.PrivacyProtocol = gosnmp.DES
+	case "AES":
+		usm.PrivacyProtocol = gosnmp.AES
+	case "AES192":
+		usm.PrivacyProtocol = gosnmp.AES192
+	case "AES256":
+		usm.PrivacyProtocol = gosnmp.AES256
+	case "AES192C":
+		usm.PrivacyProtocol = gosnmp.AES192C
+	case "AES256C":
+		usm.PrivacyProtocol = gosnmp.AES256C
+	default:
+		return nil, fmt.Errorf("snmp user %s: unknown priv_protocol %q", user.Username, user.PrivProtocol)
+	}
+
+	return usm, nil
+}
+
+func (r *Receiver) handleTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	r.received.Add(1)
+
+	if !r.sourceAllowed(addr.IP) {
+		r.rejected.Add(1)
+		return
+	}
+	if packet.Version != gosnmp.Version3 && len(r.communities) > 0 && !r.communities[packet.Community] {
+		r.rejected.Add(1)
+		return
+	}
+
+	trap := r.decode(packet, addr)
+	if r.isFiltered(addr.IP, trap.TrapOID) {
+		r.filtered.Add(1)
+		return
+	}
+
+	// The listener handles one datagram at a time; forward without blocking
+	// it and drop traps the workers cannot keep up with
+	select {
+	case r.queue <- trap:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func (r *Receiver) sourceAllowed(ip net.IP) bool {
+	if len(r.allowed) == 0 {
+		return true
+	}
+	for _, network := range r.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFiltered applies the first filter matching the sender and trap OID
+func (r *Receiver) isFiltered(ip net.IP, trapOID string) bool {
+	for _, f := range r.filters {
+		if f.source != nil && !f.source.Contains(ip) {
+			continue
+		}
+		if f.trapOID != "" && trapOID != f.trapOID && !strings.HasPrefix(trapOID, f.trapOID+".") {
+			continue
+		}
+		return f.drop
+	}
+	return false
+}
+
+func (r *Receiver) decode(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) *Trap {
+	trap := &Trap{
+		Source:     addr.IP.String(),
+		Version:    packet.Version.String(),
+		Values:     make(map[string]interface{}),
+		ReceivedAt: time.Now().UTC(),
+	}
+	if packet.Version == gosnmp.Version3 {
+		if usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+			trap.User = usm.UserName
+		}
+	} else {
+		trap.Community = packet.Community
+	}
+
+	if packet.Version == gosnmp.Version1 {
+		trap.Enterprise = strings.TrimPrefix(packet.Enterprise, ".")
+		trap.Uptime = uint32(packet.Timestamp)
+		// RFC 3584 section 3.1: v1 generic/specific traps as notification OIDs
+		if packet.GenericTrap < 6 {
+			trap.TrapOID = fmt.Sprintf("1.3.6.1.6.3.1.1.5.%d", packet.GenericTrap+1)
+		} else {
+			trap.TrapOID = fmt.Sprintf("%s.0.%d", trap.Enterprise, packet.SpecificTrap)
+		}
+	}
+
+	for _, pdu := range packet.Variables {
+		oid := strings.TrimPrefix(pdu.Name, ".")
+		value := convertValue(pdu)
+
+		switch oid {
+		case oidSysUpTime:
+			if ticks, ok := value.(uint32); ok {
+				trap.Uptime = ticks
+			}
+		case oidSnmpTrapOID:
+			if trapOID, ok := value.(string); ok {
+				trap.TrapOID = strings.TrimPrefix(trapOID, ".")
+			}
+			continue
+		}
+
+		name := r.mib.Name(oid)
+		if pdu.Type == gosnmp.ObjectIdentifier {
+			if s, ok := value.(string); ok {
+				value = r.mib.Name(s)
+			}
+		}
+		trap.Varbinds = append(trap.Varbinds, Varbind{
+			OID:   oid,
+			Name:  name,
+			Type:  pdu.Type.String(),
+			Value: value,
+		})
+		trap.Values[name] = value
+	}
+
+	trap.TrapName = r.mib.Name(trap.TrapOID)
+	return trap
+}
+
+func convertValue(pdu gosnmp.SnmpPDU) interface{} {
+	switch value := pdu.Value.(type) {
+	case []byte:
+		if utf8.Valid(value) && isPrintable(value) {
+			return string(value)
+		}
+		return hex.EncodeToString(value)
+	case string:
+		return strings.TrimPrefix(value, ".")
+	default:
+		return value
+	}
+}
+
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Receiver) forwardLoop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case trap := <-r.queue:
+			r.dispatch(trap)
+		}
+	}
+}
+
+func (r *Receiver) dispatch(trap *Trap) {
+	ok := true
+
+	if r.toECC {
+		if err := r.forwardRecord(trap); err != nil {
+			ok = false
+			log.Printf("Failed to forward SNMP trap %s from %s: %v", trap.TrapName, trap.Source, err)
+		}
+	}
+	if r.toEvent {
+		if err := r.forwardEvent(trap); err != nil {
+			ok = false
+			log.Printf("Failed to send SNMP trap %s from %s as event: %v", trap.TrapName, trap.Source, err)
+		}
+	}
+
+	if ok {
+		r.forwarded.Add(1)
+	} else {
+		r.forwardErrs.Add(1)
+	}
+}
+
+func (r *Receiver) forwardRecord(trap *Trap) error {
+	record, err := r.record.Render(trap)
+	if err != nil {
+		return err
+	}
+	if record.Payload == nil {
+		record.Payload = trap
+	}
+	return r.forward(record)
+}
+
+func (r *Receiver) forwardEvent(trap *Trap) error {
+	event, err := r.event.Render(trap)
+	if err != nil {
+		return err
+	}
+	if event.AdditionalInfo == nil {
+		event.AdditionalInfo = trap.Values
+	}
+	event.TimeOfEvent = trap.ReceivedAt.Format("2006-01-02 15:04:05")
+	return r.sendEvent(event)
+}
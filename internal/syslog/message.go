@@ -0,0 +1,316 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a parsed RFC 3164 or RFC 5424 syslog message.
+type Message struct {
+	Format         string                       `json:"format"`
+	Facility       int                          `json:"facility"`
+	Severity       int                          `json:"severity"`
+	Timestamp      time.Time                    `json:"timestamp"`
+	Hostname       string                       `json:"hostname"`
+	AppName        string                       `json:"app_name,omitempty"`
+	ProcID         string                       `json:"proc_id,omitempty"`
+	MsgID          string                       `json:"msg_id,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+	Message        string                       `json:"message"`
+	RemoteAddr     string                       `json:"remote_addr"`
+}
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var severityAliases = map[string]int{
+	"emergency": 0, "panic": 0, "critical": 2, "error": 3, "warn": 4, "informational": 6,
+}
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// SeverityName returns the keyword for the message severity (e.g. "err")
+func (m *Message) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(severityNames) {
+		return severityNames[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+// FacilityName returns the keyword for the message facility (e.g. "local7")
+func (m *Message) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(facilityNames) {
+		return facilityNames[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+// ParseSeverity converts a severity keyword or number to its numeric code
+func ParseSeverity(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range severityNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	if code, ok := severityAliases[s]; ok {
+		return code, nil
+	}
+	if code, err := strconv.Atoi(s); err == nil && code >= 0 && code < len(severityNames) {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown syslog severity %q", s)
+}
+
+// ParseFacility converts a facility keyword or number to its numeric code
+func ParseFacility(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range facilityNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	if code, err := strconv.Atoi(s); err == nil && code >= 0 && code < len(facilityNames) {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown syslog facility %q", s)
+}
+
+// Parse decodes a single syslog message. RFC 5424 is detected by the version
+// number following the priority; anything else is parsed as RFC 3164, which is
+// lenient enough to accept most device output.
+func Parse(raw []byte, remoteAddr string) (*Message, error) {
+	raw = bytes.TrimRight(raw, "\r\n\x00")
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
+
+	msg := &Message{RemoteAddr: remoteAddr}
+
+	// RFC 3164 section 4.3.3: a message without PRI is treated as user.notice
+	pri := 13
+	rest := string(raw)
+	if rest[0] == '<' {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, fmt.Errorf("invalid PRI header")
+		}
+		value, err := strconv.Atoi(rest[1:end])
+		if err != nil || value > 191 {
+			return nil, fmt.Errorf("invalid PRI value %q", rest[1:end])
+		}
+		pri = value
+		rest = rest[end+1:]
+	}
+	msg.Facility = pri / 8
+	msg.Severity = pri % 8
+
+	if strings.HasPrefix(rest, "1 ") {
+		msg.Format = "rfc5424"
+		if err := parseRFC5424(msg, rest[2:]); err != nil {
+			return nil, err
+		}
+	} else {
+		msg.Format = "rfc3164"
+		parseRFC3164(msg, rest)
+	}
+
+	if msg.Hostname == "" {
+		msg.Hostname = hostFromAddr(remoteAddr)
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now().UTC()
+	}
+
+	return msg, nil
+}
+
+func parseRFC5424(msg *Message, rest string) error {
+	fields := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		sp := strings.IndexByte(rest, ' ')
+		if sp < 0 {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		fields = append(fields, rest[:sp])
+		rest = rest[sp+1:]
+	}
+
+	if fields[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid RFC 5424 timestamp %q", fields[0])
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	msg.ProcID = nilValue(fields[3])
+	msg.MsgID = nilValue(fields[4])
+
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "[") {
+		sd, remaining, err := parseStructuredData(rest)
+		if err != nil {
+			return err
+		}
+		msg.StructuredData = sd
+		rest = remaining
+	} else {
+		return fmt.Errorf("invalid RFC 5424 structured data")
+	}
+
+	rest = strings.TrimPrefix(rest, " ")
+	msg.Message = strings.TrimPrefix(rest, "\ufeff")
+	return nil
+}
+
+// parseStructuredData reads consecutive SD-ELEMENTs ([id key="value" ...]) and
+// returns the remaining message text.
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	sd := make(map[string]map[string]string)
+	for strings.HasPrefix(s, "[") {
+		end := -1
+		inQuote := false
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && inQuote:
+				i++
+			case s[i] == '"':
+				inQuote = !inQuote
+			case s[i] == ']' && !inQuote:
+				end = i
+			}
+			if end >= 0 {
+				break
+			}
+		}
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated structured data element")
+		}
+
+		id, params := parseSDElement(s[1:end])
+		sd[id] = params
+		s = s[end+1:]
+	}
+	return sd, s, nil
+}
+
+func parseSDElement(element string) (string, map[string]string) {
+	params := make(map[string]string)
+	sp := strings.IndexByte(element, ' ')
+	if sp < 0 {
+		return element, params
+	}
+	id, rest := element[:sp], element[sp+1:]
+
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		eq := strings.Index(rest, `="`)
+		if eq < 0 {
+			break
+		}
+		name := rest[:eq]
+		rest = rest[eq+2:]
+
+		var value strings.Builder
+		i := 0
+		for ; i < len(rest); i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				value.WriteByte(rest[i])
+				continue
+			}
+			if rest[i] == '"' {
+				break
+			}
+			value.WriteByte(rest[i])
+		}
+		params[name] = value.String()
+		if i >= len(rest) {
+			break
+		}
+		rest = rest[i+1:]
+	}
+	return id, params
+}
+
+// rfc3164Layouts are the timestamp forms commonly seen from BSD-style senders,
+// longest first so fractional seconds are not left in the message
+var rfc3164Layouts = []string{time.StampMicro, time.StampMilli, time.Stamp}
+
+func parseRFC3164(msg *Message, rest string) {
+	// Some senders use an ISO 8601 timestamp in an otherwise BSD-style message
+	if sp := strings.IndexByte(rest, ' '); sp > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, rest[:sp]); err == nil {
+			msg.Timestamp = ts
+			rest = rest[sp+1:]
+		}
+	}
+
+	for _, layout := range rfc3164Layouts {
+		if !msg.Timestamp.IsZero() || len(rest) < len(layout) {
+			break
+		}
+		ts, err := time.ParseInLocation(layout, rest[:len(layout)], time.Local)
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		ts = ts.AddDate(now.Year(), 0, 0)
+		// Messages from late December received in early January
+		if ts.After(now.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+		msg.Timestamp = ts.UTC()
+		rest = strings.TrimPrefix(rest[len(layout):], " ")
+	}
+
+	// Without a valid header the whole remainder is message content. HOSTNAME is
+	// optional in practice; a token ending in ':' or containing '[' is the TAG of
+	// a sender that omitted it
+	if msg.Timestamp.IsZero() {
+		msg.Message = rest
+		return
+	}
+	if sp := strings.IndexByte(rest, ' '); sp > 0 {
+		token := rest[:sp]
+		if !strings.HasSuffix(token, ":") && !strings.Contains(token, "[") {
+			msg.Hostname = token
+			rest = rest[sp+1:]
+		}
+	}
+
+	if colon := strings.Index(rest, ": "); colon > 0 && !strings.Contains(rest[:colon], " ") {
+		tag := rest[:colon]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			msg.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		msg.AppName = tag
+		rest = rest[colon+2:]
+	}
+
+	msg.Message = rest
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func hostFromAddr(addr string) string {
+	if i := strings.LastIndexByte(addr, ':'); i > 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
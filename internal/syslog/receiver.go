@@ -0,0 +1,407 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+	"litemidgo/internal/servicenow"
+)
+
+// ForwardFunc delivers a record produced from a syslog message to ServiceNow
+type ForwardFunc func(record *servicenow.ECCQueuePayload) error
+
+const (
+	queueSize     = 1024
+	forwardWorker = 4
+	// maxConns caps concurrent TCP connections; more are closed on accept
+	maxConns = 256
+	// idleTimeout closes a TCP connection that sends no frame for this long
+	idleTimeout = 5 * time.Minute
+)
+
+// Stats are the receiver's running counters since start
+type Stats struct {
+	Received      uint64 `json:"received"`
+	ParseErrors   uint64 `json:"parse_errors"`
+	Forwarded     uint64 `json:"forwarded"`
+	ForwardErrors uint64 `json:"forward_errors"`
+	Dropped       uint64 `json:"dropped"`
+	Aggregated    uint64 `json:"aggregated"`
+	QueueOverflow uint64 `json:"queue_overflow"`
+	DroppedConns  uint64 `json:"dropped_connections"`
+}
+
+type aggregate struct {
+	rule    *rule
+	last    *Message
+	count   int
+	expires time.Time
+}
+
+type outbound struct {
+	rule        *rule
+	msg         *Message
+	repeatCount int
+}
+
+// Receiver listens for syslog messages over UDP and/or TCP and turns matching
+// messages into ECC queue records.
+type Receiver struct {
+	config       *config.SyslogConfig
+	rules        []*rule
+	defaultRule  *rule
+	forward      ForwardFunc
+	queue        chan outbound
+	udpConn      net.PacketConn
+	tcpListener  net.Listener
+	done         chan struct{}
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	conns        map[net.Conn]struct{}
+	connSlots    chan struct{}
+	aggregates   map[string]*aggregate
+	received     atomic.Uint64
+	parseErrors  atomic.Uint64
+	forwarded    atomic.Uint64
+	forwardErrs  atomic.Uint64
+	dropped      atomic.Uint64
+	aggregated   atomic.Uint64
+	queueOverrun atomic.Uint64
+	droppedConns atomic.Uint64
+}
+
+func NewReceiver(cfg *config.SyslogConfig, forward ForwardFunc) (*Receiver, error) {
+	rules, err := compileRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		config:     cfg,
+		rules:      rules,
+		forward:    forward,
+		queue:      make(chan outbound, queueSize),
+		done:       make(chan struct{}),
+		conns:      make(map[net.Conn]struct{}),
+		connSlots:  make(chan struct{}, maxConns),
+		aggregates: make(map[string]*aggregate),
+	}
+
+	switch strings.ToLower(cfg.DefaultAction) {
+	case "", "drop":
+	case "forward":
+		record, err := mapping.NewRecord(config.RecordConfig{}, defaultRecord)
+		if err != nil {
+			return nil, err
+		}
+		r.defaultRule = &rule{name: "default", record: record}
+	default:
+		return nil, fmt.Errorf("unknown syslog default_action %q", cfg.DefaultAction)
+	}
+
+	return r, nil
+}
+
+// Start binds the configured listeners and begins processing messages
+func (r *Receiver) Start() error {
+	if r.config.UDPAddress == "" && r.config.TCPAddress == "" {
+		return fmt.Errorf("syslog receiver enabled but no udp_address or tcp_address configured")
+	}
+
+	if r.config.UDPAddress != "" {
+		conn, err := net.ListenPacket("udp", r.config.UDPAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen for syslog on udp %s: %w", r.config.UDPAddress, err)
+		}
+		r.udpConn = conn
+		r.wg.Add(1)
+		go r.serveUDP()
+		log.Printf("📥 Syslog receiver listening on udp %s", conn.LocalAddr())
+	}
+
+	if r.config.TCPAddress != "" {
+		listener, err := net.Listen("tcp", r.config.TCPAddress)
+		if err != nil {
+			if r.udpConn != nil {
+				r.udpConn.Close()
+			}
+			return fmt.Errorf("failed to listen for syslog on tcp %s: %w", r.config.TCPAddress, err)
+		}
+		r.tcpListener = listener
+		r.wg.Add(1)
+		go r.serveTCP()
+		log.Printf("📥 Syslog receiver listening on tcp %s", listener.Addr())
+	}
+
+	for i := 0; i < forwardWorker; i++ {
+		r.wg.Add(1)
+		go r.forwardLoop()
+	}
+	r.wg.Add(1)
+	go r.flushLoop()
+
+	return nil
+}
+
+// Stop closes the listeners and waits for in-flight forwards to finish
+func (r *Receiver) Stop() error {
+	close(r.done)
+	if r.udpConn != nil {
+		r.udpConn.Close()
+	}
+	if r.tcpListener != nil {
+		r.tcpListener.Close()
+	}
+	r.mu.Lock()
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Receiver) Stats() Stats {
+	return Stats{
+		Received:      r.received.Load(),
+		ParseErrors:   r.parseErrors.Load(),
+		Forwarded:     r.forwarded.Load(),
+		ForwardErrors: r.forwardErrs.Load(),
+		Dropped:       r.dropped.Load(),
+		Aggregated:    r.aggregated.Load(),
+		QueueOverflow: r.queueOverrun.Load(),
+		DroppedConns:  r.droppedConns.Load(),
+	}
+}
+
+func (r *Receiver) maxMessageSize() int {
+	if r.config.MaxMessageSize > 0 {
+		return r.config.MaxMessageSize
+	}
+	return 65536
+}
+
+func (r *Receiver) serveUDP() {
+	defer r.wg.Done()
+
+	buf := make([]byte, r.maxMessageSize())
+	for {
+		n, addr, err := r.udpConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog UDP read error: %v", err)
+			continue
+		}
+		r.handleRaw(buf[:n], addr.String())
+	}
+}
+
+func (r *Receiver) serveTCP() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.tcpListener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog TCP accept error: %v", err)
+			continue
+		}
+
+		select {
+		case r.connSlots <- struct{}{}:
+		default:
+			r.droppedConns.Add(1)
+			conn.Close()
+			continue
+		}
+
+		r.mu.Lock()
+		r.conns[conn] = struct{}{}
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go r.serveConn(conn)
+	}
+}
+
+// serveConn reads RFC 6587 frames: octet-counted ("LEN SP MSG") when the frame
+// starts with a digit, newline-delimited otherwise. A connection that sends
+// no frame within idleTimeout is closed.
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+		<-r.connSlots
+	}()
+
+	maxSize := r.maxMessageSize()
+	reader := bufio.NewReaderSize(conn, maxSize+16)
+	remote := conn.RemoteAddr().String()
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			return
+		}
+		first, err := reader.Peek(1)
+		if err != nil {
+			return
+		}
+
+		if first[0] >= '1' && first[0] <= '9' {
+			prefix, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			length, err := strconv.Atoi(strings.TrimSpace(prefix))
+			if err != nil || length > maxSize {
+				r.parseErrors.Add(1)
+				log.Printf("Syslog TCP framing error from %s, closing connection", remote)
+				return
+			}
+			frame := make([]byte, length)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return
+			}
+			r.handleRaw(frame, remote)
+			continue
+		}
+
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			r.parseErrors.Add(1)
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = reader.ReadSlice('\n')
+			}
+			continue
+		}
+		if len(line) > 0 {
+			r.handleRaw(line, remote)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *Receiver) handleRaw(raw []byte, remote string) {
+	r.received.Add(1)
+
+	msg, err := Parse(raw, remote)
+	if err != nil {
+		r.parseErrors.Add(1)
+		return
+	}
+	r.handle(msg)
+}
+
+func (r *Receiver) handle(msg *Message) {
+	matched := r.defaultRule
+	for _, candidate := range r.rules {
+		if candidate.matches(msg) {
+			matched = candidate
+			break
+		}
+	}
+	if matched == nil || matched.drop {
+		r.dropped.Add(1)
+		return
+	}
+
+	if matched.window > 0 {
+		key := strings.Join([]string{matched.name, msg.Hostname, msg.AppName, msg.Message}, "\x00")
+		now := time.Now()
+
+		r.mu.Lock()
+		if agg, ok := r.aggregates[key]; ok && now.Before(agg.expires) {
+			agg.count++
+			agg.last = msg
+			r.mu.Unlock()
+			r.aggregated.Add(1)
+			return
+		}
+		r.aggregates[key] = &aggregate{rule: matched, expires: now.Add(matched.window)}
+		r.mu.Unlock()
+	}
+
+	r.enqueue(outbound{rule: matched, msg: msg})
+}
+
+func (r *Receiver) enqueue(out outbound) {
+	select {
+	case r.queue <- out:
+	default:
+		r.queueOverrun.Add(1)
+		r.dropped.Add(1)
+	}
+}
+
+// flushLoop emits a summary record for every aggregation window that folded
+// duplicate messages, once the window has closed.
+func (r *Receiver) flushLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			for key, agg := range r.aggregates {
+				if now.Before(agg.expires) {
+					continue
+				}
+				delete(r.aggregates, key)
+				if agg.count > 0 {
+					r.enqueue(outbound{rule: agg.rule, msg: agg.last, repeatCount: agg.count})
+				}
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *Receiver) forwardLoop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case out := <-r.queue:
+			record, err := out.rule.render(out.msg, out.repeatCount)
+			if err != nil {
+				r.forwardErrs.Add(1)
+				log.Printf("Syslog rule %s failed to render record: %v", out.rule.name, err)
+				continue
+			}
+			if err := r.forward(record); err != nil {
+				r.forwardErrs.Add(1)
+				log.Printf("Failed to forward syslog record from %s: %v", out.msg.Hostname, err)
+				continue
+			}
+			r.forwarded.Add(1)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package syslog
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+)
+
+func TestTCPConnectionsAreCapped(t *testing.T) {
+	r, err := NewReceiver(&config.SyslogConfig{TCPAddress: "127.0.0.1:0"}, func(*servicenow.ECCQueuePayload) error { return nil })
+	if err != nil {
+		t.Fatalf("NewReceiver: %v", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer r.Stop()
+
+	addr := r.tcpListener.Addr().String()
+	for i := 0; i < maxConns; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		defer conn.Close()
+	}
+
+	extra, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer extra.Close()
+	extra.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := extra.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("read over the cap: got %v, want the connection closed", err)
+	}
+	if dropped := r.Stats().DroppedConns; dropped != 1 {
+		t.Errorf("dropped connections = %d, want 1", dropped)
+	}
+}
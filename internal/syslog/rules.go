@@ -0,0 +1,153 @@
+package syslog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"litemidgo/config"
+	"litemidgo/internal/mapping"
+	"litemidgo/internal/servicenow"
+)
+
+type rule struct {
+	name       string
+	severities map[int]bool
+	facilities map[int]bool
+	host       *regexp.Regexp
+	appName    *regexp.Regexp
+	message    *regexp.Regexp
+	drop       bool
+	window     time.Duration
+	record     *mapping.Record
+}
+
+// defaultRecord is used for any field a rule does not map explicitly
+var defaultRecord = config.RecordConfig{
+	Agent:  "litemidgo-syslog",
+	Topic:  "syslog",
+	Name:   "{{.Hostname}}",
+	Source: "{{.Hostname}}",
+}
+
+func compileRules(cfg *config.SyslogConfig) ([]*rule, error) {
+	rules := make([]*rule, 0, len(cfg.Rules))
+	for i, rc := range cfg.Rules {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+
+		r := &rule{
+			name:   name,
+			window: time.Duration(rc.AggregateWindow) * time.Second,
+		}
+
+		switch strings.ToLower(rc.Action) {
+		case "", "forward":
+		case "drop":
+			r.drop = true
+		default:
+			return nil, fmt.Errorf("syslog rule %s: unknown action %q", name, rc.Action)
+		}
+
+		if len(rc.Match.Severities) > 0 {
+			r.severities = make(map[int]bool)
+			for _, s := range rc.Match.Severities {
+				code, err := ParseSeverity(s)
+				if err != nil {
+					return nil, fmt.Errorf("syslog rule %s: %w", name, err)
+				}
+				r.severities[code] = true
+			}
+		}
+		if len(rc.Match.Facilities) > 0 {
+			r.facilities = make(map[int]bool)
+			for _, f := range rc.Match.Facilities {
+				code, err := ParseFacility(f)
+				if err != nil {
+					return nil, fmt.Errorf("syslog rule %s: %w", name, err)
+				}
+				r.facilities[code] = true
+			}
+		}
+
+		var err error
+		if r.host, err = compilePattern(rc.Match.Host); err != nil {
+			return nil, fmt.Errorf("syslog rule %s: invalid host pattern: %w", name, err)
+		}
+		if r.appName, err = compilePattern(rc.Match.AppName); err != nil {
+			return nil, fmt.Errorf("syslog rule %s: invalid app_name pattern: %w", name, err)
+		}
+		if r.message, err = compilePattern(rc.Match.Message); err != nil {
+			return nil, fmt.Errorf("syslog rule %s: invalid message pattern: %w", name, err)
+		}
+
+		if r.record, err = mapping.NewRecord(rc.Record, defaultRecord); err != nil {
+			return nil, fmt.Errorf("syslog rule %s: %w", name, err)
+		}
+
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func (r *rule) matches(msg *Message) bool {
+	if r.severities != nil && !r.severities[msg.Severity] {
+		return false
+	}
+	if r.facilities != nil && !r.facilities[msg.Facility] {
+		return false
+	}
+	if r.host != nil && !r.host.MatchString(msg.Hostname) {
+		return false
+	}
+	if r.appName != nil && !r.appName.MatchString(msg.AppName) {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(msg.Message) {
+		return false
+	}
+	return true
+}
+
+// render builds the ECC record for a message. repeatCount is the number of
+// identical messages that aggregation folded into this record.
+func (r *rule) render(msg *Message, repeatCount int) (*servicenow.ECCQueuePayload, error) {
+	record, err := r.record.Render(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, ok := record.Payload.(map[string]interface{})
+	if !ok {
+		payload = map[string]interface{}{
+			"format":          msg.Format,
+			"facility":        msg.FacilityName(),
+			"severity":        msg.SeverityName(),
+			"timestamp":       msg.Timestamp.UTC().Format(time.RFC3339),
+			"hostname":        msg.Hostname,
+			"app_name":        msg.AppName,
+			"proc_id":         msg.ProcID,
+			"msg_id":          msg.MsgID,
+			"structured_data": msg.StructuredData,
+			"message":         msg.Message,
+			"remote_addr":     msg.RemoteAddr,
+		}
+	}
+	payload["rule"] = r.name
+	if repeatCount > 0 {
+		payload["repeat_count"] = repeatCount
+	}
+	record.Payload = payload
+
+	return record, nil
+}
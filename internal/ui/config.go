@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -9,6 +10,7 @@ import (
 )
 
 type ConfigModel struct {
+	profile   string
 	index     int
 	focus     int
 	questions []Question
@@ -29,7 +31,9 @@ type Question struct {
 
 type ConfigCompleteMsg map[string]string
 
-func NewConfigModel() ConfigModel {
+// NewConfigModel creates the setup form for a profile ("" for the base
+// settings), prefilled with the current values where they are set
+func NewConfigModel(profile string, current map[string]string) ConfigModel {
 	questions := []Question{
 		{
 			Key:         "instance",
@@ -89,6 +93,9 @@ func NewConfigModel() ConfigModel {
 				if strings.TrimSpace(s) == "" {
 					return fmt.Errorf("port cannot be empty")
 				}
+				if _, err := strconv.Atoi(strings.TrimSpace(s)); err != nil {
+					return fmt.Errorf("port must be a number")
+				}
 				return nil
 			},
 		},
@@ -114,6 +121,9 @@ func NewConfigModel() ConfigModel {
 				if strings.TrimSpace(s) == "" {
 					return fmt.Errorf("timeout cannot be empty")
 				}
+				if _, err := strconv.Atoi(strings.TrimSpace(s)); err != nil {
+					return fmt.Errorf("timeout must be a number")
+				}
 				return nil
 			},
 		},
@@ -122,9 +132,13 @@ func NewConfigModel() ConfigModel {
 	answers := make(map[string]string)
 	for _, q := range questions {
 		answers[q.Key] = q.Default
+		if value := current[q.Key]; value != "" && value != "0" {
+			answers[q.Key] = value
+		}
 	}
 
 	return ConfigModel{
+		profile:   profile,
 		questions: questions,
 		Answers:   answers,
 		index:     0,
@@ -142,6 +156,7 @@ func (m ConfigModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			m.quitting = true
+			m.Answers = map[string]string{}
 			return m, tea.Quit
 
 		case tea.KeyEnter, tea.KeyTab:
@@ -203,7 +218,11 @@ func (m ConfigModel) View() string {
 	var content strings.Builder
 
 	// Title
-	content.WriteString(titleStyle.Render("🔧 LiteMIDgo Configuration Setup"))
+	title := "🔧 LiteMIDgo Configuration Setup"
+	if m.profile != "" {
+		title += " - profile " + m.profile
+	}
+	content.WriteString(titleStyle.Render(title))
 	content.WriteString("\n\n")
 
 	// Progress indicator
@@ -14,6 +14,8 @@ import (
 type ConnectionTestModel struct {
 	status    TestStatus
 	instance  string
+	cfg       *config.ServiceNowConfig
+	path      string
 	error     error
 	spinner   int
 	width     int
@@ -30,16 +32,18 @@ const (
 	StatusFailed
 )
 
-func NewConnectionTestModel(instance string) ConnectionTestModel {
+func NewConnectionTestModel(cfg *config.ServiceNowConfig) ConnectionTestModel {
 	return ConnectionTestModel{
 		status:   StatusIdle,
-		instance: instance,
+		instance: cfg.Instance,
+		cfg:      cfg,
 		spinner:  0,
 	}
 }
 
 type TestCompleteMsg struct {
 	success bool
+	path    string
 	error   error
 }
 
@@ -72,11 +76,12 @@ func (m ConnectionTestModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
 					return TickMsg(t)
 				}),
-				performConnectionTest(m.instance),
+				performConnectionTest(m.cfg),
 			)
 		}
 
 	case TestCompleteMsg:
+		m.path = msg.path
 		if msg.success {
 			m.status = StatusSuccess
 		} else {
@@ -93,20 +98,18 @@ func (m ConnectionTestModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func performConnectionTest(instance string) tea.Cmd {
+func performConnectionTest(cfg *config.ServiceNowConfig) tea.Cmd {
 	return func() tea.Msg {
-		// Load configuration
-		cfg, err := config.LoadConfig("")
+		// Create ServiceNow client and test connection
+		snowClient, err := servicenow.NewClient(cfg)
 		if err != nil {
 			return TestCompleteMsg{success: false, error: err}
 		}
-
-		// Create ServiceNow client and test connection
-		snowClient := servicenow.NewClient(&cfg.ServiceNow)
 		err = snowClient.TestConnection()
-		
+
 		return TestCompleteMsg{
 			success: err == nil,
+			path:    snowClient.ConnectionPath().String(),
 			error:   err,
 		}
 	}
@@ -147,6 +150,8 @@ func (m ConnectionTestModel) View() string {
 		content.WriteString(normalStyle.Render(fmt.Sprintf("Successfully connected to %s", m.instance)))
 		content.WriteString("\n\n")
 		content.WriteString(successStyle.Render("Your ServiceNow instance is accessible and credentials are valid."))
+		content.WriteString("\n\n")
+		content.WriteString(normalStyle.Render(fmt.Sprintf("Connection path: %s", m.path)))
 
 	case StatusFailed:
 		content.WriteString(errorStyle.Render("❌ Connection Failed"))
@@ -155,6 +160,10 @@ func (m ConnectionTestModel) View() string {
 			content.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v", m.error)))
 			content.WriteString("\n\n")
 		}
+		if m.path != "" {
+			content.WriteString(normalStyle.Render(fmt.Sprintf("Connection path: %s", m.path)))
+			content.WriteString("\n\n")
+		}
 		content.WriteString(normalStyle.Render("Please check:"))
 		content.WriteString("\n")
 		content.WriteString(normalStyle.Render("• Instance URL is correct"))
@@ -162,6 +171,8 @@ func (m ConnectionTestModel) View() string {
 		content.WriteString(normalStyle.Render("• Username and password are valid"))
 		content.WriteString("\n")
 		content.WriteString(normalStyle.Render("• Network connectivity to ServiceNow"))
+		content.WriteString("\n")
+		content.WriteString(normalStyle.Render("• Proxy and TLS settings (proxy, tls.ca_files, tls.cert_file)"))
 	}
 
 	// Help text
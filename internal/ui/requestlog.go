@@ -0,0 +1,255 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"litemidgo/internal/server"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// requestLogLimit is the number of records the dashboard keeps
+	requestLogLimit = 500
+	// requestLogRows is the height of the request log pane
+	requestLogRows = 10
+	// detailMaxLines caps the payload and response shown in the detail view
+	detailMaxLines = 30
+)
+
+// fetchRequests appends the records logged by the server since the last fetch
+func (m *ServerDashboardModel) fetchRequests() {
+	entries := m.server.RecentRequests(m.logSeq)
+	if len(entries) == 0 {
+		return
+	}
+	m.logSeq = entries[len(entries)-1].Seq
+	m.requests = append(m.requests, entries...)
+	if len(m.requests) > requestLogLimit {
+		m.requests = append([]server.RequestLogEntry(nil), m.requests[len(m.requests)-requestLogLimit:]...)
+	}
+}
+
+// visibleRequests returns the records matching the status and topic filters,
+// oldest first
+func (m *ServerDashboardModel) visibleRequests() []server.RequestLogEntry {
+	var visible []server.RequestLogEntry
+	for _, entry := range m.requests {
+		if m.statusFilter == "ok" && !entry.Success || m.statusFilter == "failed" && entry.Success {
+			continue
+		}
+		if m.topicFilter != "" && entry.Topic != m.topicFilter {
+			continue
+		}
+		visible = append(visible, entry)
+	}
+	return visible
+}
+
+// selectedIndex is the position of the selected record in visible; without a
+// selection the newest record is selected and followed
+func (m *ServerDashboardModel) selectedIndex(visible []server.RequestLogEntry) int {
+	if m.selectedSeq != 0 {
+		for i, entry := range visible {
+			if entry.Seq == m.selectedSeq {
+				return i
+			}
+		}
+	}
+	return len(visible) - 1
+}
+
+// moveSelection handles up, down, home and end in the request log
+func (m *ServerDashboardModel) moveSelection(key string) {
+	visible := m.visibleRequests()
+	if len(visible) == 0 {
+		return
+	}
+	i := m.selectedIndex(visible)
+	switch key {
+	case "up", "k":
+		if i > 0 {
+			i--
+		}
+	case "down", "j":
+		i++
+	case "home", "g":
+		i = 0
+	case "end", "G":
+		i = len(visible)
+	}
+	if i >= len(visible)-1 {
+		// Follow new records again
+		m.selectedSeq = 0
+		return
+	}
+	m.selectedSeq = visible[i].Seq
+}
+
+func (m *ServerDashboardModel) cycleStatusFilter() {
+	switch m.statusFilter {
+	case "":
+		m.statusFilter = "ok"
+	case "ok":
+		m.statusFilter = "failed"
+	default:
+		m.statusFilter = ""
+	}
+	m.selectedSeq = 0
+}
+
+// cycleTopicFilter steps through the topics seen so far, then back to all
+func (m *ServerDashboardModel) cycleTopicFilter() {
+	seen := make(map[string]bool)
+	var topics []string
+	for _, entry := range m.requests {
+		if !seen[entry.Topic] {
+			seen[entry.Topic] = true
+			topics = append(topics, entry.Topic)
+		}
+	}
+	sort.Strings(topics)
+
+	next := ""
+	if m.topicFilter == "" && len(topics) > 0 {
+		next = topics[0]
+	} else {
+		for i, topic := range topics {
+			if topic == m.topicFilter && i+1 < len(topics) {
+				next = topics[i+1]
+			}
+		}
+	}
+	m.topicFilter = next
+	m.selectedSeq = 0
+}
+
+// openRequestDetail shows the selected record in the detail view
+func (m *ServerDashboardModel) openRequestDetail() {
+	visible := m.visibleRequests()
+	if len(visible) == 0 {
+		return
+	}
+	entry := visible[m.selectedIndex(visible)]
+	m.detail = &entry
+}
+
+func (m ServerDashboardModel) viewRequestLog(normalStyle, successStyle, errorStyle, helpStyle lipgloss.Style) string {
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FAFAFA")).Background(lipgloss.Color("#3B3363"))
+
+	statusFilter, topicFilter, mode := "all", "all", "▶ live"
+	if m.statusFilter != "" {
+		statusFilter = m.statusFilter
+	}
+	if m.topicFilter != "" {
+		topicFilter = m.topicFilter
+	}
+	if m.paused {
+		mode = "⏸ paused"
+	}
+	visible := m.visibleRequests()
+
+	var b strings.Builder
+	b.WriteString(helpStyle.Render(fmt.Sprintf("%s • status: %s • topic: %s • %d of %d records",
+		mode, statusFilter, topicFilter, len(visible), len(m.requests))))
+	b.WriteString("\n")
+	b.WriteString(normalStyle.Render(fmt.Sprintf("  %-8s  %-15s  %-12s  %-14s  %-12s  %-6s  %7s  %s",
+		"TIME", "SOURCE", "AGENT", "TOPIC", "NAME", "STATUS", "LATENCY", "SYS_ID")))
+
+	if len(visible) == 0 {
+		b.WriteString("\n" + helpStyle.Render("  No requests yet"))
+		return b.String()
+	}
+
+	selected := m.selectedIndex(visible)
+	start := 0
+	if selected >= requestLogRows {
+		start = selected - requestLogRows + 1
+	}
+	end := start + requestLogRows
+	if end > len(visible) {
+		end = len(visible)
+	}
+	for i := start; i < end; i++ {
+		entry := visible[i]
+		status := successStyle.Render(fmt.Sprintf("%-6s", "ok"))
+		if !entry.Success {
+			status = errorStyle.Render(fmt.Sprintf("%-6s", "failed"))
+		}
+		marker := "  "
+		if i == selected {
+			marker = "› "
+		}
+		row := fmt.Sprintf("%-8s  %-15s  %-12s  %-14s  %-12s  ", entry.Time.Format("15:04:05"),
+			truncate(entry.Source, 15), truncate(entry.Agent, 12), truncate(entry.Topic, 14), truncate(entry.Name, 12))
+		rest := fmt.Sprintf("  %7s  %s", formatLatency(entry.Latency), entry.SysID)
+		if i == selected {
+			b.WriteString("\n" + selectedStyle.Render(marker+row) + status + selectedStyle.Render(rest))
+		} else {
+			b.WriteString("\n" + marker + row + status + rest)
+		}
+	}
+	return b.String()
+}
+
+func (m ServerDashboardModel) viewRequestDetail(headerStyle, normalStyle, successStyle, errorStyle, boxStyle lipgloss.Style) string {
+	entry := m.detail
+	status := successStyle.Render("ok")
+	if !entry.Success {
+		status = errorStyle.Render("failed: " + entry.Error)
+	}
+	sysID := entry.SysID
+	if sysID == "" {
+		sysID = "-"
+	}
+
+	fields := fmt.Sprintf(
+		"Time: %s\nSource: %s\nAgent: %s\nTopic: %s\nName: %s\nStatus: %s\nLatency: %s\nsys_id: %s",
+		normalStyle.Render(entry.Time.Format("2006-01-02 15:04:05.000")),
+		normalStyle.Render(entry.Source),
+		normalStyle.Render(entry.Agent),
+		normalStyle.Render(entry.Topic),
+		normalStyle.Render(entry.Name),
+		status,
+		normalStyle.Render(formatLatency(entry.Latency)),
+		normalStyle.Render(sysID),
+	)
+
+	response := entry.Response
+	if response == "" {
+		response = "(no ECC queue response; see events.output)"
+	}
+
+	var b strings.Builder
+	b.WriteString(boxStyle.Render(headerStyle.Render(fmt.Sprintf("Request #%d", entry.Seq)) + "\n" + fields))
+	b.WriteString("\n\n")
+	b.WriteString(boxStyle.Render(headerStyle.Render("Payload") + "\n" + prettyBody(entry.Payload)))
+	b.WriteString("\n\n")
+	b.WriteString(boxStyle.Render(headerStyle.Render("ServiceNow Response") + "\n" + prettyBody(response)))
+	return b.String()
+}
+
+// prettyBody indents JSON and limits the number of lines shown
+func prettyBody(body string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(body), "", "  "); err == nil {
+		body = out.String()
+	}
+	lines := strings.Split(body, "\n")
+	if len(lines) > detailMaxLines {
+		lines = append(lines[:detailMaxLines], fmt.Sprintf("… %d more lines", len(lines)-detailMaxLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,12 +18,23 @@ type ServerDashboardModel struct {
 	config     *config.Config
 	status     ServerStatus
 	startTime  time.Time
-	requests   int
+	stats      server.RequestStats
 	lastUpdate time.Time
 	spinner    int
 	width      int
 	height     int
 	quitting   bool
+	err        error
+	errStage   string
+
+	// Request log pane
+	requests     []server.RequestLogEntry
+	logSeq       uint64
+	selectedSeq  uint64
+	statusFilter string
+	topicFilter  string
+	paused       bool
+	detail       *server.RequestLogEntry
 }
 
 type ServerStatus int
@@ -39,16 +51,17 @@ func NewServerDashboardModel(cfg *config.Config) ServerDashboardModel {
 		config:     cfg,
 		status:     StatusStopped,
 		startTime:  time.Now(),
-		requests:   0,
 		lastUpdate: time.Now(),
 		spinner:    0,
 	}
 }
 
+// ServerStatusMsg reports a lifecycle change of the server it carries
 type ServerStatusMsg struct {
-	status   ServerStatus
-	error    error
-	requests int
+	server *server.Server
+	status ServerStatus
+	stage  string
+	error  error
 }
 
 func (m ServerDashboardModel) Init() tea.Cmd {
@@ -60,6 +73,15 @@ func (m ServerDashboardModel) Init() tea.Cmd {
 func (m ServerDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// The detail view closes with Esc, Enter or Backspace
+		if m.detail != nil && msg.Type != tea.KeyCtrlC {
+			switch msg.Type {
+			case tea.KeyEsc, tea.KeyEnter, tea.KeyBackspace:
+				m.detail = nil
+			}
+			return m, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			m.quitting = true
@@ -70,46 +92,73 @@ func (m ServerDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 
 		case tea.KeyEnter, tea.KeySpace:
-			if m.status == StatusStopped {
-				m.status = StatusStarting
-				m.server = server.NewServer(m.config)
-				return m, tea.Batch(
-					startServer(m.server),
-					tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
-						return TickMsg(t)
-					}),
-				)
-			} else if m.status == StatusRunning {
-				m.status = StatusStopped
-				if m.server != nil {
-					m.server.Stop()
-					m.server = nil
-				}
+			if msg.Type == tea.KeyEnter && m.status == StatusRunning {
+				m.openRequestDetail()
+				return m, nil
+			}
+			switch m.status {
+			case StatusStopped, StatusError:
+				return m, m.start()
+			case StatusRunning:
+				m.stop()
 			}
+		case tea.KeyUp, tea.KeyDown, tea.KeyHome, tea.KeyEnd:
+			m.moveSelection(msg.String())
 		case tea.KeyRunes:
-			if strings.ToLower(string(msg.Runes)) == "q" {
+			switch key := string(msg.Runes); strings.ToLower(key) {
+			case "q":
 				m.quitting = true
 				if m.server != nil {
 					m.server.Stop()
 				}
 				return m, tea.Quit
+			case "r":
+				if m.status == StatusError || m.status == StatusRunning {
+					m.stop()
+					return m, m.start()
+				}
+			case "k", "j", "g":
+				m.moveSelection(key)
+			case "f":
+				m.cycleStatusFilter()
+			case "t":
+				m.cycleTopicFilter()
+			case "p":
+				m.paused = !m.paused
+				if !m.paused && m.server != nil {
+					m.fetchRequests()
+				}
 			}
 		}
 
 	case TickMsg:
 		m.spinner = (m.spinner + 1) % 4
 		m.lastUpdate = time.Now()
+		if m.server != nil {
+			m.stats = m.server.Stats()
+			if !m.paused {
+				m.fetchRequests()
+			}
+		}
 		return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
 			return TickMsg(t)
 		})
 
 	case ServerStatusMsg:
-		m.status = msg.status
-		if msg.error != nil {
-			// Handle server error
+		if msg.server != m.server {
+			// The server was stopped or replaced in the meantime
+			return m, nil
 		}
-		if msg.requests > m.requests {
-			m.requests = msg.requests
+		m.status = msg.status
+		m.err, m.errStage = msg.error, msg.stage
+		switch msg.status {
+		case StatusRunning:
+			m.startTime = time.Now()
+			return m, serveServer(m.server)
+		case StatusError:
+			// Release the ports of receivers started before the failure
+			m.server.Stop()
+			m.server = nil
 		}
 
 	case tea.WindowSizeMsg:
@@ -120,15 +169,62 @@ func (m ServerDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// start creates a server from the configuration and binds it in the
+// background; the outcome arrives as a ServerStatusMsg
+func (m *ServerDashboardModel) start() tea.Cmd {
+	srv, err := server.NewServer(m.config)
+	if err != nil {
+		m.status, m.err, m.errStage = StatusError, err, "Startup failed"
+		return nil
+	}
+	m.server = srv
+	m.status, m.err, m.errStage = StatusStarting, nil, ""
+	m.stats = server.RequestStats{}
+	m.requests, m.logSeq, m.selectedSeq = nil, 0, 0
+	return startServer(srv)
+}
+
+func (m *ServerDashboardModel) stop() {
+	if m.server != nil {
+		m.server.Stop()
+		m.server = nil
+	}
+	m.status = StatusStopped
+}
+
+// startServer tests the connection and binds the listener; the server is
+// only reported as running once it accepts connections
 func startServer(srv *server.Server) tea.Cmd {
 	return func() tea.Msg {
-		go func() {
-			if err := srv.Start(); err != nil {
-				// Send error message
-			}
-		}()
-		return ServerStatusMsg{status: StatusRunning}
+		if err := srv.Listen(); err != nil {
+			return ServerStatusMsg{server: srv, status: StatusError, stage: "Startup failed", error: err}
+		}
+		return ServerStatusMsg{server: srv, status: StatusRunning}
+	}
+}
+
+// serveServer handles connections until the server stops, reporting listener
+// failures
+func serveServer(srv *server.Server) tea.Cmd {
+	return func() tea.Msg {
+		if err := srv.Serve(); err != nil {
+			return ServerStatusMsg{server: srv, status: StatusError, stage: "Listener failed", error: err}
+		}
+		return nil
+	}
+}
+
+// errorHint suggests a fix for common startup errors
+func errorHint(err error) string {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "address already in use"):
+		return "Another process is using this address; stop it or change server.port"
+	case strings.Contains(msg, "permission denied") && strings.Contains(msg, "listen"):
+		return "Ports below 1024 need elevated privileges; choose another server.port"
+	case strings.Contains(msg, "connection test failed"):
+		return "Run 'litemidgo doctor' to diagnose the ServiceNow connection"
 	}
+	return ""
 }
 
 func (m ServerDashboardModel) View() string {
@@ -152,6 +248,13 @@ func (m ServerDashboardModel) View() string {
 	content.WriteString(titleStyle.Render("🚀 LiteMIDgo Server Dashboard"))
 	content.WriteString("\n\n")
 
+	if m.detail != nil {
+		content.WriteString(m.viewRequestDetail(headerStyle, normalStyle, successStyle, errorStyle, boxStyle))
+		content.WriteString("\n\n")
+		content.WriteString(helpStyle.Render("Esc/Enter to go back • Ctrl+C to exit"))
+		return lipgloss.NewStyle().Padding(1, 2).Render(content.String())
+	}
+
 	// Server Status Box
 	statusText := "Stopped"
 	statusColor := errorStyle
@@ -163,12 +266,29 @@ func (m ServerDashboardModel) View() string {
 	case StatusRunning:
 		statusText = "Running"
 		statusColor = successStyle
+		if m.stats.UpstreamFailing() {
+			statusText = "Running (ServiceNow failing)"
+			statusColor = warningStyle
+		}
 	case StatusError:
 		statusText = "Error"
 		statusColor = errorStyle
 	}
 
 	statusBox := fmt.Sprintf("Status: %s", statusColor.Render(statusText))
+	detailStyle := normalStyle.Width(76)
+	switch {
+	case m.err != nil:
+		statusBox += "\n\n" + errorStyle.Render(m.errStage) + "\n" + detailStyle.Render(m.err.Error())
+		if hint := errorHint(m.err); hint != "" {
+			statusBox += "\n" + infoStyle.Render("💡 "+hint)
+		}
+	case m.status == StatusRunning && m.stats.UpstreamFailing():
+		statusBox += "\n\n" + warningStyle.Render(fmt.Sprintf("%d consecutive records failed, last at %s",
+			m.stats.ConsecutiveFailures, m.stats.LastErrorAt.Format("15:04:05"))) +
+			"\n" + detailStyle.Render(m.stats.LastError) +
+			"\n" + infoStyle.Render("💡 Press R to restart the server and retest the ServiceNow connection")
+	}
 	content.WriteString(boxStyle.Render(headerStyle.Render("Server Status") + "\n" + statusBox))
 	content.WriteString("\n\n")
 
@@ -183,29 +303,119 @@ func (m ServerDashboardModel) View() string {
 	content.WriteString("\n\n")
 
 	// Statistics Box
-	uptime := time.Since(m.startTime)
+	uptime := "-"
+	if m.status == StatusRunning {
+		uptime = time.Since(m.startTime).Round(time.Second).String()
+	}
+	stats := m.stats
+	latency := "n/a"
+	if stats.LatencyP50 > 0 {
+		latency = fmt.Sprintf("p50 %s • p90 %s • p99 %s", formatLatency(stats.LatencyP50),
+			formatLatency(stats.LatencyP90), formatLatency(stats.LatencyP99))
+	}
+	rate := 0
+	if len(stats.PerSecond) > 1 {
+		// The last second is still in progress
+		rate = stats.PerSecond[len(stats.PerSecond)-2]
+	}
 	statsBox := fmt.Sprintf(
-		"Uptime: %s\nRequests: %s\nLast Update: %s",
-		normalStyle.Render(uptime.Round(time.Second).String()),
-		normalStyle.Render(fmt.Sprintf("%d", m.requests)),
+		"Uptime: %s\nRequests: %s  %s  %s  %s\nRate (60s): %s %s\nUpstream Latency: %s\nLast Update: %s",
+		normalStyle.Render(uptime),
+		normalStyle.Render(fmt.Sprintf("%d", stats.Total)),
+		successStyle.Render(fmt.Sprintf("✓ %d", stats.Success)),
+		errorStyle.Render(fmt.Sprintf("✗ %d", stats.Failed)),
+		warningStyle.Render(fmt.Sprintf("⋯ %d in flight", stats.InFlight)),
+		infoStyle.Render(sparkline(stats.PerSecond)),
+		normalStyle.Render(fmt.Sprintf("%d/s", rate)),
+		normalStyle.Render(latency),
 		normalStyle.Render(m.lastUpdate.Format("15:04:05")),
 	)
+	if topics := topTopics(stats.Topics, 5); topics != "" {
+		statsBox += "\nTopics:\n" + normalStyle.Render(topics)
+	}
 	content.WriteString(boxStyle.Render(headerStyle.Render("Statistics") + "\n" + statsBox))
 	content.WriteString("\n\n")
 
+	// Request Log Box
+	if m.status == StatusRunning || len(m.requests) > 0 {
+		content.WriteString(boxStyle.Render(headerStyle.Render("Request Log") + "\n" + m.viewRequestLog(normalStyle, successStyle, errorStyle, helpStyle)))
+		content.WriteString("\n\n")
+	}
+
 	// Endpoints Box
-	endpointsBox := infoStyle.Render("GET  /health\nPOST /proxy/ecc_queue\nGET  /")
+	endpoints := "GET  /health\nPOST /proxy/ecc_queue\nPOST /proxy/ecc_queue/stream\nPOST /proxy/em_event\nGET  /"
+	if m.config.Server.Web.Enabled {
+		endpoints += fmt.Sprintf("\nGET  /ui/ (web dashboard: http://%s:%d/ui/)", m.config.Server.Host, m.config.Server.Port)
+	}
+	endpointsBox := infoStyle.Render(endpoints)
 	content.WriteString(boxStyle.Render(headerStyle.Render("Available Endpoints") + "\n" + endpointsBox))
 
 	// Help text
 	content.WriteString("\n\n")
 	if m.status == StatusStopped {
 		content.WriteString(helpStyle.Render("Press Enter/Space to start server • Q/Ctrl+C to exit"))
+	} else if m.status == StatusError {
+		content.WriteString(helpStyle.Render("Press R/Enter to retry • Q/Ctrl+C to exit"))
 	} else if m.status == StatusRunning {
-		content.WriteString(helpStyle.Render("Press Enter/Space to stop server • Q/Ctrl+C to exit"))
+		content.WriteString(helpStyle.Render("↑/↓ select • Enter details • F status filter • T topic filter • P pause\nSpace to stop server • R to restart • Q/Ctrl+C to exit"))
 	} else {
 		content.WriteString(helpStyle.Render("Q/Ctrl+C to exit"))
 	}
 
 	return lipgloss.NewStyle().Padding(1, 2).Render(content.String())
 }
+
+// sparkline draws one bar per value, scaled to the largest value
+func sparkline(values []int) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	peak := 0
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		if peak == 0 || v == 0 {
+			line[i] = bars[0]
+			continue
+		}
+		line[i] = bars[(v*(len(bars)-1)+peak-1)/peak]
+	}
+	return string(line)
+}
+
+// topTopics lists the busiest topics with their failure counts
+func topTopics(topics map[string]server.TopicStats, n int) string {
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if topics[names[i]].Total != topics[names[j]].Total {
+			return topics[names[i]].Total > topics[names[j]].Total
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+
+	var lines []string
+	for _, name := range names {
+		t := topics[name]
+		line := fmt.Sprintf("  %-24s %d", name, t.Total)
+		if t.Failed > 0 {
+			line += fmt.Sprintf(" (%d failed)", t.Failed)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatLatency(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,93 @@
+package mockinstance
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// authenticate accepts basic auth with the configured user and bearer tokens
+// issued by /oauth_token.do, returning the user name the request runs as.
+func (i *Instance) authenticate(r *http.Request) (string, bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		expiry, ok := i.tokens[token]
+		if !ok || time.Now().After(expiry) {
+			delete(i.tokens, token)
+			return "", false
+		}
+		return i.opts.Username, true
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(username), []byte(i.opts.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(i.opts.Password)) != 1 {
+		return "", false
+	}
+	return username, true
+}
+
+// handleOAuthToken implements the password, client_credentials and
+// refresh_token grants of /oauth_token.do. It is only available when a client
+// ID is configured.
+func (i *Instance) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if i.opts.ClientID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "server_error", "error_description": "OAuth is not enabled"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	if id, secret, ok := r.BasicAuth(); ok {
+		clientID, clientSecret = id, secret
+	}
+	if subtle.ConstantTimeCompare([]byte(clientID), []byte(i.opts.ClientID)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(clientSecret), []byte(i.opts.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "access_denied", "error_description": "invalid client credentials"})
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		if r.PostForm.Get("username") != i.opts.Username || r.PostForm.Get("password") != i.opts.Password {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "access_denied", "error_description": "invalid user credentials"})
+			return
+		}
+	case "client_credentials":
+	case "refresh_token":
+		i.mu.Lock()
+		valid := i.refresh[r.PostForm.Get("refresh_token")]
+		delete(i.refresh, r.PostForm.Get("refresh_token"))
+		i.mu.Unlock()
+		if !valid {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant", "error_description": "unknown refresh token"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	accessToken, refreshToken := newSysID()+newSysID(), newSysID()+newSysID()
+	i.mu.Lock()
+	i.tokens[accessToken] = time.Now().Add(tokenLifetime)
+	i.refresh[refreshToken] = true
+	i.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"scope":         "useraccount",
+		"token_type":    "Bearer",
+		"expires_in":    int(tokenLifetime.Seconds()),
+	})
+}
@@ -0,0 +1,597 @@
+// Package mockinstance is a small stand-in for a ServiceNow instance. It
+// serves the REST APIs liteMIDgo uses (Table API, import sets, Event
+// Management, Attachment and IRE), keeps records in memory or on disk, and
+// can inject latency, rate limiting and server errors. It backs the
+// `litemidgo mock-instance` command and can be used from Go tests:
+//
+//	inst, _ := mockinstance.New(mockinstance.Options{})
+//	srv := httptest.NewServer(inst)
+//	defer srv.Close()
+package mockinstance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a mock instance. Zero values give an in-memory instance
+// with admin/admin basic auth and no fault injection.
+type Options struct {
+	Username string
+	Password string
+	// ClientID and ClientSecret enable OAuth tokens from /oauth_token.do
+	ClientID     string
+	ClientSecret string
+	// DataDir persists tables (and attachment content) to disk when set
+	DataDir string
+
+	// Latency is added to every request, plus a random amount up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// RateLimitRate and ErrorRate are the fractions (0-1) of requests answered
+	// with 429 Too Many Requests and with a 500/503 error
+	RateLimitRate float64
+	ErrorRate     float64
+	// RetryAfter is sent with 429 responses, in seconds
+	RetryAfter int
+
+	// Logger receives one line per request; nil disables request logging
+	Logger *log.Logger
+}
+
+// Instance is an http.Handler serving the mock APIs
+type Instance struct {
+	opts  Options
+	store *Store
+	mux   *http.ServeMux
+
+	mu          sync.Mutex
+	tokens      map[string]time.Time
+	refresh     map[string]bool
+	attachments map[string][]byte
+	random      *rand.Rand
+}
+
+const tokenLifetime = 30 * time.Minute
+
+func New(opts Options) (*Instance, error) {
+	if opts.Username == "" {
+		opts.Username = "admin"
+	}
+	if opts.Password == "" {
+		opts.Password = "admin"
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = 1
+	}
+
+	store, err := NewStore(opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &Instance{
+		opts:        opts,
+		store:       store,
+		mux:         http.NewServeMux(),
+		tokens:      make(map[string]time.Time),
+		refresh:     make(map[string]bool),
+		attachments: make(map[string][]byte),
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	// The Table API connection test reads sys_user, so make sure it has the
+	// configured user
+	users, _, err := store.List("sys_user", "user_name="+opts.Username, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		if _, err := store.Insert("sys_user", map[string]interface{}{
+			"user_name": opts.Username,
+			"name":      "Mock Administrator",
+			"active":    "true",
+		}, "system"); err != nil {
+			return nil, err
+		}
+	}
+
+	inst.routes()
+	return inst, nil
+}
+
+// Store gives tests direct access to the records
+func (i *Instance) Store() *Store {
+	return i.store
+}
+
+func (i *Instance) routes() {
+	i.mux.HandleFunc("POST /oauth_token.do", i.handleOAuthToken)
+
+	i.mux.HandleFunc("GET /api/now/table/{table}", i.handleTableList)
+	i.mux.HandleFunc("POST /api/now/table/{table}", i.handleTableInsert)
+	i.mux.HandleFunc("GET /api/now/table/{table}/{sys_id}", i.handleTableGet)
+	i.mux.HandleFunc("PUT /api/now/table/{table}/{sys_id}", i.handleTableUpdate)
+	i.mux.HandleFunc("PATCH /api/now/table/{table}/{sys_id}", i.handleTableUpdate)
+	i.mux.HandleFunc("DELETE /api/now/table/{table}/{sys_id}", i.handleTableDelete)
+
+	i.mux.HandleFunc("POST /api/now/import/{table}", i.handleImport)
+	i.mux.HandleFunc("POST /api/now/import/{table}/insertMultiple", i.handleImportMultiple)
+
+	i.mux.HandleFunc("POST /api/global/em/jsonv2", i.handleEvents)
+
+	i.mux.HandleFunc("POST /api/now/attachment/file", i.handleAttachmentUpload)
+	i.mux.HandleFunc("GET /api/now/attachment/{sys_id}", i.handleAttachmentGet)
+	i.mux.HandleFunc("GET /api/now/attachment/{sys_id}/file", i.handleAttachmentDownload)
+
+	i.mux.HandleFunc("POST /api/now/identifyreconcile", i.handleIdentifyReconcile)
+	i.mux.HandleFunc("POST /api/now/identifyreconcile/query", i.handleIdentifyReconcile)
+}
+
+// statusRecorder captures the status code for request logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (i *Instance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	i.serve(rec, r)
+
+	if i.opts.Logger != nil {
+		i.opts.Logger.Printf("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+func (i *Instance) serve(w http.ResponseWriter, r *http.Request) {
+	if delay := i.delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	user, ok := i.authenticate(r)
+	if !ok && r.URL.Path != "/oauth_token.do" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Service-now"`)
+		writeError(w, http.StatusUnauthorized, "User Not Authenticated", "Required to provide Auth information")
+		return
+	}
+
+	switch fault := i.fault(); fault {
+	case http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", strconv.Itoa(i.opts.RetryAfter))
+		writeError(w, fault, "Rate limit exceeded", "Injected by mock instance")
+		return
+	case http.StatusInternalServerError, http.StatusServiceUnavailable:
+		writeError(w, fault, "Internal server error", "Injected by mock instance")
+		return
+	}
+
+	r.Header.Set("X-Mock-User", user)
+	i.mux.ServeHTTP(w, r)
+}
+
+func (i *Instance) delay() time.Duration {
+	delay := i.opts.Latency
+	if i.opts.Jitter > 0 {
+		i.mu.Lock()
+		delay += time.Duration(i.random.Int63n(int64(i.opts.Jitter)))
+		i.mu.Unlock()
+	}
+	return delay
+}
+
+// fault picks an injected status code for this request, or 0
+func (i *Instance) fault() int {
+	if i.opts.RateLimitRate <= 0 && i.opts.ErrorRate <= 0 {
+		return 0
+	}
+
+	i.mu.Lock()
+	roll := i.random.Float64()
+	serviceUnavailable := i.random.Intn(2) == 0
+	i.mu.Unlock()
+
+	switch {
+	case roll < i.opts.RateLimitRate:
+		return http.StatusTooManyRequests
+	case roll < i.opts.RateLimitRate+i.opts.ErrorRate:
+		if serviceUnavailable {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusInternalServerError
+	}
+	return 0
+}
+
+// writeStoreError responds 400 for invalid table names and 500 otherwise
+func writeStoreError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, ErrInvalidTable) {
+		writeError(w, http.StatusBadRequest, "Invalid table", err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, message, err.Error())
+}
+
+// writeError responds in the Table API error format
+func writeError(w http.ResponseWriter, status int, message, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":  map[string]string{"message": message, "detail": detail},
+		"status": "failure",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func readValues(r *http.Request) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return values, nil
+}
+
+func selectFields(record Record, fields string) Record {
+	if fields == "" {
+		return record
+	}
+	selected := Record{}
+	for _, field := range strings.Split(fields, ",") {
+		if value, ok := record[strings.TrimSpace(field)]; ok {
+			selected[strings.TrimSpace(field)] = value
+		}
+	}
+	return selected
+}
+
+func (i *Instance) handleTableList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 10000
+	if value := q.Get("sysparm_limit"); value != "" {
+		limit, _ = strconv.Atoi(value)
+	}
+	offset, _ := strconv.Atoi(q.Get("sysparm_offset"))
+
+	records, total, err := i.store.List(r.PathValue("table"), q.Get("sysparm_query"), offset, limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	result := make([]Record, len(records))
+	for j, record := range records {
+		result[j] = selectFields(record, q.Get("sysparm_fields"))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+func (i *Instance) handleTableGet(w http.ResponseWriter, r *http.Request) {
+	record, ok := i.store.Get(r.PathValue("table"), r.PathValue("sys_id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "No Record found", "Record doesn't exist or ACL restricts the record retrieval")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": selectFields(record, r.URL.Query().Get("sysparm_fields"))})
+}
+
+func (i *Instance) handleTableInsert(w http.ResponseWriter, r *http.Request) {
+	values, err := readValues(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	table := r.PathValue("table")
+	if table == "ecc_queue" {
+		// Records inserted through the API land on the input queue, ready to process
+		if _, ok := values["queue"]; !ok {
+			values["queue"] = "input"
+		}
+		if _, ok := values["state"]; !ok {
+			values["state"] = "ready"
+		}
+	}
+
+	record, err := i.store.Insert(table, values, r.Header.Get("X-Mock-User"))
+	if err != nil {
+		writeStoreError(w, "Failed to store record", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/now/table/%s/%s", table, record["sys_id"]))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"result": record})
+}
+
+func (i *Instance) handleTableUpdate(w http.ResponseWriter, r *http.Request) {
+	values, err := readValues(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	record, ok, err := i.store.Update(r.PathValue("table"), r.PathValue("sys_id"), values, r.Header.Get("X-Mock-User"))
+	if err != nil {
+		writeStoreError(w, "Failed to store record", err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "No Record found", "Record doesn't exist or ACL restricts the record retrieval")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": record})
+}
+
+func (i *Instance) handleTableDelete(w http.ResponseWriter, r *http.Request) {
+	ok, err := i.store.Delete(r.PathValue("table"), r.PathValue("sys_id"))
+	if err != nil {
+		writeStoreError(w, "Failed to delete record", err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "No Record found", "Record doesn't exist or ACL restricts the record retrieval")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importRow stores one import set row in the staging table. There are no
+// transform maps, so every row is reported as inserted into the staging table.
+func (i *Instance) importRow(table string, values map[string]interface{}, user, importSet string) (map[string]string, error) {
+	values["sys_import_set"] = importSet
+	values["sys_import_state"] = "processed"
+	record, err := i.store.Insert(table, values, user)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"transform_map": "mock",
+		"table":         table,
+		"display_name":  "sys_id",
+		"display_value": record["sys_id"],
+		"record_link":   fmt.Sprintf("/api/now/table/%s/%s", table, record["sys_id"]),
+		"status":        "inserted",
+		"sys_id":        record["sys_id"],
+	}, nil
+}
+
+func (i *Instance) handleImport(w http.ResponseWriter, r *http.Request) {
+	values, err := readValues(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	table := r.PathValue("table")
+	importSet := "ISET" + newSysID()[:7]
+	row, err := i.importRow(table, values, r.Header.Get("X-Mock-User"), importSet)
+	if err != nil {
+		writeStoreError(w, "Failed to store record", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"import_set":    importSet,
+		"staging_table": table,
+		"result":        []map[string]string{row},
+	})
+}
+
+func (i *Instance) handleImportMultiple(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Records []map[string]interface{} `json:"records"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	table := r.PathValue("table")
+	importSet := newSysID()
+	for _, values := range body.Records {
+		if _, err := i.importRow(table, values, r.Header.Get("X-Mock-User"), importSet); err != nil {
+			writeStoreError(w, "Failed to store record", err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"import_set_id":       importSet,
+		"multi_import_set_id": newSysID(),
+	})
+}
+
+func (i *Instance) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Records []map[string]interface{} `json:"records"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	for _, values := range body.Records {
+		values["state"] = "Ready"
+		if _, err := i.store.Insert("em_event", values, r.Header.Get("X-Mock-User")); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to store record", err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result": map[string]string{
+			"Default Bulk Endpoint": fmt.Sprintf("%d events were inserted", len(body.Records)),
+		},
+	})
+}
+
+func (i *Instance) handleAttachmentUpload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	table, tableSysID, fileName := q.Get("table_name"), q.Get("table_sys_id"), q.Get("file_name")
+	if table == "" || tableSysID == "" || fileName == "" {
+		writeError(w, http.StatusBadRequest, "Missing parameters", "table_name, table_sys_id and file_name are required")
+		return
+	}
+	if _, ok := i.store.Get(table, tableSysID); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid table or record", fmt.Sprintf("%s/%s does not exist", table, tableSysID))
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read attachment", err.Error())
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	record, err := i.store.Insert("sys_attachment", map[string]interface{}{
+		"file_name":    fileName,
+		"content_type": contentType,
+		"size_bytes":   strconv.Itoa(len(content)),
+		"table_name":   table,
+		"table_sys_id": tableSysID,
+	}, r.Header.Get("X-Mock-User"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to store attachment", err.Error())
+		return
+	}
+
+	if err := i.saveAttachment(record["sys_id"], content); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to store attachment", err.Error())
+		return
+	}
+
+	record["download_link"] = fmt.Sprintf("/api/now/attachment/%s/file", record["sys_id"])
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"result": record})
+}
+
+func (i *Instance) saveAttachment(sysID string, content []byte) error {
+	if i.opts.DataDir != "" {
+		if !tableName.MatchString(sysID) {
+			return fmt.Errorf("invalid attachment sys_id %q", sysID)
+		}
+		dir := filepath.Join(i.opts.DataDir, "attachments")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, sysID), content, 0o600)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.attachments[sysID] = content
+	return nil
+}
+
+func (i *Instance) loadAttachment(sysID string) ([]byte, error) {
+	if i.opts.DataDir != "" {
+		// sys_id can be set by clients inserting into sys_attachment
+		if !tableName.MatchString(sysID) {
+			return nil, os.ErrNotExist
+		}
+		return os.ReadFile(filepath.Join(i.opts.DataDir, "attachments", sysID))
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	content, ok := i.attachments[sysID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return content, nil
+}
+
+func (i *Instance) handleAttachmentGet(w http.ResponseWriter, r *http.Request) {
+	record, ok := i.store.Get("sys_attachment", r.PathValue("sys_id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "No Record found", "Attachment doesn't exist")
+		return
+	}
+	record["download_link"] = fmt.Sprintf("/api/now/attachment/%s/file", record["sys_id"])
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": record})
+}
+
+func (i *Instance) handleAttachmentDownload(w http.ResponseWriter, r *http.Request) {
+	record, ok := i.store.Get("sys_attachment", r.PathValue("sys_id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "No Record found", "Attachment doesn't exist")
+		return
+	}
+	content, err := i.loadAttachment(record["sys_id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "No Record found", "Attachment content is missing")
+		return
+	}
+	w.Header().Set("Content-Type", record["content_type"])
+	w.Write(content)
+}
+
+// handleIdentifyReconcile inserts every item into its class table. There is no
+// identification, so each call creates new CIs; /query only reports them.
+func (i *Instance) handleIdentifyReconcile(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Items []struct {
+			ClassName string                 `json:"className"`
+			Values    map[string]interface{} `json:"values"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	for _, item := range body.Items {
+		if err := checkTable(item.ClassName); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid className", err.Error())
+			return
+		}
+	}
+
+	dryRun := r.URL.Path == "/api/now/identifyreconcile/query"
+	items := make([]map[string]interface{}, 0, len(body.Items))
+	for _, item := range body.Items {
+		result := map[string]interface{}{
+			"className": item.ClassName,
+			"operation": "INSERT",
+			"errors":    []interface{}{},
+		}
+		if !dryRun {
+			if item.Values == nil {
+				item.Values = map[string]interface{}{}
+			}
+			item.Values["discovery_source"] = r.URL.Query().Get("sysparm_data_source")
+			record, err := i.store.Insert(item.ClassName, item.Values, r.Header.Get("X-Mock-User"))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to store record", err.Error())
+				return
+			}
+			result["sysId"] = record["sys_id"]
+		}
+		items = append(items, result)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result": map[string]interface{}{"items": items, "relations": []interface{}{}},
+	})
+}
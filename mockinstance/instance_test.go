@@ -0,0 +1,259 @@
+package mockinstance_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"litemidgo/config"
+	"litemidgo/internal/servicenow"
+	"litemidgo/mockinstance"
+)
+
+func newInstance(t *testing.T, opts mockinstance.Options) (*mockinstance.Instance, *httptest.Server) {
+	t.Helper()
+	inst, err := mockinstance.New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	srv := httptest.NewServer(inst)
+	t.Cleanup(srv.Close)
+	return inst, srv
+}
+
+func newClient(t *testing.T, srv *httptest.Server) *servicenow.Client {
+	t.Helper()
+	client, err := servicenow.NewClient(&config.ServiceNowConfig{
+		Instance: strings.TrimPrefix(srv.URL, "http://"),
+		Username: "admin",
+		Password: "admin",
+		Timeout:  5,
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// do sends a request as admin/admin and decodes the JSON response into result
+func do(t *testing.T, method, url string, body interface{}, result interface{}) *http.Response {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("admin", "admin")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, url, err)
+		}
+	}
+	return resp
+}
+
+func TestTableInsertGetList(t *testing.T) {
+	_, srv := newInstance(t, mockinstance.Options{})
+	tableURL := srv.URL + "/api/now/table/incident"
+
+	var created struct {
+		Result mockinstance.Record `json:"result"`
+	}
+	resp := do(t, http.MethodPost, tableURL, map[string]interface{}{"short_description": "disk full", "priority": 2}, &created)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("insert: got status %d, want 201", resp.StatusCode)
+	}
+	sysID := created.Result["sys_id"]
+	if sysID == "" || created.Result["priority"] != "2" || created.Result["sys_created_by"] != "admin" {
+		t.Fatalf("insert: unexpected record %v", created.Result)
+	}
+	do(t, http.MethodPost, tableURL, map[string]interface{}{"short_description": "cpu high", "priority": 1}, nil)
+
+	var got struct {
+		Result mockinstance.Record `json:"result"`
+	}
+	if resp := do(t, http.MethodGet, tableURL+"/"+sysID, nil, &got); resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: got status %d, want 200", resp.StatusCode)
+	}
+	if got.Result["short_description"] != "disk full" {
+		t.Errorf("get: short_description = %q, want %q", got.Result["short_description"], "disk full")
+	}
+	if resp := do(t, http.MethodGet, tableURL+"/missing", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get missing: got status %d, want 404", resp.StatusCode)
+	}
+
+	var list struct {
+		Result []mockinstance.Record `json:"result"`
+	}
+	resp = do(t, http.MethodGet, tableURL+"?sysparm_query=short_descriptionLIKEcpu^ORDERBYpriority", nil, &list)
+	if len(list.Result) != 1 || list.Result[0]["short_description"] != "cpu high" {
+		t.Fatalf("list: got %v, want the cpu high record", list.Result)
+	}
+	if total := resp.Header.Get("X-Total-Count"); total != "1" {
+		t.Errorf("list: X-Total-Count = %q, want 1", total)
+	}
+
+	resp = do(t, http.MethodGet, tableURL+"?sysparm_query=ORDERBYDESCpriority&sysparm_limit=1", nil, &list)
+	if len(list.Result) != 1 || list.Result[0]["priority"] != "2" {
+		t.Fatalf("list paged: got %v, want the priority 2 record", list.Result)
+	}
+	if total := resp.Header.Get("X-Total-Count"); total != "2" {
+		t.Errorf("list paged: X-Total-Count = %q, want 2", total)
+	}
+}
+
+func TestAuthenticationRequired(t *testing.T) {
+	_, srv := newInstance(t, mockinstance.Options{})
+
+	resp, err := http.Get(srv.URL + "/api/now/table/incident")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestInvalidTableNames(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	_, srv := newInstance(t, mockinstance.Options{DataDir: dataDir})
+
+	for _, path := range []string{
+		"/api/now/table/..%2F..%2Fescaped",
+		"/api/now/table/Incident",
+		"/api/now/import/..%2Fescaped",
+	} {
+		resp := do(t, http.MethodPost, srv.URL+path, map[string]interface{}{"a": 1}, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s: got status %d, want 400", path, resp.StatusCode)
+		}
+	}
+
+	ire := map[string]interface{}{
+		"items": []map[string]interface{}{{"className": "../escaped", "values": map[string]string{"name": "x"}}},
+	}
+	if resp := do(t, http.MethodPost, srv.URL+"/api/now/identifyreconcile", ire, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("IRE: got status %d, want 400", resp.StatusCode)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(matches) > 0 {
+		t.Errorf("files written outside the data directory: %v", matches)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "sys_user.json")); err != nil {
+		t.Errorf("valid tables are still persisted: %v", err)
+	}
+}
+
+func TestECCQueueWithClient(t *testing.T) {
+	inst, srv := newInstance(t, mockinstance.Options{})
+	client := newClient(t, srv)
+
+	if err := client.TestConnection(); err != nil {
+		t.Fatalf("TestConnection: %v", err)
+	}
+	resp, err := client.SendToECCQueue(&servicenow.ECCQueuePayload{
+		Agent:   "litemidgo",
+		Topic:   "endpointData",
+		Name:    "web01",
+		Source:  "web01",
+		Payload: map[string]interface{}{"cpu": 12.5},
+	})
+	if err != nil {
+		t.Fatalf("SendToECCQueue: %v", err)
+	}
+
+	records := inst.Store().Records("ecc_queue")
+	if len(records) != 1 {
+		t.Fatalf("got %d ecc_queue records, want 1", len(records))
+	}
+	record := records[0]
+	if record["sys_id"] != resp.Result.SysID {
+		t.Errorf("sys_id = %q, want %q", record["sys_id"], resp.Result.SysID)
+	}
+	if record["queue"] != "input" || record["state"] != "ready" || record["topic"] != "endpointData" {
+		t.Errorf("unexpected record %v", record)
+	}
+	if record["payload"] != `{"cpu":12.5}` {
+		t.Errorf("payload = %q, want the JSON encoding", record["payload"])
+	}
+}
+
+func TestIdentifyReconcileRoundTrip(t *testing.T) {
+	inst, srv := newInstance(t, mockinstance.Options{})
+	client := newClient(t, srv)
+
+	payload := &servicenow.IREPayload{Items: []servicenow.IREItem{
+		{ClassName: "cmdb_ci_linux_server", Values: map[string]interface{}{"name": "web01", "ram": 4096}},
+		{ClassName: "cmdb_ci_ip_address", Values: map[string]interface{}{"ip_address": "10.0.0.5"}},
+	}}
+
+	dry, err := client.IdentifyReconcile(payload, "LiteMIDgo", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(dry.Result.Items) != 2 || dry.Result.Items[0].Operation != "INSERT" {
+		t.Fatalf("dry run: unexpected result %+v", dry.Result)
+	}
+	if n := len(inst.Store().Records("cmdb_ci_linux_server")); n != 0 {
+		t.Fatalf("dry run stored %d records, want 0", n)
+	}
+
+	result, err := client.IdentifyReconcile(payload, "LiteMIDgo", false)
+	if err != nil {
+		t.Fatalf("IdentifyReconcile: %v", err)
+	}
+	if len(result.Result.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(result.Result.Items))
+	}
+
+	server, ok := inst.Store().Get("cmdb_ci_linux_server", result.Result.Items[0].SysID)
+	if !ok {
+		t.Fatalf("CI %s not stored", result.Result.Items[0].SysID)
+	}
+	if server["name"] != "web01" || server["ram"] != "4096" || server["discovery_source"] != "LiteMIDgo" {
+		t.Errorf("unexpected CI %v", server)
+	}
+	if _, ok := inst.Store().Get("cmdb_ci_ip_address", result.Result.Items[1].SysID); !ok {
+		t.Errorf("CI %s not stored", result.Result.Items[1].SysID)
+	}
+}
+
+func TestECCFilterValueIsMatchedLiterally(t *testing.T) {
+	inst, srv := newInstance(t, mockinstance.Options{})
+	client := newClient(t, srv)
+
+	for _, agent := range []string{"x^ORtopic!=y", "x"} {
+		if _, err := inst.Store().Insert("ecc_queue", map[string]interface{}{"agent": agent, "topic": "y"}, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := client.ListECCRecords(context.Background(), servicenow.ECCFilter{Agent: "x^ORtopic!=y"}.Encode(""), 0, 10)
+	if err != nil {
+		t.Fatalf("ListECCRecords: %v", err)
+	}
+	if len(records) != 1 || records[0].Agent != "x^ORtopic!=y" {
+		t.Errorf("got %+v, want only the record whose agent contains the ^", records)
+	}
+}
@@ -0,0 +1,360 @@
+package mockinstance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a table row. As on a real instance, every value is a string.
+type Record map[string]string
+
+// timeLayout is the format ServiceNow uses for sys_created_on and friends
+const timeLayout = "2006-01-02 15:04:05"
+
+// ErrInvalidTable is returned for table names that are not lower-case
+// letters, digits and underscores. Table names become file names in the data
+// directory, so anything else is refused.
+var ErrInvalidTable = errors.New("invalid table name")
+
+var tableName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func checkTable(table string) error {
+	if !tableName.MatchString(table) {
+		return fmt.Errorf("%w %q", ErrInvalidTable, table)
+	}
+	return nil
+}
+
+// Store holds records per table in memory. With a directory set, each table
+// is loaded from and written through to <dir>/<table>.json.
+type Store struct {
+	mu     sync.RWMutex
+	dir    string
+	tables map[string][]Record
+}
+
+// NewStore creates a store. An empty dir keeps everything in memory.
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, tables: make(map[string][]Record)}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var records []Record
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		s.tables[strings.TrimSuffix(filepath.Base(file), ".json")] = records
+	}
+	return s, nil
+}
+
+func newSysID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// stringify converts a JSON value to the string form stored on a record;
+// objects and arrays are kept as their JSON encoding (like ecc_queue.payload).
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// Insert adds a record, filling in the sys_ fields, and returns a copy of it
+func (s *Store) Insert(table string, values map[string]interface{}, user string) (Record, error) {
+	if err := checkTable(table); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(timeLayout)
+	record := Record{}
+	for key, value := range values {
+		record[key] = stringify(value)
+	}
+	if record["sys_id"] == "" {
+		record["sys_id"] = newSysID()
+	}
+	record["sys_created_on"] = now
+	record["sys_updated_on"] = now
+	record["sys_created_by"] = user
+	record["sys_updated_by"] = user
+	record["sys_mod_count"] = "0"
+	record["sys_class_name"] = table
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[table] = append(s.tables[table], record)
+	return copyRecord(record), s.persist(table)
+}
+
+// Get returns a copy of a record by sys_id
+func (s *Store) Get(table, sysID string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.tables[table] {
+		if record["sys_id"] == sysID {
+			return copyRecord(record), true
+		}
+	}
+	return nil, false
+}
+
+// Update merges values into a record and returns the updated copy
+func (s *Store) Update(table, sysID string, values map[string]interface{}, user string) (Record, bool, error) {
+	if err := checkTable(table); err != nil {
+		return nil, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.tables[table] {
+		if record["sys_id"] != sysID {
+			continue
+		}
+		for key, value := range values {
+			if key == "sys_id" {
+				continue
+			}
+			record[key] = stringify(value)
+		}
+		modCount, _ := strconv.Atoi(record["sys_mod_count"])
+		record["sys_mod_count"] = strconv.Itoa(modCount + 1)
+		record["sys_updated_on"] = time.Now().UTC().Format(timeLayout)
+		record["sys_updated_by"] = user
+		return copyRecord(record), true, s.persist(table)
+	}
+	return nil, false, nil
+}
+
+// Delete removes a record by sys_id
+func (s *Store) Delete(table, sysID string) (bool, error) {
+	if err := checkTable(table); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.tables[table]
+	for i, record := range records {
+		if record["sys_id"] == sysID {
+			s.tables[table] = append(records[:i:i], records[i+1:]...)
+			return true, s.persist(table)
+		}
+	}
+	return false, nil
+}
+
+// List returns the records matching an encoded query, ordered and paged, and
+// the total number of matches before paging
+func (s *Store) List(table, encodedQuery string, offset, limit int) ([]Record, int, error) {
+	query, err := parseQuery(encodedQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	var matches []Record
+	for _, record := range s.tables[table] {
+		if query.matches(record) {
+			matches = append(matches, copyRecord(record))
+		}
+	}
+	s.mu.RUnlock()
+
+	if query.orderBy != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if query.descending {
+				return matches[i][query.orderBy] > matches[j][query.orderBy]
+			}
+			return matches[i][query.orderBy] < matches[j][query.orderBy]
+		})
+	}
+
+	total := len(matches)
+	if offset > len(matches) {
+		offset = len(matches)
+	}
+	matches = matches[offset:]
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
+
+// Records returns every record of a table, in insertion order
+func (s *Store) Records(table string) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]Record, len(s.tables[table]))
+	for i, record := range s.tables[table] {
+		records[i] = copyRecord(record)
+	}
+	return records
+}
+
+// persist writes a table to disk; the caller holds the lock
+func (s *Store) persist(table string) error {
+	if s.dir == "" {
+		return nil
+	}
+	if err := checkTable(table); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.tables[table], "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, table+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func copyRecord(record Record) Record {
+	copied := make(Record, len(record))
+	for k, v := range record {
+		copied[k] = v
+	}
+	return copied
+}
+
+// query is a parsed sysparm_query. Conditions are ANDed (^, with ^^ for a
+// literal ^ in a value); supported
+// operators are =, !=, >, >=, <, <=, LIKE, STARTSWITH, ISEMPTY and ISNOTEMPTY,
+// plus ORDERBY<field> and ORDERBYDESC<field>.
+type query struct {
+	conditions []condition
+	orderBy    string
+	descending bool
+}
+
+type condition struct {
+	field    string
+	operator string
+	value    string
+}
+
+var operators = []string{"ISNOTEMPTY", "ISEMPTY", "STARTSWITH", "LIKE", "!=", ">=", "<=", "=", ">", "<"}
+
+func parseQuery(encoded string) (*query, error) {
+	q := &query{}
+	for _, part := range splitQuery(encoded) {
+		if part == "" {
+			continue
+		}
+		if field, ok := strings.CutPrefix(part, "ORDERBYDESC"); ok {
+			q.orderBy, q.descending = field, true
+			continue
+		}
+		if field, ok := strings.CutPrefix(part, "ORDERBY"); ok {
+			q.orderBy = field
+			continue
+		}
+
+		// The operator is the one starting earliest; longer operators win ties
+		best, bestOp := -1, ""
+		for _, op := range operators {
+			if i := strings.Index(part, op); i > 0 && (best < 0 || i < best) {
+				best, bestOp = i, op
+			}
+		}
+		if best < 0 {
+			return nil, fmt.Errorf("unsupported query condition %q", part)
+		}
+		q.conditions = append(q.conditions, condition{field: part[:best], operator: bestOp, value: part[best+len(bestOp):]})
+	}
+	return q, nil
+}
+
+// splitQuery splits an encoded query into its conditions at each "^" that is
+// not part of an escaped "^^"
+func splitQuery(encoded string) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '^' {
+			part.WriteByte(encoded[i])
+			continue
+		}
+		if i+1 < len(encoded) && encoded[i+1] == '^' {
+			part.WriteByte('^')
+			i++
+			continue
+		}
+		parts = append(parts, part.String())
+		part.Reset()
+	}
+	return append(parts, part.String())
+}
+
+func (q *query) matches(record Record) bool {
+	for _, c := range q.conditions {
+		value := record[c.field]
+		var ok bool
+		switch c.operator {
+		case "=":
+			ok = value == c.value
+		case "!=":
+			ok = value != c.value
+		case ">":
+			ok = value > c.value
+		case ">=":
+			ok = value >= c.value
+		case "<":
+			ok = value < c.value
+		case "<=":
+			ok = value <= c.value
+		case "LIKE":
+			ok = strings.Contains(strings.ToLower(value), strings.ToLower(c.value))
+		case "STARTSWITH":
+			ok = strings.HasPrefix(value, c.value)
+		case "ISEMPTY":
+			ok = value == ""
+		case "ISNOTEMPTY":
+			ok = value != ""
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}